@@ -0,0 +1,441 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/lnenad/probster/communication"
+	"github.com/lnenad/probster/scripting"
+	"github.com/lnenad/probster/storage"
+)
+
+// Exit codes of the run command
+const (
+	ExitOK      = 0
+	ExitFailed  = 1
+	ExitUsage   = 2
+	ExitAborted = 130
+)
+
+// Runner executes stored requests without the GUI
+type Runner struct {
+	h        *storage.HistoryStorage
+	settings storage.Settings
+	st       *storage.SettingsStorage
+	cs       *storage.CookieStorage
+	es       *storage.EnvironmentStorage
+	tokens   *communication.TokenCache
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+// NewRunner returns a runner that reads and records entries in the provided storage
+func NewRunner(
+	h *storage.HistoryStorage,
+	settings storage.Settings,
+	st *storage.SettingsStorage,
+	cs *storage.CookieStorage,
+	es *storage.EnvironmentStorage,
+	stdout io.Writer,
+	stderr io.Writer,
+) *Runner {
+	r := &Runner{h: h, settings: settings, st: st, cs: cs, es: es, stdout: stdout, stderr: stderr}
+	r.tokens = communication.NewTokenCache(r.authorize)
+	return r
+}
+
+// authorize shows the authorization page of the OAuth 2.0 authorization code grant
+func (r *Runner) authorize(authURL string) error {
+	fmt.Fprintf(r.stderr, "Waiting for the authorization, open %s if the browser doesn't show it\n", authURL)
+	if err := communication.OpenBrowser(authURL); err != nil {
+		log.Warnf("Unable to open the browser: %s", err)
+	}
+	return nil
+}
+
+// runOptions holds the flags of the run command
+type runOptions struct {
+	env        string
+	method     string
+	list       bool
+	headers    bool
+	timing     bool
+	body       bool
+	save       bool
+	failStatus int
+	verbose    bool
+}
+
+// Run executes the history entries selected by the arguments, printing their
+// responses, and returns the exit code of the command
+func (r *Runner) Run(args []string) int {
+	active, _ := r.settings[storage.SettingEnvironment].(string)
+
+	var opts runOptions
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(r.stderr)
+	flags.StringVar(&opts.env, "env", active, "environment whose variables are substituted, an empty name uses none")
+	flags.StringVar(&opts.method, "method", "", "only run the entries sent with this method")
+	flags.BoolVar(&opts.list, "list", false, "list the selected entries without sending them")
+	flags.BoolVar(&opts.headers, "headers", false, "print the response headers")
+	flags.BoolVar(&opts.timing, "timing", false, "print the timing breakdown of each request")
+	flags.BoolVar(&opts.body, "body", true, "print the response bodies")
+	flags.BoolVar(&opts.save, "save", false, "add the responses to the request history")
+	flags.IntVar(&opts.failStatus, "fail-status", 400, "status code from which a response counts as a failure when the request has no assertions, 0 accepts every status")
+	flags.BoolVar(&opts.verbose, "verbose", false, "log what is being sent")
+	flags.Usage = func() {
+		fmt.Fprintf(r.stderr, `Usage: probster run [flags] <key or URL pattern>...
+
+Runs the history entries with the provided keys, or whose URL matches the
+provided patterns. Patterns match a part of the URL, * and ? wildcards match
+the whole URL. Identical requests are sent once, in the order they were recorded.
+
+Requests with assertions fail when one of them fails, the others when the
+status code reaches -fail-status. Variables extracted from a response are
+available to the requests that follow it, the request scripts run as in the
+app. The exit code is 0 when every request
+succeeds, 1 when one fails and 2 when the arguments are invalid.
+
+Flags:
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	if !opts.verbose {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	var envVars map[string]string
+	if opts.env != "" {
+		if _, exists := r.es.Get(opts.env); !exists {
+			fmt.Fprintf(r.stderr, "Unknown environment %q\n", opts.env)
+			return ExitUsage
+		}
+		envVars = r.es.Variables(opts.env)
+	}
+
+	entries, err := selectEntries(r.h.GetAllRequests(), flags.Args(), strings.ToUpper(opts.method))
+	if err != nil {
+		fmt.Fprintln(r.stderr, err)
+		return ExitUsage
+	}
+
+	if opts.list {
+		for _, entry := range entries {
+			fmt.Fprintf(r.stdout, "%s  %s %s\n", entry.Key, entry.RR.Request.Method, entry.RR.Request.Path)
+		}
+		return ExitOK
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	failed := 0
+	for idx, entry := range entries {
+		if idx > 0 {
+			fmt.Fprintln(r.stdout)
+		}
+		if !r.runEntry(ctx, entry, envVars, opts) {
+			failed++
+		}
+		if ctx.Err() != nil {
+			fmt.Fprintln(r.stderr, "Interrupted")
+			return ExitAborted
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(r.stderr, "%d of %d requests failed\n", failed, len(entries))
+		return ExitFailed
+	}
+	return ExitOK
+}
+
+// runEntry sends the request of the entry and prints the response, it
+// reports whether the request succeeded. The variables extracted by the
+// previous requests are substituted along with the environment ones
+func (r *Runner) runEntry(ctx context.Context, entry storage.HistoryEntry, envVars map[string]string, opts runOptions) bool {
+	request := entry.RR.Request
+
+	// the pre-request script changes the request that is sent, the history keeps the stored one
+	sent := request
+	pre, preErr := scripting.PreRequest(&sent, storage.MergeVariables(envVars, r.settings.Variables()))
+	r.updateVariables(pre.ApplyVariables)
+
+	vars := storage.MergeVariables(envVars, r.settings.Variables())
+	resolved, unresolved := sent.Resolve(vars)
+	fmt.Fprintf(r.stdout, "%s %s\n", resolved.Method, resolved.Path)
+	r.printConsole("pre-request", pre.Console)
+	if preErr != nil {
+		fmt.Fprintf(r.stdout, "Error: %s\n", preErr)
+		return false
+	}
+	if len(unresolved) > 0 {
+		fmt.Fprintf(r.stdout, "Error: unresolved variables {{%s}}\n", strings.Join(unresolved, "}}, {{"))
+		return false
+	}
+	u, err := url.Parse(resolved.Path)
+	if err != nil {
+		fmt.Fprintf(r.stdout, "Error: invalid URL. %s\n", err)
+		return false
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		fmt.Fprintf(r.stdout, "Error: invalid URL scheme %q\n", u.Scheme)
+		return false
+	}
+	headers, body, err := resolved.Outgoing()
+	if err != nil {
+		fmt.Fprintf(r.stdout, "Error: unable to build the request body. %s\n", err)
+		return false
+	}
+
+	start := time.Now()
+	result, err := communication.Send(ctx, resolved.Path, resolved.Method, headers, body, resolved.SendOptions(r.settings, r.cs, r.tokens))
+	if err == communication.ErrCancelled || err == communication.ErrTimedOut {
+		outcome := communication.Outcome(err)
+		fmt.Fprintf(r.stdout, "Request %s after %d ms\n", outcome, time.Now().Sub(start).Milliseconds())
+		r.save(opts, request, storage.RequestResult{Dur: time.Now().Sub(start), Outcome: outcome})
+		return false
+	}
+	if err != nil {
+		fmt.Fprintf(r.stdout, "Error: %s\n", err)
+		return false
+	}
+
+	response := storage.RequestResult{
+		StatusCode:   result.Response.StatusCode,
+		Headers:      storage.ResponseHeaders(result.Response.Header),
+		ResponseBody: result.Body,
+		EncodedSize:  result.EncodedSize,
+		Dur:          result.Timing.Total,
+		Timing:       result.Timing,
+		Redirects:    result.Redirects,
+		TLS:          result.TLS,
+		Outcome:      communication.OutcomeCompleted,
+	}
+	response.Tests = storage.RunAssertions(resolved.Assertions, response)
+	response.Extracted = storage.RunExtractions(resolved.Extractions, response)
+	extracted := storage.ExtractedVariables(response.Extracted)
+	post, postErr := scripting.PostResponse(resolved, &response, storage.MergeVariables(vars, extracted))
+	r.updateVariables(func(stored map[string]string) bool {
+		for name, value := range extracted {
+			stored[name] = value
+		}
+		return post.ApplyVariables(stored) || len(extracted) > 0
+	})
+	r.save(opts, request, response)
+
+	status := result.Response.Status
+	if len(result.Redirects) > 0 {
+		status += fmt.Sprintf(" (after %d redirects)", len(result.Redirects))
+	}
+	fmt.Fprintf(r.stdout, "%s in %d ms, %s\n", status, result.Timing.Total.Milliseconds(), formatSize(len(result.Body)))
+
+	if opts.timing {
+		tw := tabwriter.NewWriter(r.stdout, 0, 4, 2, ' ', 0)
+		for _, phase := range result.Timing.Phases() {
+			fmt.Fprintf(tw, "  %s\t%.2f ms\n", phase.Name, float64(phase.Duration)/float64(time.Millisecond))
+		}
+		tw.Flush()
+	}
+	if opts.headers {
+		names := make([]string, 0, len(result.Response.Header))
+		for name := range result.Response.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range result.Response.Header[name] {
+				fmt.Fprintf(r.stdout, "%s: %s\n", name, value)
+			}
+		}
+	}
+	if opts.body && len(result.Body) > 0 {
+		fmt.Fprintln(r.stdout)
+		if communication.IsBinary(result.Response.Header.Get("Content-Type"), result.Body) {
+			fmt.Fprintf(r.stdout, "<%s of binary data>\n", formatSize(len(result.Body)))
+		} else {
+			r.stdout.Write(result.Body)
+			if result.Body[len(result.Body)-1] != '\n' {
+				fmt.Fprintln(r.stdout)
+			}
+		}
+	}
+
+	r.printConsole("post-response", post.Console)
+	if postErr != nil {
+		fmt.Fprintf(r.stdout, "Error: %s\n", postErr)
+	}
+	if len(response.Extracted) > 0 {
+		fmt.Fprintln(r.stdout, "\nExtracted:")
+		for _, e := range response.Extracted {
+			if e.Error != "" {
+				fmt.Fprintf(r.stdout, "  MISS  {{%s}} (%s)\n", e.Variable, e.Error)
+			} else {
+				fmt.Fprintf(r.stdout, "  SET   {{%s}} = %s\n", e.Variable, shorten(e.Value, 80))
+			}
+		}
+	}
+
+	// the assertions of a request decide whether it failed, the status code is only checked without them
+	passed, tests := response.TestsPassed()
+	if tests > 0 {
+		fmt.Fprintf(r.stdout, "\nTests: %d of %d passed\n", passed, tests)
+		for _, test := range response.Tests {
+			mark, detail := "PASS", ""
+			if !test.Passed {
+				mark, detail = "FAIL", fmt.Sprintf(" (actual: %s)", shorten(test.Actual, 80))
+				if test.Error != "" {
+					detail = fmt.Sprintf(" (%s)", test.Error)
+				}
+			}
+			fmt.Fprintf(r.stdout, "  %s  %s%s\n", mark, test.Name, detail)
+		}
+		return passed == tests && postErr == nil
+	}
+	return postErr == nil && (opts.failStatus <= 0 || result.Response.StatusCode < opts.failStatus)
+}
+
+// shorten cuts the text to the provided number of characters
+func shorten(text string, max int) string {
+	if runes := []rune(text); len(runes) > max {
+		return string(runes[:max]) + "…"
+	}
+	return text
+}
+
+// updateVariables persists the changes update makes to the stored variables,
+// the app and later runs see them too
+func (r *Runner) updateVariables(update func(vars map[string]string) bool) {
+	vars := r.settings.Variables()
+	if update(vars) {
+		r.settings.SetVariables(vars)
+		r.st.UpdateSetting(storage.SettingVariables, vars)
+	}
+}
+
+// printConsole prints what a script wrote to the console
+func (r *Runner) printConsole(hook string, lines []scripting.Line) {
+	for _, line := range lines {
+		fmt.Fprintf(r.stdout, "[%s] %s: %s\n", hook, line.Level, line.Message)
+	}
+}
+
+// save records the response in the history when the -save flag is set
+func (r *Runner) save(opts runOptions, request storage.RequestInput, response storage.RequestResult) {
+	if !opts.save {
+		return
+	}
+	key := []byte(time.Now().Format(storage.HistoryKeyFormat))
+	r.h.RequestCompleted(key, storage.RequestResponse{Request: request, Response: response})
+}
+
+// selectEntries picks the entries whose key equals a pattern or whose URL
+// matches it, requests that are identical to an earlier one are dropped
+func selectEntries(entries storage.HistoryList, patterns []string, method string) ([]storage.HistoryEntry, error) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	var selected []storage.HistoryEntry
+	seenKeys := map[string]bool{}
+	seenRequests := map[string]bool{}
+	for _, pattern := range patterns {
+		match, err := urlMatcher(pattern)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.Key != pattern && !match(entry.RR.Request.Path) {
+				continue
+			}
+			if method != "" && entry.RR.Request.Method != method {
+				continue
+			}
+			found = true
+			encoded, _ := json.Marshal(entry.RR.Request)
+			if seenKeys[entry.Key] || seenRequests[string(encoded)] {
+				continue
+			}
+			seenKeys[entry.Key] = true
+			seenRequests[string(encoded)] = true
+			selected = append(selected, entry)
+		}
+		if !found {
+			return nil, fmt.Errorf("no history entry matches %q", pattern)
+		}
+	}
+	return selected, nil
+}
+
+// urlMatcher returns a func matching URLs against the pattern, patterns with
+// wildcards match the whole URL and the others a part of it
+func urlMatcher(pattern string) (func(string) bool, error) {
+	if !strings.ContainsAny(pattern, "*?") {
+		return func(u string) bool {
+			return strings.Contains(u, pattern)
+		}, nil
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL pattern %q: %s", pattern, err)
+	}
+	return re.MatchString, nil
+}
+
+// formatSize returns the size in bytes in a human readable form
+func formatSize(size int) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f kB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,293 @@
+package communication
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Authorization schemes, an empty scheme sends no credentials
+const (
+	AuthNone   = ""
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthAPIKey = "apikey"
+	AuthDigest = "digest"
+	AuthOAuth2 = "oauth2"
+)
+
+// Places an API key can be sent in
+const (
+	APIKeyHeader = "header"
+	APIKeyQuery  = "query"
+)
+
+// Auth holds the credentials of a request, Send adds them to the request
+// instead of them being stored as plain headers
+type Auth struct {
+	Scheme string
+	// Username and Password are used by the basic and digest schemes, and
+	// by the password grant of the oauth2 scheme
+	Username string
+	Password string
+	Token    string
+	// KeyName and KeyValue are the API key, sent as a header or a query param depending on KeyIn
+	KeyName  string
+	KeyValue string
+	KeyIn    string
+	OAuth2   OAuth2
+}
+
+// apply adds the credentials of the preemptive schemes to the request, the
+// digest scheme answers the challenge of the server instead and the oauth2
+// token is added by Send
+func (a Auth) apply(req *http.Request) {
+	if name, value := a.Header(); name != "" {
+		req.Header.Set(name, value)
+	}
+	req.URL.RawQuery = a.Query(req.URL.RawQuery)
+}
+
+// Header returns the header sent by the basic, bearer and header API key
+// schemes, the name is empty for the other schemes
+func (a Auth) Header() (string, string) {
+	switch a.Scheme {
+	case AuthBasic:
+		credentials := base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password))
+		return "Authorization", "Basic " + credentials
+	case AuthBearer:
+		return "Authorization", "Bearer " + a.Token
+	case AuthAPIKey:
+		if a.KeyIn != APIKeyQuery {
+			return a.KeyName, a.KeyValue
+		}
+	}
+	return "", ""
+}
+
+// Query returns the raw query with the API key appended when it is sent as a query param
+func (a Auth) Query(rawQuery string) string {
+	if a.Scheme != AuthAPIKey || a.KeyIn != APIKeyQuery || a.KeyName == "" {
+		return rawQuery
+	}
+	if rawQuery != "" {
+		rawQuery += "&"
+	}
+	return rawQuery + escapeQuery(a.KeyName) + "=" + escapeQuery(a.KeyValue)
+}
+
+// digestTransport answers HTTP Digest challenges, the request is sent again
+// with the credentials when the server replies 401 with a Digest challenge
+type digestTransport struct {
+	transport http.RoundTripper
+	username  string
+	password  string
+
+	mu sync.Mutex
+	// nc counts the requests sent with each nonce
+	nc map[string]int
+}
+
+func newDigestTransport(transport http.RoundTripper, username, password string) *digestTransport {
+	return &digestTransport{
+		transport: transport,
+		username:  username,
+		password:  password,
+		nc:        make(map[string]int),
+	}
+}
+
+func (dt *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := dt.transport.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	challenge, ok := digestChallenge(res.Header)
+	if !ok || (req.Body != nil && req.GetBody == nil) {
+		return res, nil
+	}
+
+	var body []byte
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return res, nil
+		}
+		body, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return res, nil
+		}
+		retry.Body, _ = req.GetBody()
+	}
+	authorization, err := dt.authorization(challenge, req.Method, req.URL.RequestURI(), body)
+	if err != nil {
+		// unsupported challenges leave the 401 response to the caller
+		return res, nil
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	retry.Header.Set("Authorization", authorization)
+	return dt.transport.RoundTrip(retry)
+}
+
+// digestChallenge returns the parameters of the Digest challenge among the
+// WWW-Authenticate headers, a header can hold several challenges like
+// `Digest realm="a", nonce="b", Basic realm="a"`
+func digestChallenge(header http.Header) (map[string]string, bool) {
+	for _, value := range header[http.CanonicalHeaderKey("WWW-Authenticate")] {
+		// params is set while the parameters of a Digest challenge are read
+		var params map[string]string
+		for rest := value; ; {
+			rest = strings.TrimLeft(rest, " \t,")
+			if rest == "" {
+				break
+			}
+			end := strings.IndexAny(rest, " \t=,")
+			if end < 0 {
+				end = len(rest)
+			}
+			token := rest[:end]
+			rest = strings.TrimLeft(rest[end:], " \t")
+			if !strings.HasPrefix(rest, "=") {
+				// a token without a value starts the next challenge
+				if params != nil {
+					return params, true
+				}
+				if strings.EqualFold(token, "Digest") {
+					params = make(map[string]string)
+				}
+				continue
+			}
+			var paramValue string
+			paramValue, rest = authParamValue(rest[1:])
+			if params != nil {
+				params[strings.ToLower(token)] = paramValue
+			}
+		}
+		if params != nil {
+			return params, true
+		}
+	}
+	return nil, false
+}
+
+// authParamValue reads a token or quoted string value from the start of s,
+// returning it with the remainder of s
+func authParamValue(s string) (string, string) {
+	s = strings.TrimLeft(s, " \t")
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexByte(s, ',')
+		if end < 0 {
+			end = len(s)
+		}
+		return strings.TrimSpace(s[:end]), s[end:]
+	}
+	var value strings.Builder
+	idx := 1
+	for ; idx < len(s) && s[idx] != '"'; idx++ {
+		if s[idx] == '\\' && idx+1 < len(s) {
+			idx++
+		}
+		value.WriteByte(s[idx])
+	}
+	if idx < len(s) {
+		idx++
+	}
+	return value.String(), s[idx:]
+}
+
+// authorization computes the Authorization header answering the challenge (RFC 7616)
+func (dt *digestTransport) authorization(challenge map[string]string, method, uri string, body []byte) (string, error) {
+	algorithm := challenge["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	var newHash func() hash.Hash
+	sess := strings.HasSuffix(strings.ToUpper(algorithm), "-SESS")
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	case "SHA-512-256":
+		newHash = sha512.New512_256
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	h := func(data string) string {
+		sum := newHash()
+		sum.Write([]byte(data))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	qop := ""
+	for _, offered := range strings.Split(challenge["qop"], ",") {
+		offered = strings.TrimSpace(offered)
+		if offered == "auth" || (offered == "auth-int" && qop == "") {
+			qop = offered
+		}
+	}
+	if challenge["qop"] != "" && qop == "" {
+		return "", fmt.Errorf("unsupported digest qop %q", challenge["qop"])
+	}
+
+	nonce := challenge["nonce"]
+	realm := challenge["realm"]
+	var cnonceBytes [16]byte
+	rand.Read(cnonceBytes[:])
+	cnonce := hex.EncodeToString(cnonceBytes[:])
+
+	dt.mu.Lock()
+	dt.nc[nonce]++
+	nc := fmt.Sprintf("%08x", dt.nc[nonce])
+	dt.mu.Unlock()
+
+	ha1 := h(dt.username + ":" + realm + ":" + dt.password)
+	if sess {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	if qop == "auth-int" {
+		ha2 = h(method + ":" + uri + ":" + h(string(body)))
+	}
+
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	fields := []string{
+		"username=" + quoteParam(dt.username),
+		"realm=" + quoteParam(realm),
+		"nonce=" + quoteParam(nonce),
+		"uri=" + quoteParam(uri),
+		"algorithm=" + algorithm,
+		"response=" + quoteParam(response),
+	}
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+nc, "cnonce="+quoteParam(cnonce))
+	}
+	if opaque, ok := challenge["opaque"]; ok {
+		fields = append(fields, "opaque="+quoteParam(opaque))
+	}
+	return "Digest " + strings.Join(fields, ", "), nil
+}
+
+// quoteParam returns the value as a quoted string of an auth parameter
+func quoteParam(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,119 @@
+package communication
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Body modes a request body can be built from
+const (
+	BodyNone      = "none"
+	BodyRaw       = "raw"
+	BodyForm      = "form"
+	BodyMultipart = "multipart"
+	BodyBinary    = "binary"
+)
+
+// Raw body types and the content type each one is sent with
+const (
+	RawText = "text"
+	RawJSON = "json"
+	RawXML  = "xml"
+)
+
+var rawContentTypes = map[string]string{
+	RawText: "text/plain; charset=utf-8",
+	RawJSON: "application/json",
+	RawXML:  "application/xml",
+}
+
+// FormPart is a single field of an urlencoded or multipart form, the value
+// of a file part is the path of the file to upload
+type FormPart struct {
+	Key      string
+	Value    string
+	File     bool
+	Disabled bool
+}
+
+// BodySpec describes how the body of a request is built
+type BodySpec struct {
+	Mode    string
+	Raw     string
+	RawType string
+	Parts   []FormPart
+	File    string
+}
+
+// Build returns the encoded body and the content type it should be sent
+// with, an empty mode is treated as a raw body without a content type
+func (b BodySpec) Build() ([]byte, string, error) {
+	switch b.Mode {
+	case BodyNone:
+		return nil, "", nil
+	case "":
+		return []byte(b.Raw), "", nil
+	case BodyRaw:
+		return []byte(b.Raw), rawContentTypes[b.RawType], nil
+	case BodyForm:
+		// url.Values.Encode sorts the keys, keep the order the fields were entered in
+		var pairs []string
+		for _, p := range b.Parts {
+			if !p.Disabled {
+				pairs = append(pairs, url.QueryEscape(p.Key)+"="+url.QueryEscape(p.Value))
+			}
+		}
+		return []byte(strings.Join(pairs, "&")), "application/x-www-form-urlencoded", nil
+	case BodyMultipart:
+		return buildMultipart(b.Parts)
+	case BodyBinary:
+		if b.File == "" {
+			return nil, "", fmt.Errorf("no file selected for the binary body")
+		}
+		data, err := ioutil.ReadFile(b.File)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, "application/octet-stream", nil
+	}
+	return nil, "", fmt.Errorf("unknown body mode %q", b.Mode)
+}
+
+func buildMultipart(parts []FormPart) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		if p.Disabled {
+			continue
+		}
+		if !p.File {
+			if err := w.WriteField(p.Key, p.Value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		f, err := os.Open(p.Value)
+		if err != nil {
+			return nil, "", err
+		}
+		fw, err := w.CreateFormFile(p.Key, filepath.Base(p.Value))
+		if err == nil {
+			_, err = io.Copy(fw, f)
+		}
+		f.Close()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
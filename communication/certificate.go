@@ -0,0 +1,108 @@
+package communication
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// CertificateInfo is a serializable summary of a x509 certificate
+type CertificateInfo struct {
+	Subject            string
+	Issuer             string
+	SANs               []string
+	SerialNumber       string
+	NotBefore          time.Time
+	NotAfter           time.Time
+	KeyAlgorithm       string
+	SignatureAlgorithm string
+}
+
+// Expired reports whether the certificate is not valid at the provided time
+func (ci CertificateInfo) Expired(at time.Time) bool {
+	return at.After(ci.NotAfter) || at.Before(ci.NotBefore)
+}
+
+// ExpiresWithin reports whether the certificate expires in less than d from the provided time
+func (ci CertificateInfo) ExpiresWithin(at time.Time, d time.Duration) bool {
+	return ci.NotAfter.Sub(at) < d
+}
+
+// TLSInfo holds the negotiated TLS connection parameters and the certificate
+// chain presented by the server
+type TLSInfo struct {
+	Version      string
+	CipherSuite  string
+	ALPN         string
+	ServerName   string
+	Certificates []CertificateInfo
+}
+
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+
+	version, ok := tlsVersionNames[state.Version]
+	if !ok {
+		version = fmt.Sprintf("0x%04x", state.Version)
+	}
+
+	info := &TLSInfo{
+		Version:     version,
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  state.ServerName,
+	}
+	for _, cert := range state.PeerCertificates {
+		info.Certificates = append(info.Certificates, newCertificateInfo(cert))
+	}
+
+	return info
+}
+
+func newCertificateInfo(cert *x509.Certificate) CertificateInfo {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	return CertificateInfo{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SANs:               sans,
+		SerialNumber:       cert.SerialNumber.String(),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		KeyAlgorithm:       keyAlgorithm(cert),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+	}
+}
+
+func keyAlgorithm(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d bits", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
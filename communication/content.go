@@ -0,0 +1,84 @@
+package communication
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// sniffLen is how much of a body is looked at to tell text from binary
+const sniffLen = 8192
+
+// MediaType returns the lower cased media type of a Content-Type value, without its parameters
+func MediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	if idx := strings.Index(contentType, ";"); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// IsImage reports whether the content type is an image that can be previewed
+func IsImage(contentType string) bool {
+	mt := MediaType(contentType)
+	return strings.HasPrefix(mt, "image/") && mt != "image/svg+xml"
+}
+
+// IsBinary reports whether a body can't be displayed as text, the content
+// type decides when it is known and the bytes are sniffed otherwise
+func IsBinary(contentType string, body []byte) bool {
+	mt := MediaType(contentType)
+	if mt == "" || mt == "application/octet-stream" {
+		mt = MediaType(http.DetectContentType(body))
+	}
+
+	switch {
+	case isTextual(mt):
+		return false
+	case strings.HasPrefix(mt, "image/"),
+		strings.HasPrefix(mt, "audio/"),
+		strings.HasPrefix(mt, "video/"),
+		strings.HasPrefix(mt, "font/"),
+		mt == "application/pdf",
+		mt == "application/zip",
+		mt == "application/x-gzip",
+		mt == "application/gzip",
+		mt == "application/wasm",
+		strings.Contains(mt, "protobuf"),
+		strings.Contains(mt, "msgpack"),
+		strings.HasPrefix(mt, "application/grpc"):
+		return true
+	}
+	return looksBinary(body)
+}
+
+func isTextual(mt string) bool {
+	if strings.HasPrefix(mt, "text/") {
+		return true
+	}
+	for _, suffix := range []string{"json", "xml", "javascript", "ecmascript", "yaml", "x-www-form-urlencoded", "graphql", "csv"} {
+		if strings.HasSuffix(mt, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// looksBinary treats bodies holding NUL bytes or many control characters as binary
+func looksBinary(body []byte) bool {
+	if len(body) > sniffLen {
+		body = body[:sniffLen]
+	}
+	control := 0
+	for _, b := range body {
+		switch {
+		case b == 0:
+			return true
+		case b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' && b != 0x1b:
+			control++
+		}
+	}
+	return len(body) > 0 && control*10 > len(body)
+}
@@ -0,0 +1,120 @@
+package communication
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// AcceptEncoding is sent when the request does not set its own Accept-Encoding
+const AcceptEncoding = "gzip, deflate, br"
+
+// Charsets lists the encodings offered as a manual override of the response charset
+var Charsets = []string{
+	"UTF-8",
+	"UTF-16LE",
+	"UTF-16BE",
+	"ISO-8859-1",
+	"ISO-8859-2",
+	"ISO-8859-15",
+	"Windows-1250",
+	"Windows-1251",
+	"Windows-1252",
+	"KOI8-R",
+	"Shift_JIS",
+	"EUC-JP",
+	"ISO-2022-JP",
+	"EUC-KR",
+	"GBK",
+	"GB18030",
+	"Big5",
+}
+
+// decodeContent undoes the content codings listed in a Content-Encoding header,
+// they are removed in the reverse order of being applied
+func decodeContent(contentEncoding string, data []byte) ([]byte, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+		var r io.Reader
+		switch coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			gr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			r = gr
+		case "deflate":
+			// deflate is meant to be zlib wrapped but some servers send raw deflate
+			if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+				r = zr
+			} else {
+				r = flate.NewReader(bytes.NewReader(data))
+			}
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(data))
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %q", coding)
+		}
+
+		decoded, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode %s content: %s", coding, err)
+		}
+		data = decoded
+	}
+	return data, nil
+}
+
+// Charset returns the charset parameter of a Content-Type value
+func Charset(contentType string) string {
+	for _, param := range strings.Split(contentType, ";")[1:] {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], "charset") {
+			return strings.Trim(kv[1], `"' `)
+		}
+	}
+	return ""
+}
+
+// DecodeText converts a body in the named charset to UTF-8, a byte order
+// mark takes precedence and bodies without a charset are taken as UTF-8
+func DecodeText(body []byte, charset string) (string, error) {
+	var enc encoding.Encoding
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return string(body[3:]), nil
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}), bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	case charset == "" || strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "utf8"):
+		return string(body), nil
+	default:
+		var err error
+		if enc, err = htmlindex.Get(charset); err != nil {
+			return string(body), fmt.Errorf("unknown charset %q", charset)
+		}
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return string(body), err
+	}
+	if !utf8.Valid(decoded) {
+		return string(body), fmt.Errorf("the body is not valid %s", charset)
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,480 @@
+package communication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth 2.0 grants a token can be obtained with
+const (
+	OAuth2ClientCredentials = "client_credentials"
+	OAuth2Password          = "password"
+	OAuth2AuthorizationCode = "authorization_code"
+)
+
+// DefaultRedirectURL is the redirect URI of the authorization code grant when
+// none is configured, the listener picks a free port
+const DefaultRedirectURL = "http://127.0.0.1/callback"
+
+// TokenExpiryLeeway is how long before their expiry tokens get refreshed
+const TokenExpiryLeeway = 30 * time.Second
+
+// AuthorizationTimeout is how long the authorization code grant waits for the browser
+const AuthorizationTimeout = 5 * time.Minute
+
+// OAuth2 configures how the token of the oauth2 scheme is obtained, the
+// password grant uses the Username and Password of the Auth
+type OAuth2 struct {
+	Grant string
+	// AuthURL is the authorization endpoint of the authorization code grant
+	AuthURL      string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+	// RedirectURL is a loopback URL the browser gets redirected to, a
+	// missing port picks a free one
+	RedirectURL string
+	// CredentialsInBody sends the client credentials as form fields instead of Basic auth
+	CredentialsInBody bool
+}
+
+// Token is an OAuth 2.0 access token, a zero Expiry never expires
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Expiry       time.Time
+	Scope        string
+}
+
+// Valid reports whether the token can still be used at the provided time
+func (t Token) Valid(now time.Time) bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(TokenExpiryLeeway).Before(t.Expiry))
+}
+
+// authorization returns the Authorization header value of the token
+func (t Token) authorization() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer " + t.AccessToken
+	}
+	return t.TokenType + " " + t.AccessToken
+}
+
+// TokenCache keeps the OAuth 2.0 tokens in memory, they are refreshed or
+// obtained again once they expire
+type TokenCache struct {
+	// open shows the authorization page of the authorization code grant
+	open func(authURL string) error
+
+	// mu guards the maps, it isn't held while a token is obtained
+	mu     sync.Mutex
+	tokens map[string]Token
+	// pending holds a channel for each configuration whose token is being
+	// obtained, it is closed once done so a single browser prompt is shown
+	pending map[string]chan struct{}
+}
+
+// NewTokenCache returns an empty cache, open shows the authorization page of
+// the authorization code grant to the user
+func NewTokenCache(open func(authURL string) error) *TokenCache {
+	return &TokenCache{
+		open:    open,
+		tokens:  make(map[string]Token),
+		pending: make(map[string]chan struct{}),
+	}
+}
+
+// tokenKey identifies the tokens obtained with the same configuration, the
+// secrets are hashed so changing them obtains a new token
+func tokenKey(auth Auth) string {
+	cfg := auth.OAuth2
+	secrets := sha256.Sum256([]byte(cfg.ClientSecret + "\n" + auth.Password))
+	return strings.Join([]string{cfg.Grant, cfg.AuthURL, cfg.TokenURL, cfg.ClientID, cfg.Scope, auth.Username, hex.EncodeToString(secrets[:])}, "\n")
+}
+
+// Cached returns the token stored for the configuration, valid or not
+func (tc *TokenCache) Cached(auth Auth) (Token, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	token, ok := tc.tokens[tokenKey(auth)]
+	return token, ok
+}
+
+// Forget drops the token stored for the configuration
+func (tc *TokenCache) Forget(auth Auth) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	delete(tc.tokens, tokenKey(auth))
+}
+
+// Token returns a valid token for the configuration, an expired one is
+// refreshed and a missing one is obtained with the grant. opts configure the
+// connection to the token endpoint.
+func (tc *TokenCache) Token(ctx context.Context, auth Auth, opts Options) (Token, error) {
+	key := tokenKey(auth)
+	cached, done, err := tc.begin(ctx, key, true)
+	if err != nil || done == nil {
+		return cached, err
+	}
+	defer done()
+
+	if cached.RefreshToken != "" {
+		token, err := refreshToken(ctx, auth.OAuth2, cached.RefreshToken, opts)
+		if err == nil {
+			tc.store(key, token)
+			return token, nil
+		}
+		if ctx.Err() != nil {
+			return Token{}, err
+		}
+		// a rejected refresh token falls back to the grant
+	}
+	token, err := tc.obtain(ctx, auth, opts)
+	if err != nil {
+		return Token{}, err
+	}
+	tc.store(key, token)
+	return token, nil
+}
+
+// Renew obtains a new token with the grant, ignoring the cached one
+func (tc *TokenCache) Renew(ctx context.Context, auth Auth, opts Options) (Token, error) {
+	key := tokenKey(auth)
+	_, done, err := tc.begin(ctx, key, false)
+	if err != nil {
+		return Token{}, err
+	}
+	defer done()
+
+	token, err := tc.obtain(ctx, auth, opts)
+	if err != nil {
+		return Token{}, err
+	}
+	tc.store(key, token)
+	return token, nil
+}
+
+// begin waits until no other token of the key is being obtained. It returns
+// the valid cached token with a nil done when useCached is set, otherwise
+// the cached token, valid or not, and done ends the exchange of the caller.
+func (tc *TokenCache) begin(ctx context.Context, key string, useCached bool) (Token, func(), error) {
+	for {
+		tc.mu.Lock()
+		cached := tc.tokens[key]
+		if useCached && cached.Valid(time.Now()) {
+			tc.mu.Unlock()
+			return cached, nil, nil
+		}
+		wait, busy := tc.pending[key]
+		if !busy {
+			finished := make(chan struct{})
+			tc.pending[key] = finished
+			tc.mu.Unlock()
+			return cached, func() {
+				tc.mu.Lock()
+				delete(tc.pending, key)
+				tc.mu.Unlock()
+				close(finished)
+			}, nil
+		}
+		tc.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return Token{}, nil, ErrCancelled
+		}
+	}
+}
+
+func (tc *TokenCache) store(key string, token Token) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.tokens[key] = token
+}
+
+func (tc *TokenCache) obtain(ctx context.Context, auth Auth, opts Options) (Token, error) {
+	cfg := auth.OAuth2
+	switch cfg.Grant {
+	case OAuth2ClientCredentials:
+		return requestToken(ctx, cfg, url.Values{"grant_type": {OAuth2ClientCredentials}}, opts)
+	case OAuth2Password:
+		return requestToken(ctx, cfg, url.Values{
+			"grant_type": {OAuth2Password},
+			"username":   {auth.Username},
+			"password":   {auth.Password},
+		}, opts)
+	case OAuth2AuthorizationCode:
+		if tc.open == nil {
+			return Token{}, errors.New("the authorization code grant needs a browser")
+		}
+		return authorizeLoopback(ctx, cfg, tc.open, opts)
+	}
+	return Token{}, fmt.Errorf("unknown OAuth 2.0 grant %q", cfg.Grant)
+}
+
+func refreshToken(ctx context.Context, cfg OAuth2, refresh string, opts Options) (Token, error) {
+	token, err := requestToken(ctx, cfg, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refresh},
+	}, opts)
+	if err == nil && token.RefreshToken == "" {
+		// the refresh token is kept when the server doesn't rotate it
+		token.RefreshToken = refresh
+	}
+	return token, err
+}
+
+// requestToken posts the grant to the token endpoint (RFC 6749 section 4)
+func requestToken(ctx context.Context, cfg OAuth2, form url.Values, opts Options) (Token, error) {
+	if cfg.TokenURL == "" {
+		return Token{}, errors.New("the token URL is missing")
+	}
+	if cfg.Scope != "" && form.Get("grant_type") != OAuth2AuthorizationCode {
+		form.Set("scope", cfg.Scope)
+	}
+
+	// the token endpoint gets the transport settings of the request, without its cookies
+	tokenOpts := Options{
+		Timeouts: opts.Timeouts,
+		TLS:      opts.TLS,
+		Proxy:    opts.Proxy,
+	}
+	if cfg.CredentialsInBody || cfg.ClientSecret == "" {
+		form.Set("client_id", cfg.ClientID)
+		if cfg.ClientSecret != "" {
+			form.Set("client_secret", cfg.ClientSecret)
+		}
+	} else {
+		// the credentials are form encoded before being sent as Basic auth (RFC 6749 section 2.3.1)
+		tokenOpts.Auth = Auth{
+			Scheme:   AuthBasic,
+			Username: url.QueryEscape(cfg.ClientID),
+			Password: url.QueryEscape(cfg.ClientSecret),
+		}
+	}
+
+	headers := map[string][]string{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Accept":       {"application/json"},
+	}
+	result, err := Send(ctx, cfg.TokenURL, http.MethodPost, headers, []byte(form.Encode()), tokenOpts)
+	if err != nil {
+		return Token{}, err
+	}
+	return parseTokenResponse(result.Response, result.Body)
+}
+
+// parseTokenResponse reads the token or the error returned by the token
+// endpoint, a few providers reply form encoded instead of JSON
+func parseTokenResponse(res *http.Response, body []byte) (Token, error) {
+	values := map[string]interface{}{}
+	if MediaType(res.Header.Get("Content-Type")) == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(body))
+		if err == nil {
+			for name := range form {
+				values[name] = form.Get(name)
+			}
+		}
+	} else if err := json.Unmarshal(body, &values); err != nil && res.StatusCode < 300 {
+		return Token{}, fmt.Errorf("the token endpoint replied with invalid JSON: %s", err)
+	}
+	text := func(name string) string {
+		switch value := values[name].(type) {
+		case string:
+			return value
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		return ""
+	}
+
+	if code := text("error"); code != "" {
+		if description := text("error_description"); description != "" {
+			return Token{}, fmt.Errorf("the token endpoint replied %s: %s", code, description)
+		}
+		return Token{}, fmt.Errorf("the token endpoint replied %s", code)
+	}
+	if res.StatusCode >= 300 {
+		return Token{}, fmt.Errorf("the token endpoint replied %s", res.Status)
+	}
+	token := Token{
+		AccessToken:  text("access_token"),
+		TokenType:    text("token_type"),
+		RefreshToken: text("refresh_token"),
+		Scope:        text("scope"),
+	}
+	if token.AccessToken == "" {
+		return Token{}, errors.New("the token endpoint replied without an access_token")
+	}
+	if expiresIn, err := strconv.ParseFloat(text("expires_in"), 64); err == nil && expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn * float64(time.Second)))
+	}
+	return token, nil
+}
+
+// authorizeLoopback runs the authorization code grant with PKCE (RFC 7636),
+// the code is received by a listener on the loopback redirect URI (RFC 8252)
+func authorizeLoopback(ctx context.Context, cfg OAuth2, open func(string) error, opts Options) (Token, error) {
+	if cfg.AuthURL == "" {
+		return Token{}, errors.New("the authorization URL is missing")
+	}
+	authURL, err := url.Parse(cfg.AuthURL)
+	if err != nil {
+		return Token{}, fmt.Errorf("invalid authorization URL: %s", err)
+	}
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = DefaultRedirectURL
+	}
+	redirect, err := url.Parse(cfg.RedirectURL)
+	if err != nil {
+		return Token{}, fmt.Errorf("invalid redirect URL: %s", err)
+	}
+	if redirect.Scheme != "http" || !isLoopback(redirect.Hostname()) {
+		return Token{}, errors.New("the redirect URL has to be a http:// URL on 127.0.0.1, [::1] or localhost")
+	}
+	if redirect.Path == "" {
+		redirect.Path = "/"
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(redirect.Hostname(), redirect.Port()))
+	if err != nil {
+		return Token{}, fmt.Errorf("unable to listen on the redirect URL: %s", err)
+	}
+	if redirect.Port() == "" || redirect.Port() == "0" {
+		port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+		redirect.Host = net.JoinHostPort(redirect.Hostname(), port)
+	}
+
+	verifier := randomString(32)
+	state := randomString(16)
+	challenge := sha256.Sum256([]byte(verifier))
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", cfg.ClientID)
+	query.Set("redirect_uri", redirect.String())
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if cfg.Scope != "" {
+		query.Set("scope", cfg.Scope)
+	}
+	authURL.RawQuery = query.Encode()
+
+	type callback struct {
+		code string
+		err  error
+	}
+	received := make(chan callback, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != redirect.Path {
+			http.NotFound(w, r)
+			return
+		}
+		params := r.URL.Query()
+		if params.Get("state") != state {
+			// callbacks that don't belong to this authorization keep it waiting
+			http.Error(w, "Unknown authorization state", http.StatusBadRequest)
+			return
+		}
+		var result callback
+		switch {
+		case params.Get("error") != "":
+			result.err = fmt.Errorf("the authorization server replied %s", params.Get("error"))
+			if description := params.Get("error_description"); description != "" {
+				result.err = fmt.Errorf("%s: %s", result.err, description)
+			}
+		case params.Get("code") == "":
+			result.err = errors.New("the authorization server replied without a code")
+		default:
+			result.code = params.Get("code")
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if result.err != nil {
+			fmt.Fprintf(w, "<html><body><h3>Authorization failed</h3><p>%s</p></body></html>", html.EscapeString(result.err.Error()))
+		} else {
+			fmt.Fprint(w, "<html><body><h3>Authorization complete</h3><p>You can close this window and return to Probster.</p></body></html>")
+		}
+		select {
+		case received <- result:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	if err := open(authURL.String()); err != nil {
+		return Token{}, fmt.Errorf("unable to open the authorization page: %s", err)
+	}
+
+	timer := time.NewTimer(AuthorizationTimeout)
+	defer timer.Stop()
+	var result callback
+	select {
+	case result = <-received:
+	case <-timer.C:
+		return Token{}, fmt.Errorf("no authorization received within %s", AuthorizationTimeout)
+	case <-ctx.Done():
+		return Token{}, ErrCancelled
+	}
+	if result.err != nil {
+		return Token{}, result.err
+	}
+
+	return requestToken(ctx, cfg, url.Values{
+		"grant_type":    {OAuth2AuthorizationCode},
+		"code":          {result.code},
+		"redirect_uri":  {redirect.String()},
+		"code_verifier": {verifier},
+	}, opts)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// randomString returns n random bytes encoded as unpadded base64url
+func randomString(n int) string {
+	data := make([]byte, n)
+	rand.Read(data)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// OpenBrowser opens the URL in the default browser of the system
+func OpenBrowser(rawURL string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", rawURL)
+	case "darwin":
+		cmd = exec.Command("open", rawURL)
+	default:
+		cmd = exec.Command("xdg-open", rawURL)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
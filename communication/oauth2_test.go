@@ -0,0 +1,469 @@
+package communication
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tokenServer is a stand-in authorization server, it issues the tokens t1, t2,
+// ... and a protected /api endpoint that echoes the Authorization header
+type tokenServer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu sync.Mutex
+	// expiresIn is sent with the tokens, 0 leaves expires_in out
+	expiresIn int
+	issued    int
+	grants    []string
+	// forms holds the form of every token request
+	forms []url.Values
+	// basic holds the Basic auth credentials of every token request
+	basic [][2]string
+	// challenges maps the issued codes to their PKCE challenge
+	challenges map[string]string
+	redirects  map[string]string
+	// revoked tokens are answered with 401 by /api
+	revoked map[string]bool
+}
+
+func newTokenServer(t *testing.T) *tokenServer {
+	ts := &tokenServer{
+		t:          t,
+		expiresIn:  3600,
+		challenges: make(map[string]string),
+		redirects:  make(map[string]string),
+		revoked:    make(map[string]bool),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", ts.authorize)
+	mux.HandleFunc("/token", ts.token)
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		if ts.revoked[r.Header.Get("Authorization")] {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	})
+	ts.Server = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// authorize approves every request, redirecting back with a code bound to the challenge
+func (ts *tokenServer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	ts.mu.Lock()
+	code := fmt.Sprintf("code%d", len(ts.challenges)+1)
+	ts.challenges[code] = query.Get("code_challenge")
+	ts.redirects[code] = query.Get("redirect_uri")
+	ts.mu.Unlock()
+
+	redirect := query.Get("redirect_uri") + "?code=" + code + "&state=" + url.QueryEscape(query.Get("state"))
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (ts *tokenServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ts.t.Errorf("invalid token request: %v", err)
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	user, pass, _ := r.BasicAuth()
+	ts.forms = append(ts.forms, r.PostForm)
+	ts.basic = append(ts.basic, [2]string{user, pass})
+	grant := r.PostForm.Get("grant_type")
+	ts.grants = append(ts.grants, grant)
+
+	fail := func(code, description string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q, "error_description": %q}`, code, description)
+	}
+	switch grant {
+	case OAuth2Password:
+		if r.PostForm.Get("password") != "hunter2" {
+			fail("invalid_grant", "wrong password")
+			return
+		}
+	case OAuth2AuthorizationCode:
+		code := r.PostForm.Get("code")
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if challenge, ok := ts.challenges[code]; !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			fail("invalid_grant", "the code verifier doesn't match the challenge")
+			return
+		}
+		if r.PostForm.Get("redirect_uri") != ts.redirects[code] {
+			fail("invalid_grant", "the redirect URI doesn't match")
+			return
+		}
+		delete(ts.challenges, code)
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") != "refresh" {
+			fail("invalid_grant", "unknown refresh token")
+			return
+		}
+	}
+
+	ts.issued++
+	token := fmt.Sprintf(`{"access_token": "t%d", "token_type": "bearer", "refresh_token": "refresh"`, ts.issued)
+	if grant == "refresh_token" {
+		// the refresh token isn't rotated
+		token = fmt.Sprintf(`{"access_token": "t%d", "token_type": "bearer"`, ts.issued)
+	}
+	if ts.expiresIn > 0 {
+		token += fmt.Sprintf(`, "expires_in": %d`, ts.expiresIn)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, token+"}")
+}
+
+func (ts *tokenServer) revoke(authorization string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.revoked[authorization] = true
+}
+
+func (ts *tokenServer) grantLog() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.grants...)
+}
+
+// send requests /api with the auth and returns the Authorization header it received
+func (ts *tokenServer) send(t *testing.T, tokens *TokenCache, auth Auth) (int, string) {
+	t.Helper()
+	result, err := Send(context.Background(), ts.URL+"/api", http.MethodGet, nil, nil, Options{Auth: auth, Tokens: tokens})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	return result.Response.StatusCode, string(result.Body)
+}
+
+// browser follows the authorization page the way a browser would
+func browser(t *testing.T) func(string) error {
+	return func(authURL string) error {
+		go func() {
+			res, err := http.Get(authURL)
+			if err != nil {
+				t.Errorf("browser: %v", err)
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("browser: the callback replied %s", res.Status)
+			}
+		}()
+		return nil
+	}
+}
+
+func TestTokenCacheGrants(t *testing.T) {
+	ts := newTokenServer(t)
+	tests := []struct {
+		name       string
+		auth       Auth
+		wantForm   url.Values
+		wantBasic  [2]string
+		wantHeader string
+	}{
+		{
+			name: "client credentials with Basic auth",
+			auth: Auth{Scheme: AuthOAuth2, OAuth2: OAuth2{
+				Grant:        OAuth2ClientCredentials,
+				TokenURL:     ts.URL + "/token",
+				ClientID:     "my client",
+				ClientSecret: "s&cret",
+				Scope:        "read write",
+			}},
+			wantForm:   url.Values{"grant_type": {"client_credentials"}, "scope": {"read write"}},
+			wantBasic:  [2]string{"my+client", "s%26cret"},
+			wantHeader: "Bearer t1",
+		},
+		{
+			name: "client credentials in the body",
+			auth: Auth{Scheme: AuthOAuth2, OAuth2: OAuth2{
+				Grant:             OAuth2ClientCredentials,
+				TokenURL:          ts.URL + "/token",
+				ClientID:          "client",
+				ClientSecret:      "secret",
+				CredentialsInBody: true,
+			}},
+			wantForm:   url.Values{"grant_type": {"client_credentials"}, "client_id": {"client"}, "client_secret": {"secret"}},
+			wantHeader: "Bearer t2",
+		},
+		{
+			name: "password",
+			auth: Auth{Scheme: AuthOAuth2, Username: "bob", Password: "hunter2", OAuth2: OAuth2{
+				Grant:    OAuth2Password,
+				TokenURL: ts.URL + "/token",
+				ClientID: "public",
+			}},
+			wantForm:   url.Values{"grant_type": {"password"}, "username": {"bob"}, "password": {"hunter2"}, "client_id": {"public"}},
+			wantHeader: "Bearer t3",
+		},
+	}
+	tokens := NewTokenCache(nil)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := ts.send(t, tokens, tt.auth); got != tt.wantHeader {
+				t.Errorf("Authorization = %q, want %q", got, tt.wantHeader)
+			}
+			// the second request uses the cached token
+			if _, got := ts.send(t, tokens, tt.auth); got != tt.wantHeader {
+				t.Errorf("cached Authorization = %q, want %q", got, tt.wantHeader)
+			}
+			ts.mu.Lock()
+			defer ts.mu.Unlock()
+			if len(ts.forms) != i+1 {
+				t.Fatalf("%d token requests, want %d", len(ts.forms), i+1)
+			}
+			if got := ts.forms[i].Encode(); got != tt.wantForm.Encode() {
+				t.Errorf("token request form = %s, want %s", got, tt.wantForm.Encode())
+			}
+			if got := ts.basic[i]; got != tt.wantBasic {
+				t.Errorf("token request Basic auth = %v, want %v", got, tt.wantBasic)
+			}
+		})
+	}
+}
+
+func TestTokenCacheErrors(t *testing.T) {
+	ts := newTokenServer(t)
+	tests := []struct {
+		name    string
+		auth    Auth
+		open    func(string) error
+		wantErr string
+	}{
+		{
+			name:    "error replied by the token endpoint",
+			auth:    Auth{Scheme: AuthOAuth2, Username: "bob", Password: "wrong", OAuth2: OAuth2{Grant: OAuth2Password, TokenURL: ts.URL + "/token"}},
+			wantErr: "invalid_grant: wrong password",
+		},
+		{
+			name:    "missing token URL",
+			auth:    Auth{Scheme: AuthOAuth2, OAuth2: OAuth2{Grant: OAuth2ClientCredentials}},
+			wantErr: "the token URL is missing",
+		},
+		{
+			name:    "authorization code without a browser",
+			auth:    Auth{Scheme: AuthOAuth2, OAuth2: OAuth2{Grant: OAuth2AuthorizationCode, AuthURL: ts.URL + "/authorize", TokenURL: ts.URL + "/token"}},
+			wantErr: "needs a browser",
+		},
+		{
+			name:    "redirect URL that isn't a loopback address",
+			auth:    Auth{Scheme: AuthOAuth2, OAuth2: OAuth2{Grant: OAuth2AuthorizationCode, AuthURL: ts.URL + "/authorize", TokenURL: ts.URL + "/token", RedirectURL: "https://example.com/callback"}},
+			open:    browser(t),
+			wantErr: "has to be a http:// URL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Send(context.Background(), ts.URL+"/api", http.MethodGet, nil, nil, Options{Auth: tt.auth, Tokens: NewTokenCache(tt.open)})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Send() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenCacheRefreshBeforeExpiry(t *testing.T) {
+	ts := newTokenServer(t)
+	// the token expires within TokenExpiryLeeway, so it is refreshed by the next request
+	ts.expiresIn = int(TokenExpiryLeeway/time.Second) - 10
+	auth := Auth{Scheme: AuthOAuth2, OAuth2: OAuth2{Grant: OAuth2ClientCredentials, TokenURL: ts.URL + "/token", ClientID: "client", ClientSecret: "secret"}}
+	tokens := NewTokenCache(nil)
+
+	for _, want := range []string{"Bearer t1", "Bearer t2", "Bearer t3"} {
+		if _, got := ts.send(t, tokens, auth); got != want {
+			t.Errorf("Authorization = %q, want %q", got, want)
+		}
+	}
+	want := []string{"client_credentials", "refresh_token", "refresh_token"}
+	if got := ts.grantLog(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("grants = %v, want %v", got, want)
+	}
+	// the refresh token is kept when the server doesn't rotate it
+	if token, _ := tokens.Cached(auth); token.RefreshToken != "refresh" {
+		t.Errorf("refresh token = %q, want %q", token.RefreshToken, "refresh")
+	}
+}
+
+func TestTokenCacheUnauthorized(t *testing.T) {
+	ts := newTokenServer(t)
+	auth := Auth{Scheme: AuthOAuth2, OAuth2: OAuth2{Grant: OAuth2ClientCredentials, TokenURL: ts.URL + "/token", ClientID: "client", ClientSecret: "secret"}}
+	tokens := NewTokenCache(nil)
+
+	if _, got := ts.send(t, tokens, auth); got != "Bearer t1" {
+		t.Fatalf("Authorization = %q, want %q", got, "Bearer t1")
+	}
+	ts.revoke("Bearer t1")
+	if status, _ := ts.send(t, tokens, auth); status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", status)
+	}
+	if _, ok := tokens.Cached(auth); ok {
+		t.Error("the rejected token is still cached")
+	}
+	if status, got := ts.send(t, tokens, auth); status != http.StatusOK || got != "Bearer t2" {
+		t.Errorf("after the 401 got %d %q, want 200 %q", status, got, "Bearer t2")
+	}
+}
+
+func TestTokenCacheChangedSecrets(t *testing.T) {
+	ts := newTokenServer(t)
+	auth := Auth{Scheme: AuthOAuth2, Username: "bob", Password: "hunter2", OAuth2: OAuth2{Grant: OAuth2Password, TokenURL: ts.URL + "/token", ClientID: "client", ClientSecret: "old"}}
+	tokens := NewTokenCache(nil)
+	ts.send(t, tokens, auth)
+
+	changed := auth
+	changed.OAuth2.ClientSecret = "new"
+	if _, got := ts.send(t, tokens, changed); got != "Bearer t2" {
+		t.Errorf("Authorization with a changed secret = %q, want a new token", got)
+	}
+	if _, ok := tokens.Cached(changed); !ok {
+		t.Error("the token of the changed secret isn't cached")
+	}
+	wrong := auth
+	wrong.Password = "wrong"
+	if _, err := Send(context.Background(), ts.URL+"/api", http.MethodGet, nil, nil, Options{Auth: wrong, Tokens: tokens}); err == nil {
+		t.Error("a changed password reused the cached token")
+	}
+}
+
+func TestTokenCacheAuthorizationCode(t *testing.T) {
+	ts := newTokenServer(t)
+	auth := Auth{Scheme: AuthOAuth2, OAuth2: OAuth2{
+		Grant:    OAuth2AuthorizationCode,
+		AuthURL:  ts.URL + "/authorize?audience=api",
+		TokenURL: ts.URL + "/token",
+		ClientID: "app",
+		Scope:    "openid",
+	}}
+
+	var authURL *url.URL
+	open := func(page string) error {
+		var err error
+		if authURL, err = url.Parse(page); err != nil {
+			return err
+		}
+		query := authURL.Query()
+		if query.Get("audience") != "api" || query.Get("client_id") != "app" || query.Get("scope") != "openid" {
+			t.Errorf("authorization URL = %s", page)
+		}
+		// a stray callback with another state is ignored
+		res, err := http.Get(query.Get("redirect_uri") + "?code=stolen&state=other")
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("stray callback replied %s, want 400", res.Status)
+		}
+		return browser(t)(page)
+	}
+	tokens := NewTokenCache(open)
+
+	if _, got := ts.send(t, tokens, auth); got != "Bearer t1" {
+		t.Fatalf("Authorization = %q, want %q", got, "Bearer t1")
+	}
+	if authURL == nil {
+		t.Fatal("the authorization page wasn't opened")
+	}
+	redirect, err := url.Parse(authURL.Query().Get("redirect_uri"))
+	if err != nil || redirect.Hostname() != "127.0.0.1" || redirect.Port() == "" || redirect.Path != "/callback" {
+		t.Errorf("redirect URI = %q, want the default loopback URL on a free port", authURL.Query().Get("redirect_uri"))
+	}
+
+	ts.mu.Lock()
+	form := ts.forms[0]
+	ts.mu.Unlock()
+	if form.Get("code_verifier") == "" || form.Get("code") != "code1" || form.Get("scope") != "" {
+		t.Errorf("code exchange form = %v", form)
+	}
+	if _, got := ts.send(t, tokens, auth); got != "Bearer t1" {
+		t.Errorf("cached Authorization = %q, want %q", got, "Bearer t1")
+	}
+}
+
+func TestTokenCacheWaitingForBrowser(t *testing.T) {
+	ts := newTokenServer(t)
+	auth := Auth{Scheme: AuthOAuth2, OAuth2: OAuth2{Grant: OAuth2AuthorizationCode, AuthURL: ts.URL + "/authorize", TokenURL: ts.URL + "/token", ClientID: "app"}}
+
+	opened := make(chan string, 1)
+	tokens := NewTokenCache(func(page string) error {
+		opened <- page
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first := make(chan error, 1)
+	go func() {
+		_, err := tokens.Token(ctx, auth, Options{})
+		first <- err
+	}()
+	page := <-opened
+
+	// the cache stays usable while the browser is pending
+	read := make(chan struct{})
+	go func() {
+		tokens.Cached(auth)
+		tokens.Forget(Auth{Scheme: AuthOAuth2})
+		close(read)
+	}()
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Fatal("Cached blocked while the authorization was pending")
+	}
+
+	// a second request waits for the pending authorization instead of opening the browser again
+	second := make(chan Token, 1)
+	go func() {
+		token, err := tokens.Token(context.Background(), auth, Options{})
+		if err != nil {
+			t.Errorf("second Token() error = %v", err)
+		}
+		second <- token
+	}()
+
+	browser(t)(page)
+	if err := <-first; err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if token := <-second; token.AccessToken != "t1" {
+		t.Errorf("second token = %q, want the pending one", token.AccessToken)
+	}
+	select {
+	case page := <-opened:
+		t.Errorf("the browser was opened twice: %s", page)
+	default:
+	}
+}
+
+func TestTokenCacheCancelled(t *testing.T) {
+	ts := newTokenServer(t)
+	auth := Auth{Scheme: AuthOAuth2, OAuth2: OAuth2{Grant: OAuth2AuthorizationCode, AuthURL: ts.URL + "/authorize", TokenURL: ts.URL + "/token"}}
+	tokens := NewTokenCache(func(string) error { return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := Send(ctx, ts.URL+"/api", http.MethodGet, nil, nil, Options{Auth: auth, Tokens: tokens})
+	if err != ErrTimedOut {
+		t.Errorf("Send() error = %v, want %v", err, ErrTimedOut)
+	}
+}
@@ -0,0 +1,150 @@
+package communication
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Proxy override modes of a single request
+const (
+	ProxyInherit = "inherit"
+	ProxyDirect  = "direct"
+	ProxyCustom  = "custom"
+)
+
+// ProxyOptions holds the proxy configuration. When no proxy is set the
+// environment variables (HTTP_PROXY, HTTPS_PROXY, NO_PROXY) are used.
+type ProxyOptions struct {
+	// Direct disables every proxy, including the environment ones
+	Direct bool
+	HTTP   string
+	HTTPS  string
+	// SOCKS5 takes precedence over the HTTP and HTTPS proxies
+	SOCKS5   string
+	Username string
+	Password string
+	// Bypass lists hosts, domains (".example.com"), CIDR ranges or "*" that are reached directly
+	Bypass []string
+}
+
+// ProxyOverride replaces the proxy configuration for a single request
+type ProxyOverride struct {
+	Mode string
+	URL  string
+}
+
+// Override returns the proxy options to use for a request with the provided override
+func (p ProxyOptions) Override(o ProxyOverride) ProxyOptions {
+	switch o.Mode {
+	case ProxyDirect:
+		return ProxyOptions{Direct: true}
+	case ProxyCustom:
+		// the bypass list and credentials still apply to the custom proxy
+		return ProxyOptions{HTTP: o.URL, HTTPS: o.URL, Username: p.Username, Password: p.Password, Bypass: p.Bypass}
+	}
+	return p
+}
+
+// proxyFunc returns the http.Transport Proxy func
+func (p ProxyOptions) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if p.Direct {
+		return nil, nil
+	}
+	if p.HTTP == "" && p.HTTPS == "" && p.SOCKS5 == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	httpProxy, err := p.proxyURL(p.HTTP, "http")
+	if err != nil {
+		return nil, err
+	}
+	httpsProxy, err := p.proxyURL(p.HTTPS, "http")
+	if err != nil {
+		return nil, err
+	}
+	socksProxy, err := p.proxyURL(p.SOCKS5, "socks5")
+	if err != nil {
+		return nil, err
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if p.bypassed(req.URL) {
+			return nil, nil
+		}
+		if socksProxy != nil {
+			return socksProxy, nil
+		}
+		if req.URL.Scheme == "https" {
+			return httpsProxy, nil
+		}
+		return httpProxy, nil
+	}, nil
+}
+
+// proxyURL parses a proxy address, defaultScheme is used for bare host:port values
+func (p ProxyOptions) proxyURL(address, defaultScheme string) (*url.URL, error) {
+	if address == "" {
+		return nil, nil
+	}
+	if !strings.Contains(address, "://") {
+		address = defaultScheme + "://" + address
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy address %s: %s", address, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+	}
+
+	if u.User == nil && p.Username != "" {
+		u.User = url.UserPassword(p.Username, p.Password)
+	}
+
+	return u, nil
+}
+
+// bypassed reports whether the request URL matches the bypass list
+func (p ProxyOptions) bypassed(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	ip := net.ParseIP(host)
+
+	for _, entry := range p.Bypass {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, port, err := net.SplitHostPort(entry); err == nil {
+			if port != u.Port() {
+				continue
+			}
+			entry = h
+		}
+		if strings.HasPrefix(entry, ".") {
+			if strings.HasSuffix(host, entry) || host == entry[1:] {
+				return true
+			}
+			continue
+		}
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
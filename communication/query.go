@@ -0,0 +1,89 @@
+package communication
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// QueryParam is a single query string parameter, disabled params are kept
+// in the editor but left out of the URL
+type QueryParam struct {
+	Key      string
+	Value    string
+	Disabled bool
+}
+
+// splitURL splits rawURL into the part before the query, the raw query and the fragment (with its '#')
+func splitURL(rawURL string) (string, string, string) {
+	base, fragment := rawURL, ""
+	if idx := strings.Index(base, "#"); idx >= 0 {
+		base, fragment = base[:idx], base[idx:]
+	}
+	query := ""
+	if idx := strings.Index(base, "?"); idx >= 0 {
+		base, query = base[:idx], base[idx+1:]
+	}
+	return base, query, fragment
+}
+
+// ParseQuery returns the decoded query params of rawURL in the order they
+// appear, repeated keys are kept as separate params
+func ParseQuery(rawURL string) []QueryParam {
+	_, query, _ := splitURL(rawURL)
+
+	var params []QueryParam
+	for _, pair := range strings.FieldsFunc(query, func(r rune) bool { return r == '&' }) {
+		key, value := pair, ""
+		if idx := strings.Index(pair, "="); idx >= 0 {
+			key, value = pair[:idx], pair[idx+1:]
+		}
+		params = append(params, QueryParam{
+			Key:   unescapeQuery(key),
+			Value: unescapeQuery(value),
+		})
+	}
+	return params
+}
+
+// WithQuery replaces the query of rawURL with the enabled params, percent-encoding them
+func WithQuery(rawURL string, params []QueryParam) string {
+	base, _, fragment := splitURL(rawURL)
+
+	var pairs []string
+	for _, p := range params {
+		if p.Disabled {
+			continue
+		}
+		pairs = append(pairs, escapeQuery(p.Key)+"="+escapeQuery(p.Value))
+	}
+	if len(pairs) == 0 {
+		return base + fragment
+	}
+	return base + "?" + strings.Join(pairs, "&") + fragment
+}
+
+// variableRefRegex matches {{name}} references, they are left unescaped so they can still be substituted
+var variableRefRegex = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+
+// escapeQuery percent-encodes a query component, keeping the {{name}} references as typed
+func escapeQuery(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range variableRefRegex.FindAllStringIndex(s, -1) {
+		b.WriteString(url.QueryEscape(s[last:loc[0]]))
+		b.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(url.QueryEscape(s[last:]))
+	return b.String()
+}
+
+// unescapeQuery decodes a query component, values that are not valid
+// percent-encodings are returned as typed
+func unescapeQuery(s string) string {
+	if unescaped, err := url.QueryUnescape(s); err == nil {
+		return unescaped
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+package communication
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Redirect policy modes
+const (
+	RedirectFollow = "follow"
+	RedirectNone   = "none"
+	RedirectLimit  = "limit"
+)
+
+// RedirectPolicy controls how redirect responses are handled. The zero value
+// follows redirects the same way net/http does by default.
+type RedirectPolicy struct {
+	Mode string
+	// Max is the maximum number of redirects followed in RedirectLimit mode
+	Max int
+}
+
+// Hop holds a single redirect response of a redirect chain
+type Hop struct {
+	URL        string
+	Method     string
+	StatusCode int
+	Headers    map[string][]string
+	Dur        time.Duration
+}
+
+// checkRedirect returns the http.Client CheckRedirect func for the policy
+func (rp RedirectPolicy) checkRedirect() func(req *http.Request, via []*http.Request) error {
+	switch rp.Mode {
+	case RedirectNone:
+		return func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	case RedirectLimit:
+		return func(req *http.Request, via []*http.Request) error {
+			if len(via) > rp.Max {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// hopRecorder records every round trip performed by the client so that the
+// redirect chain can be reported
+type hopRecorder struct {
+	transport http.RoundTripper
+	trace     *timingTrace
+
+	mu   sync.Mutex
+	hops []Hop
+}
+
+func (hr *hopRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	hr.trace.reset()
+	start := time.Now()
+
+	res, err := hr.transport.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	hr.mu.Lock()
+	hr.hops = append(hr.hops, Hop{
+		URL:        req.URL.String(),
+		Method:     req.Method,
+		StatusCode: res.StatusCode,
+		Headers:    res.Header,
+		Dur:        time.Now().Sub(start),
+	})
+	hr.mu.Unlock()
+
+	return res, nil
+}
+
+// redirects returns the recorded hops without the final response
+func (hr *hopRecorder) redirects() []Hop {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	if len(hr.hops) < 2 {
+		return nil
+	}
+	return hr.hops[:len(hr.hops)-1]
+}
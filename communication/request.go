@@ -1,36 +1,132 @@
 package communication
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// Request outcomes recorded alongside the response
+const (
+	OutcomeCompleted = "completed"
+	OutcomeCancelled = "cancelled"
+	OutcomeTimedOut  = "timed out"
+	// OutcomeNotSent marks requests added to the history without being sent
+	OutcomeNotSent = "not sent"
+)
+
+// ErrCancelled is returned by Send when the request context gets cancelled
+var ErrCancelled = errors.New("request cancelled")
+
+// ErrTimedOut is returned by Send when one of the request timeouts expires
+var ErrTimedOut = errors.New("request timed out")
+
+// Timeouts holds the timeout configuration of a single request.
+// Zero values disable the corresponding timeout.
+type Timeouts struct {
+	Connect        time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+	Total          time.Duration
+}
+
+// DefaultTimeouts are used for new requests
+var DefaultTimeouts = Timeouts{
+	Connect:      30 * time.Second,
+	TLSHandshake: 10 * time.Second,
+}
+
+// Result holds the outcome of a successfully sent request
+type Result struct {
+	Response *http.Response
+	// Body is decoded from the Content-Encoding of the response
+	Body []byte
+	// EncodedSize is the size of the body as it was received
+	EncodedSize int64
+	Timing      Timing
+	// Redirects holds the redirect responses that preceded Response
+	Redirects []Hop
+	// TLS is nil for plain HTTP responses
+	TLS *TLSInfo
+}
+
+// Options holds the per request transport configuration
+type Options struct {
+	Timeouts  Timeouts
+	Redirects RedirectPolicy
+	TLS       TLSOptions
+	Proxy     ProxyOptions
+	Auth      Auth
+	// Tokens caches the tokens of the oauth2 scheme, nil obtains a new token for every request
+	Tokens *TokenCache
+	// Jar stores and supplies the cookies, nil sends the request without cookies
+	Jar http.CookieJar
+}
+
+// MethodHasBody reports whether requests with the method usually carry a body
+func MethodHasBody(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+// Outcome resolves the outcome of a request from the error returned by Send
+func Outcome(err error) string {
+	switch err {
+	case ErrCancelled:
+		return OutcomeCancelled
+	case ErrTimedOut:
+		return OutcomeTimedOut
+	}
+	return OutcomeCompleted
+}
+
 // Send sends the HTTP request
-func Send(url, method string, headers map[string][]string, body string) (*http.Response, []byte, error) {
-	log.Printf("Sending rq: %#v %#v %#v %#v \n", url, method, headers, body)
+func Send(ctx context.Context, url, method string, headers map[string][]string, body []byte, opts Options) (*Result, error) {
+	log.Printf("Sending rq: %#v %#v %#v (%d bytes) \n", url, method, headers, len(body))
+
+	// the token is obtained before the timing of the request starts
+	var token Token
+	if opts.Auth.Scheme == AuthOAuth2 {
+		if opts.Tokens == nil {
+			opts.Tokens = NewTokenCache(nil)
+		}
+		var err error
+		token, err = opts.Tokens.Token(ctx, opts.Auth, opts)
+		if err != nil {
+			if err == ErrCancelled || ctx.Err() != nil {
+				return nil, resolveError(ctx, err)
+			}
+			return nil, fmt.Errorf("unable to get the OAuth 2.0 token: %s", err)
+		}
+	}
+	ctx, trace := newTimingTrace(ctx)
+
 	// create request body
-	var reqBody *strings.Reader
-	var req *http.Request
-
-	if method != "GET" && method != "HEAD" {
-		reqBody = strings.NewReader(body)
-
-		// create a request object
-		req, _ = http.NewRequest(
-			method,
-			url,
-			reqBody,
-		)
-	} else {
-		// create a request object
-		req, _ = http.NewRequest(
-			method,
-			url,
-			nil,
-		)
+	var reqBody io.Reader
+	if len(body) > 0 || MethodHasBody(method) {
+		reqBody = bytes.NewReader(body)
+	}
+
+	// create a request object
+	req, err := http.NewRequestWithContext(
+		ctx,
+		method,
+		url,
+		reqBody,
+	)
+	if err != nil {
+		return nil, err
 	}
 
 	for k, values := range headers {
@@ -38,19 +134,122 @@ func Send(url, method string, headers map[string][]string, body string) (*http.R
 			req.Header.Add(k, v)
 		}
 	}
+	// the credentials take precedence over headers of the same name
+	opts.Auth.apply(req)
+	if token.AccessToken != "" {
+		req.Header.Set("Authorization", token.authorization())
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", AcceptEncoding)
+	}
 	// send an HTTP using `req` object
-	res, err := http.DefaultClient.Do(req)
+	recorder := &hopRecorder{trace: trace}
+	client, transport, err := newClient(opts, recorder)
+	if err != nil {
+		return nil, err
+	}
+	// every request gets its own transport, its connections aren't reused
+	defer transport.CloseIdleConnections()
+	res, err := client.Do(req)
 
 	// check for response error
 	if err != nil {
-		return res, nil, err
+		return nil, resolveError(ctx, err)
 	}
 
-	// read response body
-	data, _ := ioutil.ReadAll(res.Body)
-
 	// close response body
 	defer res.Body.Close()
+	if token.AccessToken != "" && res.StatusCode == http.StatusUnauthorized {
+		// a revoked token is obtained again by the next request
+		opts.Tokens.Forget(opts.Auth)
+	}
+
+	// read response body
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, resolveError(ctx, err)
+	}
+	encodedSize := int64(len(data))
+	if contentEncoding := res.Header.Get("Content-Encoding"); contentEncoding != "" && len(data) > 0 {
+		decoded, err := decodeContent(contentEncoding, data)
+		if err != nil {
+			// keep the raw body so it can still be inspected
+			log.Warnf("Unable to decode the response body: %s", err)
+		} else {
+			data = decoded
+		}
+	}
+
+	tlsInfo := newTLSInfo(res.TLS)
+	if tlsInfo != nil && tlsInfo.ServerName == "" {
+		// ConnectionState only carries the server name on the server side
+		tlsInfo.ServerName = opts.TLS.ServerName
+		if tlsInfo.ServerName == "" {
+			tlsInfo.ServerName = res.Request.URL.Hostname()
+		}
+	}
+
+	return &Result{
+		Response:    res,
+		Body:        data,
+		EncodedSize: encodedSize,
+		Timing:      trace.timing(time.Now()),
+		Redirects:   recorder.redirects(),
+		TLS:         tlsInfo,
+	}, nil
+}
+
+func newClient(opts Options, recorder *hopRecorder) (*http.Client, *http.Transport, error) {
+	tlsConfig, err := opts.TLS.config()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proxy, err := opts.Proxy.proxyFunc()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   opts.Timeouts.Connect,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		DisableCompression:    true,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   opts.Timeouts.TLSHandshake,
+		ResponseHeaderTimeout: opts.Timeouts.ResponseHeader,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
 
-	return res, data, nil
+	recorder.transport = transport
+	if opts.Auth.Scheme == AuthDigest {
+		recorder.transport = newDigestTransport(transport, opts.Auth.Username, opts.Auth.Password)
+	}
+
+	return &http.Client{
+		Transport:     recorder,
+		CheckRedirect: opts.Redirects.checkRedirect(),
+		Jar:           opts.Jar,
+		Timeout:       opts.Timeouts.Total,
+	}, transport, nil
+}
+
+// resolveError maps cancellations and expired timeouts to ErrCancelled and ErrTimedOut
+func resolveError(ctx context.Context, err error) error {
+	if ctx.Err() == context.Canceled {
+		return ErrCancelled
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrTimedOut
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimedOut
+	}
+	return err
 }
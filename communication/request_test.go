@@ -0,0 +1,52 @@
+package communication
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendClosesIdleConnections(t *testing.T) {
+	var mu sync.Mutex
+	open := 0
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch state {
+		case http.StateNew:
+			open++
+		case http.StateClosed, http.StateHijacked:
+			open--
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	for i := 0; i < 3; i++ {
+		if _, err := Send(context.Background(), ts.URL, http.MethodGet, nil, nil, Options{Timeouts: DefaultTimeouts}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		remaining := open
+		mu.Unlock()
+		if remaining == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d connections are still open after the requests", remaining)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
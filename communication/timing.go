@@ -0,0 +1,142 @@
+package communication
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Phase is a single step of a request, relative to the moment it was sent
+type Phase struct {
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// Timing holds the breakdown of the time spent performing a request.
+// Phases that did not happen (e.g. DNS lookup on a reused connection) are zero.
+type Timing struct {
+	DNSLookup       Phase
+	TCPConnect      Phase
+	TLSHandshake    Phase
+	TimeToFirstByte Phase
+	ContentTransfer Phase
+	Total           time.Duration
+}
+
+// NamedPhase pairs a phase with its display name
+type NamedPhase struct {
+	Name string
+	Phase
+}
+
+// Phases returns the request phases in the order they happen
+func (t Timing) Phases() []NamedPhase {
+	return []NamedPhase{
+		{"DNS Lookup", t.DNSLookup},
+		{"TCP Connect", t.TCPConnect},
+		{"TLS Handshake", t.TLSHandshake},
+		{"Time To First Byte", t.TimeToFirstByte},
+		{"Content Transfer", t.ContentTransfer},
+	}
+}
+
+// timingTrace collects httptrace events into a Timing
+type timingTrace struct {
+	mu    sync.Mutex
+	start time.Time
+
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	gotConn, firstByte        time.Time
+}
+
+func newTimingTrace(ctx context.Context) (context.Context, *timingTrace) {
+	tt := &timingTrace{start: time.Now()}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tt.mark(&tt.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tt.mark(&tt.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			tt.markFirst(&tt.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			tt.mark(&tt.connectDone)
+		},
+		TLSHandshakeStart: func() {
+			tt.mark(&tt.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tt.mark(&tt.tlsDone)
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			tt.mark(&tt.gotConn)
+		},
+		GotFirstResponseByte: func() {
+			tt.mark(&tt.firstByte)
+		},
+	}
+
+	return httptrace.WithClientTrace(ctx, trace), tt
+}
+
+func (tt *timingTrace) mark(t *time.Time) {
+	tt.mu.Lock()
+	*t = time.Now()
+	tt.mu.Unlock()
+}
+
+// markFirst only records the first occurrence, dialing multiple addresses
+// reports a ConnectStart for each of them
+func (tt *timingTrace) markFirst(t *time.Time) {
+	tt.mu.Lock()
+	if t.IsZero() {
+		*t = time.Now()
+	}
+	tt.mu.Unlock()
+}
+
+// reset clears the collected events, only the last hop of a redirect chain is reported
+func (tt *timingTrace) reset() {
+	tt.mu.Lock()
+	tt.dnsStart, tt.dnsDone = time.Time{}, time.Time{}
+	tt.connectStart, tt.connectDone = time.Time{}, time.Time{}
+	tt.tlsStart, tt.tlsDone = time.Time{}, time.Time{}
+	tt.gotConn, tt.firstByte = time.Time{}, time.Time{}
+	tt.mu.Unlock()
+}
+
+// timing builds the Timing once the response body has been read at the provided time
+func (tt *timingTrace) timing(end time.Time) Timing {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	t := Timing{
+		DNSLookup:    tt.phase(tt.dnsStart, tt.dnsDone),
+		TCPConnect:   tt.phase(tt.connectStart, tt.connectDone),
+		TLSHandshake: tt.phase(tt.tlsStart, tt.tlsDone),
+		Total:        end.Sub(tt.start),
+	}
+	if !tt.firstByte.IsZero() {
+		t.TimeToFirstByte = tt.phase(tt.gotConn, tt.firstByte)
+		t.ContentTransfer = tt.phase(tt.firstByte, end)
+	}
+
+	return t
+}
+
+func (tt *timingTrace) phase(from, to time.Time) Phase {
+	if from.IsZero() || to.IsZero() {
+		return Phase{}
+	}
+	return Phase{
+		Start:    from.Sub(tt.start),
+		Duration: to.Sub(from),
+	}
+}
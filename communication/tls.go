@@ -0,0 +1,153 @@
+package communication
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSVersions lists the selectable TLS versions, from oldest to newest
+var TLSVersions = []string{"1.0", "1.1", "1.2", "1.3"}
+
+var tlsVersionValues = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Certificate verification modes of a single request
+const (
+	TLSVerifyInherit = "inherit"
+	TLSVerify        = "verify"
+	TLSSkipVerify    = "skip"
+)
+
+// TLSOptions holds the TLS configuration of a request. Empty values keep the
+// Go defaults.
+type TLSOptions struct {
+	InsecureSkipVerify bool
+	// Verification of a request replaces the InsecureSkipVerify of the options
+	// it is merged into, empty or TLSVerifyInherit keeps it
+	Verification string
+	// CAFiles are PEM files with certificates trusted in addition to the system pool
+	CAFiles        []string
+	ClientCertFile string
+	ClientKeyFile  string
+	MinVersion     string
+	MaxVersion     string
+	ServerName     string
+}
+
+// Merge returns the options with every value set in override taking precedence.
+// CA files of both are trusted.
+func (t TLSOptions) Merge(override TLSOptions) TLSOptions {
+	merged := t
+	switch override.Verification {
+	case TLSVerify, TLSSkipVerify:
+		merged.InsecureSkipVerify = override.Verification == TLSSkipVerify
+	default:
+		// requests stored before the verification mode existed only set the flag
+		merged.InsecureSkipVerify = t.InsecureSkipVerify || override.InsecureSkipVerify
+	}
+	merged.Verification = ""
+	merged.CAFiles = append(append([]string{}, t.CAFiles...), override.CAFiles...)
+	if override.ClientCertFile != "" {
+		merged.ClientCertFile = override.ClientCertFile
+		merged.ClientKeyFile = override.ClientKeyFile
+	}
+	if override.MinVersion != "" {
+		merged.MinVersion = override.MinVersion
+	}
+	if override.MaxVersion != "" {
+		merged.MaxVersion = override.MaxVersion
+	}
+	if override.ServerName != "" {
+		merged.ServerName = override.ServerName
+	}
+	return merged
+}
+
+// SkipVerify reports whether the server certificate is left unverified
+func (t TLSOptions) SkipVerify() bool {
+	switch t.Verification {
+	case TLSVerify:
+		return false
+	case TLSSkipVerify:
+		return true
+	}
+	return t.InsecureSkipVerify
+}
+
+// config builds the tls.Config, returns nil when the defaults should be used
+func (t TLSOptions) config() (*tls.Config, error) {
+	if t.isZero() {
+		return nil, nil
+	}
+
+	conf := &tls.Config{
+		InsecureSkipVerify: t.SkipVerify(),
+		ServerName:         t.ServerName,
+	}
+
+	if len(t.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, file := range t.CAFiles {
+			pem, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read CA file: %s", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no PEM certificates found in CA file %s", file)
+			}
+		}
+		conf.RootCAs = pool
+	}
+
+	if t.ClientCertFile != "" {
+		keyFile := t.ClientKeyFile
+		if keyFile == "" {
+			// The key may be bundled in the certificate file
+			keyFile = t.ClientCertFile
+		}
+		cert, err := tls.LoadX509KeyPair(t.ClientCertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %s", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	var err error
+	if conf.MinVersion, err = tlsVersion(t.MinVersion); err != nil {
+		return nil, err
+	}
+	if conf.MaxVersion, err = tlsVersion(t.MaxVersion); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+func (t TLSOptions) isZero() bool {
+	return !t.SkipVerify() &&
+		len(t.CAFiles) == 0 &&
+		t.ClientCertFile == "" &&
+		t.ClientKeyFile == "" &&
+		t.MinVersion == "" &&
+		t.MaxVersion == "" &&
+		t.ServerName == ""
+}
+
+func tlsVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+	if v, ok := tlsVersionValues[version]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version: %s", version)
+}
@@ -0,0 +1,36 @@
+package communication
+
+import (
+	"testing"
+)
+
+func TestTLSOptionsMergeVerification(t *testing.T) {
+	tests := []struct {
+		name     string
+		global   bool
+		override TLSOptions
+		want     bool
+	}{
+		{"inherit verifying", false, TLSOptions{}, false},
+		{"inherit skipping", true, TLSOptions{Verification: TLSVerifyInherit}, true},
+		{"request skips", false, TLSOptions{Verification: TLSSkipVerify}, true},
+		{"request verifies despite the preferences", true, TLSOptions{Verification: TLSVerify}, false},
+		{"stored flag without a mode", false, TLSOptions{InsecureSkipVerify: true}, true},
+		{"mode wins over the stored flag", true, TLSOptions{InsecureSkipVerify: true, Verification: TLSVerify}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := TLSOptions{InsecureSkipVerify: tt.global}.Merge(tt.override)
+			if merged.InsecureSkipVerify != tt.want || merged.SkipVerify() != tt.want {
+				t.Errorf("InsecureSkipVerify = %v, want %v", merged.InsecureSkipVerify, tt.want)
+			}
+			conf, err := merged.config()
+			if err != nil {
+				t.Fatalf("config() error = %v", err)
+			}
+			if got := conf != nil && conf.InsecureSkipVerify; got != tt.want {
+				t.Errorf("tls.Config InsecureSkipVerify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
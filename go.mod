@@ -1,14 +1,35 @@
 module github.com/lnenad/probster
 
-go 1.13
+go 1.19
 
 require (
-	github.com/akavel/rsrc v0.10.1 // indirect
+	github.com/PaesslerAG/jsonpath v0.1.1
 	github.com/alecthomas/chroma v0.8.2
+	github.com/andybalholm/brotli v1.0.4
+	github.com/antchfx/xmlquery v1.3.5
+	github.com/antchfx/xpath v1.1.10
 	github.com/asaskevich/EventBus v0.0.0-20200907212545-49d423059eef
+	github.com/dop251/goja v0.0.0-20230806174421-c933cf95e127
 	github.com/gotk3/gotk3 v0.5.2
 	github.com/hashicorp/go-version v1.2.1
 	github.com/sirupsen/logrus v1.7.0
-	github.com/tc-hib/rsrc v0.9.2 // indirect
 	github.com/xujiajun/nutsdb v0.5.0
+	golang.org/x/net v0.25.0
+	golang.org/x/text v0.16.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/PaesslerAG/gval v1.0.0 // indirect
+	github.com/akavel/rsrc v0.10.1 // indirect
+	github.com/bwmarrin/snowflake v0.3.0 // indirect
+	github.com/danwakefield/fnmatch v0.0.0-20160403171240-cbb64ac3d964 // indirect
+	github.com/dlclark/regexp2 v1.7.0 // indirect
+	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
+	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
+	github.com/tc-hib/rsrc v0.9.2 // indirect
+	github.com/xujiajun/mmap-go v1.0.1 // indirect
+	github.com/xujiajun/utils v0.0.0-20190123093513-8bf096c4f53b // indirect
+	golang.org/x/sys v0.20.0 // indirect
 )
@@ -3,6 +3,7 @@ package main
 import (
 	log "github.com/sirupsen/logrus"
 
+	"github.com/lnenad/probster/cli"
 	"github.com/lnenad/probster/storage"
 	"github.com/lnenad/probster/window"
 
@@ -23,6 +24,11 @@ const versionString = "0.4.1"
 func main() {
 	parseArgs()
 
+	// the run command executes stored requests without starting GTK
+	if len(os.Args) >= 2 && os.Args[1] == "run" {
+		os.Exit(runHeadless(os.Args[2:]))
+	}
+
 	currentVersion, err := gv.NewVersion(versionString)
 	if err != nil {
 		log.Fatal("Error setting version: ", err)
@@ -33,23 +39,21 @@ func main() {
 		log.Fatal("Could not create application:", err)
 	}
 
-	opt := nutsdb.DefaultOptions
-	opt.Dir = dataFile
-	db, err := nutsdb.Open(opt)
-	if err != nil {
-		log.Fatal(err)
-	}
+	db := openDB()
 	defer db.Close()
 
 	h := storage.SetupHistory(db)
 	st := storage.SetupSettings(db)
+	cs := storage.SetupCookies(db)
+	es := storage.SetupEnvironments(db)
+	cols := storage.SetupCollections(db)
 
 	settings := st.GetAll()
 
 	bus := evbus.New()
 
 	application.Connect("activate", func() {
-		window.BuildWindow(currentVersion, &settings, application, &h, &st, bus)
+		window.BuildWindow(currentVersion, &settings, application, &h, &st, &cs, &es, &cols, bus)
 
 		aQuit := glib.SimpleActionNew("quit", nil)
 		aQuit.Connect("activate", func() {
@@ -61,6 +65,30 @@ func main() {
 	os.Exit(application.Run(os.Args))
 }
 
+func openDB() *nutsdb.DB {
+	opt := nutsdb.DefaultOptions
+	opt.Dir = dataFile
+	db, err := nutsdb.Open(opt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+// runHeadless runs the stored requests selected by args and returns the exit code
+func runHeadless(args []string) int {
+	db := openDB()
+	defer db.Close()
+
+	h := storage.SetupHistory(db)
+	st := storage.SetupSettings(db)
+	cs := storage.SetupCookies(db)
+	es := storage.SetupEnvironments(db)
+
+	runner := cli.NewRunner(&h, st.GetAll(), &st, &cs, &es, os.Stdout, os.Stderr)
+	return runner.Run(args)
+}
+
 func parseArgs() {
 	if len(os.Args) >= 2 && os.Args[1] == "debug" {
 		f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
@@ -0,0 +1,403 @@
+// Package scripting runs the JavaScript hooks of requests. Scripts only see
+// the API set up here, there is no access to files, the network or modules.
+package scripting
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/lnenad/probster/communication"
+	"github.com/lnenad/probster/storage"
+)
+
+// Timeout stops scripts that run for too long, like endless loops
+const Timeout = 5 * time.Second
+
+// Console levels
+const (
+	LevelLog   = "log"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Line is a message a script wrote to the console
+type Line struct {
+	Level   string
+	Message string
+}
+
+// Result holds what a script produced besides its changes to the request or response
+type Result struct {
+	Console []Line
+	// Variables holds the variables the script set, Unset the ones it removed
+	Variables map[string]string
+	Unset     []string
+}
+
+// ApplyVariables applies the variable changes of the script to vars and
+// reports whether there were any
+func (r Result) ApplyVariables(vars map[string]string) bool {
+	for _, name := range r.Unset {
+		delete(vars, name)
+	}
+	for name, value := range r.Variables {
+		vars[name] = value
+	}
+	return len(r.Unset) > 0 || len(r.Variables) > 0
+}
+
+// PreRequest runs the pre-request script of the request, which can change it
+// before its {{name}} references are resolved with vars
+func PreRequest(rq *storage.RequestInput, vars map[string]string) (Result, error) {
+	if strings.TrimSpace(rq.PreRequestScript) == "" {
+		return Result{}, nil
+	}
+	s := newSession(vars)
+	request := s.requestObject(*rq)
+	s.vm.Set("request", request)
+	if err := s.run("pre-request", rq.PreRequestScript); err != nil {
+		return s.result, err
+	}
+	if err := s.readRequest(request, rq); err != nil {
+		return s.result, fmt.Errorf("pre-request script: %s", err)
+	}
+	return s.result, nil
+}
+
+// PostResponse runs the post-response script of the request, the results of
+// the tests it defines are added to the response
+func PostResponse(rq storage.RequestInput, rr *storage.RequestResult, vars map[string]string) (Result, error) {
+	if strings.TrimSpace(rq.PostResponseScript) == "" {
+		return Result{}, nil
+	}
+	s := newSession(vars)
+	request := s.requestObject(rq)
+	s.vm.Set("request", request)
+	s.vm.Set("response", s.responseObject(*rr))
+	s.vm.Set("test", func(name string, fn goja.Callable) {
+		result := storage.AssertionResult{Name: name, Passed: true}
+		if _, err := fn(goja.Undefined()); err != nil {
+			var interrupted *goja.InterruptedError
+			if errors.As(err, &interrupted) {
+				panic(interrupted)
+			}
+			result.Passed, result.Error = false, errorMessage(err)
+		}
+		rr.Tests = append(rr.Tests, result)
+	})
+	return s.result, s.run("post-response", rq.PostResponseScript)
+}
+
+// session is a single script run
+type session struct {
+	vm     *goja.Runtime
+	vars   map[string]string
+	result Result
+}
+
+func newSession(vars map[string]string) *session {
+	s := &session{vm: goja.New(), vars: make(map[string]string, len(vars))}
+	for name, value := range vars {
+		s.vars[name] = value
+	}
+	s.vm.Set("console", s.consoleObject())
+	s.vm.Set("variables", s.variablesObject())
+	s.vm.Set("crypto", s.cryptoObject())
+	s.vm.Set("btoa", func(text string) string {
+		return base64.StdEncoding.EncodeToString([]byte(text))
+	})
+	s.vm.Set("atob", func(encoded string) string {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			panic(s.vm.NewTypeError("invalid base64: %s", err))
+		}
+		return string(decoded)
+	})
+	s.vm.Set("uuid", func() string {
+		var b [16]byte
+		rand.Read(b[:])
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	})
+	return s
+}
+
+// run executes the script, stopping it once it reaches the Timeout
+func (s *session) run(hook, script string) (err error) {
+	timer := time.AfterFunc(Timeout, func() {
+		s.vm.Interrupt(fmt.Sprintf("stopped after %s", Timeout))
+	})
+	defer timer.Stop()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s script: %v", hook, r)
+		}
+	}()
+
+	program, err := goja.Compile(hook+" script", script, false)
+	if err == nil {
+		_, err = s.vm.RunProgram(program)
+	}
+	if err != nil {
+		return fmt.Errorf("%s script: %s", hook, errorMessage(err))
+	}
+	return nil
+}
+
+// bytecodeOffset matches the bytecode position goja appends to stack frames
+var bytecodeOffset = regexp.MustCompile(`\(\d+\)(\)?)$`)
+
+// errorMessage returns the message of a script error along with the script
+// position it was raised at, the frames of the native helpers are skipped
+func errorMessage(err error) string {
+	var syntaxErr *goja.CompilerSyntaxError
+	if errors.As(err, &syntaxErr) {
+		return "SyntaxError: " + syntaxErr.Message
+	}
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return fmt.Sprint(interrupted.Value())
+	}
+	var exception *goja.Exception
+	if !errors.As(err, &exception) {
+		return err.Error()
+	}
+	msg := exception.Value().String()
+	for _, frame := range strings.Split(exception.String(), "\n")[1:] {
+		frame = strings.TrimSpace(frame)
+		if strings.HasPrefix(frame, "at ") && !strings.HasSuffix(frame, "(native)") {
+			return msg + " " + bytecodeOffset.ReplaceAllString(frame, "$1")
+		}
+	}
+	return msg
+}
+
+func (s *session) consoleObject() *goja.Object {
+	console := s.vm.NewObject()
+	for _, level := range []string{LevelLog, LevelInfo, LevelWarn, LevelError} {
+		lvl := level
+		console.Set(lvl, func(call goja.FunctionCall) goja.Value {
+			parts := make([]string, len(call.Arguments))
+			for idx, arg := range call.Arguments {
+				parts[idx] = s.format(arg)
+			}
+			s.result.Console = append(s.result.Console, Line{Level: lvl, Message: strings.Join(parts, " ")})
+			return goja.Undefined()
+		})
+	}
+	console.Set("debug", console.Get(LevelLog))
+	return console
+}
+
+// format prints a console argument, objects are encoded as JSON
+func (s *session) format(arg goja.Value) string {
+	if obj, ok := arg.(*goja.Object); ok {
+		if _, isFunc := goja.AssertFunction(obj); !isFunc {
+			if encoded, err := json.Marshal(obj.Export()); err == nil {
+				return string(encoded)
+			}
+		}
+	}
+	return arg.String()
+}
+
+func (s *session) variablesObject() *goja.Object {
+	variables := s.vm.NewObject()
+	variables.Set("get", func(name string) goja.Value {
+		if value, ok := s.vars[name]; ok {
+			return s.vm.ToValue(value)
+		}
+		return goja.Undefined()
+	})
+	variables.Set("set", func(name string, value goja.Value) {
+		if !storage.IsVariableName(name) {
+			panic(s.vm.NewTypeError("invalid variable name %q", name))
+		}
+		str := value.String()
+		s.vars[name] = str
+		if s.result.Variables == nil {
+			s.result.Variables = make(map[string]string)
+		}
+		s.result.Variables[name] = str
+		s.result.Unset = removeString(s.result.Unset, name)
+	})
+	variables.Set("unset", func(name string) {
+		delete(s.vars, name)
+		delete(s.result.Variables, name)
+		s.result.Unset = append(removeString(s.result.Unset, name), name)
+	})
+	variables.Set("replace", func(text string) string {
+		out, _ := storage.Substitute(text, s.vars)
+		return out
+	})
+	return variables
+}
+
+func removeString(values []string, value string) []string {
+	var out []string
+	for _, v := range values {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// cryptoObject offers the hashes used to sign requests, digests are hex encoded
+// unless "base64" is asked for
+func (s *session) cryptoObject() *goja.Object {
+	digest := func(h hash.Hash, data string, encoding goja.Value) string {
+		h.Write([]byte(data))
+		sum := h.Sum(nil)
+		if encoding != nil && encoding.String() == "base64" {
+			return base64.StdEncoding.EncodeToString(sum)
+		}
+		return hex.EncodeToString(sum)
+	}
+	hashFunc := func(algorithm string) func() hash.Hash {
+		switch strings.ToLower(strings.Replace(algorithm, "-", "", 1)) {
+		case "md5":
+			return md5.New
+		case "sha1":
+			return sha1.New
+		case "sha256":
+			return sha256.New
+		case "sha512":
+			return sha512.New
+		}
+		panic(s.vm.NewTypeError("unsupported hash algorithm %q", algorithm))
+	}
+
+	cryptoObj := s.vm.NewObject()
+	cryptoObj.Set("hash", func(algorithm, data string, encoding goja.Value) string {
+		return digest(hashFunc(algorithm)(), data, encoding)
+	})
+	cryptoObj.Set("hmac", func(algorithm, key, data string, encoding goja.Value) string {
+		return digest(hmac.New(hashFunc(algorithm), []byte(key)), data, encoding)
+	})
+	return cryptoObj
+}
+
+// requestObject exposes the request, headers are an object of name to value
+// and body is the raw body text
+func (s *session) requestObject(rq storage.RequestInput) *goja.Object {
+	request := s.vm.NewObject()
+	request.Set("method", rq.Method)
+	request.Set("url", rq.Path)
+	request.Set("body", rq.Body)
+
+	// repeated headers are arrays of their values
+	headers := s.vm.NewObject()
+	for name, values := range rq.Headers {
+		if len(values) == 1 {
+			headers.Set(name, values[0])
+		} else {
+			items := make([]interface{}, len(values))
+			for idx, v := range values {
+				items[idx] = v
+			}
+			headers.Set(name, s.vm.NewArray(items...))
+		}
+	}
+	request.Set("headers", headers)
+
+	// headerName returns the name the header is stored under, matched case-insensitively
+	headerName := func(name string) string {
+		current := request.Get("headers").ToObject(s.vm)
+		for _, key := range current.Keys() {
+			if strings.EqualFold(key, name) {
+				return key
+			}
+		}
+		return ""
+	}
+	request.Set("getHeader", func(name string) goja.Value {
+		if key := headerName(name); key != "" {
+			return request.Get("headers").ToObject(s.vm).Get(key)
+		}
+		return goja.Undefined()
+	})
+	request.Set("setHeader", func(name, value string) {
+		current := request.Get("headers").ToObject(s.vm)
+		if key := headerName(name); key != "" {
+			current.Delete(key)
+		}
+		current.Set(name, value)
+	})
+	request.Set("removeHeader", func(name string) {
+		if key := headerName(name); key != "" {
+			request.Get("headers").ToObject(s.vm).Delete(key)
+		}
+	})
+	return request
+}
+
+// readRequest copies the changes the script made to the request object into
+// rq, a changed body is sent as is instead of the body built from its mode
+func (s *session) readRequest(request *goja.Object, rq *storage.RequestInput) error {
+	rq.Method = strings.ToUpper(request.Get("method").String())
+	rq.Path = request.Get("url").String()
+
+	if body := request.Get("body").String(); body != rq.Body {
+		rq.Body = body
+		if rq.BodyMode != communication.BodyRaw {
+			rq.BodyMode = ""
+		}
+	}
+
+	headersVal := request.Get("headers")
+	if headersVal == nil || goja.IsUndefined(headersVal) || goja.IsNull(headersVal) {
+		return errors.New("request.headers must be an object")
+	}
+	headers := headersVal.ToObject(s.vm)
+	rq.Headers = make(map[string][]string)
+	for _, name := range headers.Keys() {
+		value := headers.Get(name)
+		if list, ok := value.Export().([]interface{}); ok {
+			for _, v := range list {
+				rq.Headers[name] = append(rq.Headers[name], fmt.Sprint(v))
+			}
+			continue
+		}
+		rq.Headers[name] = []string{value.String()}
+	}
+	return nil
+}
+
+// responseObject exposes the response, header names are lowercase
+func (s *session) responseObject(rr storage.RequestResult) *goja.Object {
+	response := s.vm.NewObject()
+	response.Set("status", rr.StatusCode)
+	response.Set("time", rr.Dur.Milliseconds())
+	response.Set("body", string(rr.ResponseBody))
+
+	headers := s.vm.NewObject()
+	for name, values := range rr.Headers {
+		headers.Set(strings.ToLower(name), strings.Join(values, ", "))
+	}
+	response.Set("headers", headers)
+	response.Set("json", func() goja.Value {
+		var doc interface{}
+		if err := json.Unmarshal(rr.ResponseBody, &doc); err != nil {
+			panic(s.vm.NewTypeError("the body is not valid JSON: %s", err))
+		}
+		return s.vm.ToValue(doc)
+	})
+	return response
+}
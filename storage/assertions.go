@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// Assertion kinds, each one checks a different part of the response
+const (
+	AssertStatus   = "status"
+	AssertHeader   = "header"
+	AssertTime     = "time"
+	AssertJSONPath = "jsonpath"
+	AssertXPath    = "xpath"
+	AssertBody     = "body"
+)
+
+// Assertion operators comparing the checked value with the expected one
+const (
+	OpEquals      = "equals"
+	OpNotEquals   = "not equals"
+	OpContains    = "contains"
+	OpMatches     = "matches"
+	OpExists      = "exists"
+	OpNotExists   = "not exists"
+	OpLessThan    = "less than"
+	OpGreaterThan = "greater than"
+)
+
+// AssertionKinds lists the assertion kinds in the order they are offered
+var AssertionKinds = []string{AssertStatus, AssertHeader, AssertTime, AssertJSONPath, AssertXPath, AssertBody}
+
+// AssertionOperators lists the assertion operators in the order they are offered
+var AssertionOperators = []string{OpEquals, OpNotEquals, OpContains, OpMatches, OpExists, OpNotExists, OpLessThan, OpGreaterThan}
+
+// Assertion is a check run against the response of a request
+type Assertion struct {
+	Kind string
+	// Target is the header name, or the JSONPath or XPath expression, the other kinds don't use it
+	Target   string
+	Operator string
+	// Expected is a number of milliseconds for time assertions, a regular
+	// expression for the matches operator and "2xx" style ranges are allowed for the status
+	Expected string
+	Disabled bool
+}
+
+// String describes the assertion, like `header Content-Type contains "json"`
+func (a Assertion) String() string {
+	subject := a.Kind
+	switch a.Kind {
+	case AssertHeader:
+		subject = "header " + a.Target
+	case AssertJSONPath, AssertXPath:
+		subject = a.Target
+	}
+	switch a.Operator {
+	case OpExists, OpNotExists:
+		return subject + " " + a.Operator
+	}
+	expected := a.Expected
+	if a.Kind == AssertTime {
+		expected += " ms"
+	} else if a.Kind == AssertHeader || a.Kind == AssertBody || a.Operator == OpMatches || a.Operator == OpContains {
+		expected = strconv.Quote(expected)
+	}
+	return subject + " " + a.Operator + " " + expected
+}
+
+// AssertionResult is the outcome of an assertion
+type AssertionResult struct {
+	Name   string
+	Passed bool
+	// Actual is the checked value, Error tells why it couldn't be read or compared
+	Actual string
+	Error  string
+}
+
+// TestsPassed counts the assertions of the response that passed
+func (rr RequestResult) TestsPassed() (passed, total int) {
+	for _, test := range rr.Tests {
+		if test.Passed {
+			passed++
+		}
+	}
+	return passed, len(rr.Tests)
+}
+
+// RunAssertions checks the response against the enabled assertions
+func RunAssertions(assertions []Assertion, rr RequestResult) []AssertionResult {
+	var results []AssertionResult
+	for _, a := range assertions {
+		if a.Disabled {
+			continue
+		}
+		result := AssertionResult{Name: a.String()}
+		actual, found, err := a.actual(rr)
+		if err == nil {
+			result.Actual = actual
+			result.Passed, err = a.compare(actual, found)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// actual reads the value the assertion checks, found is false when the response doesn't have it
+func (a Assertion) actual(rr RequestResult) (string, bool, error) {
+	switch a.Kind {
+	case AssertStatus:
+		return strconv.Itoa(rr.StatusCode), true, nil
+	case AssertHeader:
+		var values []string
+		for name, vals := range rr.Headers {
+			if strings.EqualFold(name, a.Target) {
+				values = append(values, vals...)
+			}
+		}
+		return strings.Join(values, ", "), len(values) > 0, nil
+	case AssertTime:
+		return strconv.FormatInt(rr.Dur.Milliseconds(), 10), true, nil
+	case AssertBody:
+		return string(rr.ResponseBody), true, nil
+	case AssertJSONPath:
+		return jsonPathValue(a.Target, rr.ResponseBody)
+	case AssertXPath:
+		return xPathValue(a.Target, rr.ResponseBody)
+	}
+	return "", false, fmt.Errorf("unknown assertion kind %q", a.Kind)
+}
+
+// compare applies the operator of the assertion to the checked value
+func (a Assertion) compare(actual string, found bool) (bool, error) {
+	switch a.Operator {
+	case OpExists:
+		return found, nil
+	case OpNotExists:
+		return !found, nil
+	}
+	if !found {
+		return false, errors.New("not found in the response")
+	}
+
+	switch a.Operator {
+	case OpEquals, OpNotEquals:
+		equal := valuesEqual(actual, a.Expected)
+		if a.Kind == AssertStatus {
+			equal = statusMatches(actual, a.Expected)
+		}
+		return equal == (a.Operator == OpEquals), nil
+	case OpContains:
+		return strings.Contains(actual, a.Expected), nil
+	case OpMatches:
+		re, err := regexp.Compile(a.Expected)
+		if err != nil {
+			return false, fmt.Errorf("invalid regular expression: %s", err)
+		}
+		return re.MatchString(actual), nil
+	case OpLessThan, OpGreaterThan:
+		have, err := strconv.ParseFloat(strings.TrimSpace(actual), 64)
+		if err != nil {
+			return false, fmt.Errorf("%q is not a number", actual)
+		}
+		want, err := strconv.ParseFloat(strings.TrimSpace(a.Expected), 64)
+		if err != nil {
+			return false, fmt.Errorf("expected value %q is not a number", a.Expected)
+		}
+		if a.Operator == OpLessThan {
+			return have < want, nil
+		}
+		return have > want, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", a.Operator)
+}
+
+// valuesEqual compares the values as numbers when both are, so 1.0 equals 1
+func valuesEqual(actual, expected string) bool {
+	have, errHave := strconv.ParseFloat(strings.TrimSpace(actual), 64)
+	want, errWant := strconv.ParseFloat(strings.TrimSpace(expected), 64)
+	if errHave == nil && errWant == nil {
+		return have == want
+	}
+	return actual == expected
+}
+
+// statusMatches compares a status code with an expected one, an x matches any digit
+func statusMatches(status, expected string) bool {
+	expected = strings.TrimSpace(expected)
+	if len(status) != len(expected) {
+		return false
+	}
+	for idx := range status {
+		if expected[idx] != status[idx] && expected[idx] != 'x' && expected[idx] != 'X' {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonPathValue evaluates the JSONPath expression on the body, lists and
+// objects are returned encoded as JSON
+func jsonPathValue(expr string, body []byte) (string, bool, error) {
+	eval, err := jsonpath.New(expr)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid JSONPath: %s", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", false, fmt.Errorf("the body is not valid JSON: %s", err)
+	}
+	value, err := eval(context.Background(), doc)
+	if err != nil {
+		// missing keys and indexes out of range are reported as errors
+		return "", false, nil
+	}
+	// wildcards, deep scans and filters that match nothing return an empty list
+	if list, ok := value.([]interface{}); ok && len(list) == 0 && (strings.Contains(expr, "*") || strings.Contains(expr, "..") || strings.Contains(expr, "[?")) {
+		return "", false, nil
+	}
+	return jsonString(value), true, nil
+}
+
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return "null"
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// xPathValue evaluates the XPath expression on the body, node sets return the
+// text of their first node
+func xPathValue(expr string, body []byte) (value string, found bool, err error) {
+	compiled, err := xpath.Compile(expr)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid XPath: %s", err)
+	}
+	doc, err := xmlquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		return "", false, fmt.Errorf("the body is not valid XML: %s", err)
+	}
+	defer func() {
+		// the evaluation panics on some invalid function arguments
+		if r := recover(); r != nil {
+			value, found, err = "", false, fmt.Errorf("unable to evaluate the XPath: %v", r)
+		}
+	}()
+
+	switch v := compiled.Evaluate(xmlquery.CreateXPathNavigator(doc)).(type) {
+	case *xpath.NodeIterator:
+		if !v.MoveNext() {
+			return "", false, nil
+		}
+		return v.Current().Value(), true, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true, nil
+	case bool:
+		return strconv.FormatBool(v), true, nil
+	case string:
+		return v, true, nil
+	}
+	return "", false, nil
+}
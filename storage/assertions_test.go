@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestRunAssertionsJSONPath(t *testing.T) {
+	body := []byte(`{"items": [], "users": [{"name": "ann", "admin": true}], "meta": {"total": 0}}`)
+	tests := []struct {
+		name      string
+		assertion Assertion
+		want      bool
+	}{
+		{"empty list equals []", Assertion{Kind: AssertJSONPath, Target: "$.items", Operator: OpEquals, Expected: "[]"}, true},
+		{"empty list exists", Assertion{Kind: AssertJSONPath, Target: "$.items", Operator: OpExists}, true},
+		{"nested value", Assertion{Kind: AssertJSONPath, Target: "$.meta.total", Operator: OpEquals, Expected: "0"}, true},
+		{"missing key", Assertion{Kind: AssertJSONPath, Target: "$.missing", Operator: OpNotExists}, true},
+		{"wildcard without matches", Assertion{Kind: AssertJSONPath, Target: "$.items[*].id", Operator: OpNotExists}, true},
+		{"deep scan without matches", Assertion{Kind: AssertJSONPath, Target: "$..id", Operator: OpExists}, false},
+		{"filter without matches", Assertion{Kind: AssertJSONPath, Target: "$.users[?(@.admin == false)]", Operator: OpNotExists}, true},
+		{"filter with a match", Assertion{Kind: AssertJSONPath, Target: "$.users[?(@.admin == true)].name", Operator: OpContains, Expected: "ann"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := RunAssertions([]Assertion{tt.assertion}, RequestResult{ResponseBody: body})
+			if len(results) != 1 {
+				t.Fatalf("RunAssertions() returned %d results, want 1", len(results))
+			}
+			if results[0].Passed != tt.want {
+				t.Errorf("passed = %v, want %v (actual %q, error %q)", results[0].Passed, tt.want, results[0].Actual, results[0].Error)
+			}
+		})
+	}
+}
+
+func TestRunExtractionsEmptyList(t *testing.T) {
+	results := RunExtractions([]Extraction{{Variable: "items", Source: ExtractJSONPath, Expression: "$.items"}}, RequestResult{ResponseBody: []byte(`{"items": []}`)})
+	if len(results) != 1 || results[0].Error != "" || results[0].Value != "[]" {
+		t.Errorf("RunExtractions() = %+v, want the value []", results)
+	}
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xujiajun/nutsdb"
+)
+
+// CollectionItem is a collection, a folder or a saved request. Collections
+// are the root items, folders and requests are nested inside them.
+type CollectionItem struct {
+	ID   string
+	Name string
+	// Request is nil for collections and folders
+	Request *RequestInput
+	Items   []*CollectionItem
+	// Position orders the collections, nested items keep the order of Items
+	Position int
+}
+
+// IsFolder reports whether the item holds other items rather than a request
+func (ci *CollectionItem) IsFolder() bool {
+	return ci.Request == nil
+}
+
+// Find returns the item with the given ID from the tree rooted at ci
+func (ci *CollectionItem) Find(id string) *CollectionItem {
+	if ci.ID == id {
+		return ci
+	}
+	for _, item := range ci.Items {
+		if found := item.Find(id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Remove deletes the item with the given ID from the tree rooted at ci
+func (ci *CollectionItem) Remove(id string) bool {
+	for idx, item := range ci.Items {
+		if item.ID == id {
+			ci.Items = append(ci.Items[:idx], ci.Items[idx+1:]...)
+			return true
+		}
+		if item.Remove(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCollectionItem creates an item with a new ID, request is nil for collections and folders
+func NewCollectionItem(name string, request *RequestInput) *CollectionItem {
+	return &CollectionItem{
+		ID:      newItemID(),
+		Name:    name,
+		Request: request,
+	}
+}
+
+// lastItemID makes sure IDs created within the same nanosecond differ
+var lastItemID int64
+
+func newItemID() string {
+	id := time.Now().UnixNano()
+	if id <= lastItemID {
+		id = lastItemID + 1
+	}
+	lastItemID = id
+	return strconv.FormatInt(id, 36)
+}
+
+// CollectionStorage persists the saved request collections, each collection
+// is stored with its folders and requests under its own key
+type CollectionStorage struct {
+	db *nutsdb.DB
+}
+
+const bucketNameCollections = "collections"
+
+// DefaultCollectionName names the collection created when saving without one
+const DefaultCollectionName = "My requests"
+
+func SetupCollections(db *nutsdb.DB) CollectionStorage {
+	return CollectionStorage{
+		db,
+	}
+}
+
+// GetAll returns every collection in the order they are listed in
+func (c *CollectionStorage) GetAll() []*CollectionItem {
+	var collections []*CollectionItem
+	if err := c.db.View(
+		func(tx *nutsdb.Tx) error {
+			entries, err := tx.GetAll(bucketNameCollections)
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range entries {
+				var collection CollectionItem
+				err = json.Unmarshal(entry.Value, &collection)
+				if err != nil {
+					return err
+				}
+				collections = append(collections, &collection)
+			}
+
+			return nil
+		}); err != nil {
+		if err == nutsdb.ErrBucketEmpty {
+			return collections
+		} else {
+			log.Fatal(err)
+		}
+	}
+	sort.SliceStable(collections, func(i, j int) bool {
+		return collections[i].Position < collections[j].Position
+	})
+	return collections
+}
+
+// Put stores the collection, replacing the one with the same ID
+func (c *CollectionStorage) Put(collection *CollectionItem) {
+	val, err := json.Marshal(collection)
+	if err != nil {
+		log.Fatal("Error marshaling collection data: ", err)
+	}
+	if err := c.db.Update(
+		func(tx *nutsdb.Tx) error {
+			if err := tx.Put(bucketNameCollections, []byte(collection.ID), val, 0); err != nil {
+				return err
+			}
+			return nil
+		}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (c *CollectionStorage) Remove(id string) {
+	if err := c.db.Update(
+		func(tx *nutsdb.Tx) error {
+			if err := tx.Delete(bucketNameCollections, []byte(id)); err != nil {
+				return err
+			}
+			return nil
+		}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// SaveAll replaces the stored collections, their positions follow the slice order
+func (c *CollectionStorage) SaveAll(collections []*CollectionItem) {
+	keep := map[string]bool{}
+	for idx, collection := range collections {
+		collection.Position = idx
+		keep[collection.ID] = true
+		c.Put(collection)
+	}
+	for _, collection := range c.GetAll() {
+		if !keep[collection.ID] {
+			c.Remove(collection.ID)
+		}
+	}
+}
+
+// NormalizeCollections fixes a tree rearranged by the user: items dropped
+// onto a request are moved after it and requests dropped outside of any
+// collection are moved into the closest one
+func NormalizeCollections(roots []*CollectionItem) []*CollectionItem {
+	var collections []*CollectionItem
+	var orphans []*CollectionItem
+	for _, root := range flattenRequests(roots) {
+		if !root.IsFolder() {
+			if len(collections) == 0 {
+				orphans = append(orphans, root)
+			} else {
+				last := collections[len(collections)-1]
+				last.Items = append(last.Items, root)
+			}
+			continue
+		}
+		if len(collections) == 0 {
+			root.Items = append(orphans, root.Items...)
+			orphans = nil
+		}
+		collections = append(collections, root)
+	}
+	if len(orphans) > 0 {
+		collection := NewCollectionItem(DefaultCollectionName, nil)
+		collection.Items = orphans
+		collections = append(collections, collection)
+	}
+	return collections
+}
+
+// flattenRequests moves the items nested in requests after them
+func flattenRequests(items []*CollectionItem) []*CollectionItem {
+	var out []*CollectionItem
+	for _, item := range items {
+		nested := flattenRequests(item.Items)
+		if item.IsFolder() {
+			item.Items = nested
+			out = append(out, item)
+			continue
+		}
+		item.Items = nil
+		out = append(out, item)
+		out = append(out, nested...)
+	}
+	return out
+}
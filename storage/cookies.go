@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xujiajun/nutsdb"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Cookie is a cookie persisted in the cookie jar
+type Cookie struct {
+	Name     string
+	Value    string
+	Domain   string
+	Path     string
+	Expires  time.Time
+	Secure   bool
+	HttpOnly bool
+	// HostOnly cookies are only sent to the exact host that set them
+	HostOnly bool
+}
+
+// Key identifies the cookie in the jar, a cookie replaces another one with the same key
+func (c Cookie) Key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+// Expired reports whether the cookie expired at the provided time. Session
+// cookies never expire, they are kept until removed.
+func (c Cookie) Expired(at time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(at)
+}
+
+// CookieStorage is a persistent http.CookieJar
+type CookieStorage struct {
+	db *nutsdb.DB
+}
+
+const bucketNameCookies = "cookies"
+
+func SetupCookies(db *nutsdb.DB) CookieStorage {
+	return CookieStorage{
+		db,
+	}
+}
+
+// GetAll returns every stored cookie sorted by domain, path and name
+func (c *CookieStorage) GetAll() []Cookie {
+	var cookies []Cookie
+	if err := c.db.View(
+		func(tx *nutsdb.Tx) error {
+			entries, err := tx.GetAll(bucketNameCookies)
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range entries {
+				var cookie Cookie
+				err = json.Unmarshal(entry.Value, &cookie)
+				if err != nil {
+					return err
+				}
+				cookies = append(cookies, cookie)
+			}
+
+			return nil
+		}); err != nil {
+		if err == nutsdb.ErrBucketEmpty {
+			return cookies
+		} else {
+			log.Fatal(err)
+		}
+	}
+	sort.Slice(cookies, func(i, j int) bool {
+		return cookies[i].Key() < cookies[j].Key()
+	})
+	return cookies
+}
+
+// Put stores the cookie, replacing the one with the same key
+func (c *CookieStorage) Put(cookie Cookie) {
+	val, err := json.Marshal(cookie)
+	if err != nil {
+		log.Fatal("Error marshaling cookie data: ", err)
+	}
+	if err := c.db.Update(
+		func(tx *nutsdb.Tx) error {
+			if err := tx.Put(bucketNameCookies, []byte(cookie.Key()), val, 0); err != nil {
+				return err
+			}
+			return nil
+		}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (c *CookieStorage) Remove(cookie Cookie) {
+	if err := c.db.Update(
+		func(tx *nutsdb.Tx) error {
+			if err := tx.Delete(bucketNameCookies, []byte(cookie.Key())); err != nil {
+				return err
+			}
+			return nil
+		}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (c *CookieStorage) RemoveAll() {
+	c.removeCookies(c.GetAll())
+}
+
+func (c *CookieStorage) removeCookies(list []Cookie) {
+	if len(list) == 0 {
+		return
+	}
+	if err := c.db.Update(
+		func(tx *nutsdb.Tx) error {
+			for _, cookie := range list {
+				if err := tx.Delete(bucketNameCookies, []byte(cookie.Key())); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// SetCookies implements http.CookieJar
+func (c *CookieStorage) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+
+	for _, hc := range cookies {
+		cookie := Cookie{
+			Name:     hc.Name,
+			Value:    hc.Value,
+			Domain:   host,
+			Path:     hc.Path,
+			Secure:   hc.Secure,
+			HttpOnly: hc.HttpOnly,
+			HostOnly: true,
+		}
+
+		if hc.Domain != "" {
+			domain := strings.TrimPrefix(strings.ToLower(hc.Domain), ".")
+			if !domainMatch(host, domain) {
+				log.Printf("Rejecting cookie %s for domain %s set by %s", hc.Name, hc.Domain, host)
+				continue
+			}
+			if isPublicSuffix(domain) {
+				// A public suffix is only accepted as the host itself, like net/http/cookiejar does
+				if domain != host {
+					log.Printf("Rejecting cookie %s for public suffix %s set by %s", hc.Name, hc.Domain, host)
+					continue
+				}
+			} else {
+				cookie.Domain = domain
+				cookie.HostOnly = false
+			}
+		}
+
+		if cookie.Path == "" || !strings.HasPrefix(cookie.Path, "/") {
+			cookie.Path = defaultCookiePath(u.Path)
+		}
+
+		switch {
+		case hc.MaxAge < 0:
+			cookie.Expires = now
+		case hc.MaxAge > 0:
+			cookie.Expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+		case !hc.Expires.IsZero():
+			cookie.Expires = hc.Expires
+		}
+
+		if cookie.Expired(now) {
+			c.Remove(cookie)
+		} else {
+			c.Put(cookie)
+		}
+	}
+}
+
+// Cookies implements http.CookieJar
+func (c *CookieStorage) Cookies(u *url.URL) []*http.Cookie {
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+
+	var matched, expired []Cookie
+	for _, cookie := range c.GetAll() {
+		if cookie.Expired(now) {
+			expired = append(expired, cookie)
+			continue
+		}
+		if cookie.HostOnly && host != cookie.Domain {
+			continue
+		}
+		if !cookie.HostOnly && !domainMatch(host, cookie.Domain) {
+			continue
+		}
+		if !pathMatch(path, cookie.Path) {
+			continue
+		}
+		if cookie.Secure && u.Scheme != "https" {
+			continue
+		}
+		matched = append(matched, cookie)
+	}
+
+	c.removeCookies(expired)
+
+	// Cookies with longer paths are listed first
+	sort.SliceStable(matched, func(i, j int) bool {
+		return len(matched[i].Path) > len(matched[j].Path)
+	})
+
+	cookies := make([]*http.Cookie, 0, len(matched))
+	for _, cookie := range matched {
+		cookies = append(cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	return cookies
+}
+
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// isPublicSuffix reports whether cookies for the domain would be shared by
+// unrelated sites, e.g. com or co.uk
+func isPublicSuffix(domain string) bool {
+	if net.ParseIP(domain) != nil {
+		return false
+	}
+	_, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	return err != nil
+}
+
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+func defaultCookiePath(requestPath string) string {
+	idx := strings.LastIndex(requestPath, "/")
+	if idx <= 0 {
+		return "/"
+	}
+	return requestPath[:idx]
+}
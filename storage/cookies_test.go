@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xujiajun/nutsdb"
+)
+
+func testCookieStorage(t *testing.T) *CookieStorage {
+	opt := nutsdb.DefaultOptions
+	opt.Dir = t.TempDir()
+	db, err := nutsdb.Open(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	cs := SetupCookies(db)
+	return &cs
+}
+
+func cookieNames(cookies []*http.Cookie) string {
+	var names []string
+	for _, cookie := range cookies {
+		names = append(names, cookie.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestCookieStorageDomains(t *testing.T) {
+	tests := []struct {
+		name   string
+		setBy  string
+		domain string
+		sentTo map[string]bool
+	}{
+		{
+			name:   "host only",
+			setBy:  "https://a.example.com/",
+			sentTo: map[string]bool{"https://a.example.com/": true, "https://b.a.example.com/": false, "https://example.com/": false},
+		},
+		{
+			name:   "parent domain",
+			setBy:  "https://a.example.com/",
+			domain: ".example.com",
+			sentTo: map[string]bool{"https://a.example.com/": true, "https://b.example.com/": true, "https://other.com/": false},
+		},
+		{
+			name:   "top level domain",
+			setBy:  "https://a.example.com/",
+			domain: "com",
+			sentTo: map[string]bool{"https://a.example.com/": false, "https://other.com/": false},
+		},
+		{
+			name:   "multi label public suffix",
+			setBy:  "https://shop.example.co.uk/",
+			domain: "co.uk",
+			sentTo: map[string]bool{"https://shop.example.co.uk/": false, "https://other.co.uk/": false},
+		},
+		{
+			name:   "public suffix set by itself",
+			setBy:  "https://github.io/",
+			domain: "github.io",
+			sentTo: map[string]bool{"https://github.io/": true, "https://user.github.io/": false},
+		},
+		{
+			name:   "unrelated domain",
+			setBy:  "https://a.example.com/",
+			domain: "other.com",
+			sentTo: map[string]bool{"https://other.com/": false, "https://a.example.com/": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := testCookieStorage(t)
+			u, _ := url.Parse(tt.setBy)
+			cs.SetCookies(u, []*http.Cookie{{Name: "id", Value: "1", Domain: tt.domain}})
+			for target, want := range tt.sentTo {
+				u, _ := url.Parse(target)
+				if got := len(cs.Cookies(u)) == 1; got != want {
+					t.Errorf("sent to %s = %v, want %v", target, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCookieStoragePurgesExpired(t *testing.T) {
+	cs := testCookieStorage(t)
+	u, _ := url.Parse("https://example.com/")
+	cs.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "1"},
+		{Name: "short", Value: "1", Expires: time.Now().Add(time.Second)},
+		{Name: "gone", Value: "1", MaxAge: -1},
+	})
+	if got := len(cs.GetAll()); got != 2 {
+		t.Fatalf("stored %d cookies, want 2", got)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if got := cookieNames(cs.Cookies(u)); got != "session" {
+		t.Errorf("Cookies() = %q, want %q", got, "session")
+	}
+	if got := cs.GetAll(); len(got) != 1 || got[0].Name != "session" {
+		t.Errorf("expired cookies are still stored: %v", got)
+	}
+}
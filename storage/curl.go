@@ -0,0 +1,518 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lnenad/probster/communication"
+)
+
+// curlArgOptions are the curl options that take an argument, mapped to their long names
+var curlArgOptions = map[string]string{
+	"-X": "--request",
+	"-H": "--header",
+	"-d": "--data",
+	"-F": "--form",
+	"-u": "--user",
+	"-A": "--user-agent",
+	"-e": "--referer",
+	"-b": "--cookie",
+	"-x": "--proxy",
+	"-m": "--max-time",
+	"-o": "--output",
+	"-w": "--write-out",
+	"-c": "--cookie-jar",
+	"-E": "--cert",
+	"-T": "--upload-file",
+	"-r": "--range",
+}
+
+// curlIgnoredArgOptions take an argument that has no meaning for the request editor
+var curlIgnoredArgOptions = map[string]bool{
+	"--output": true, "--write-out": true, "--cookie-jar": true, "--retry": true,
+	"--retry-delay": true, "--retry-max-time": true, "--trace": true, "--trace-ascii": true,
+	"--stderr": true, "--dump-header": true, "--limit-rate": true, "--interface": true,
+	"--resolve": true, "--connect-to": true, "--ciphers": true,
+}
+
+// curlFlags are the options without an argument, mapped to their long names
+var curlFlags = map[string]string{
+	"-k": "--insecure",
+	"-L": "--location",
+	"-G": "--get",
+	"-I": "--head",
+	"-s": "--silent",
+	"-S": "--show-error",
+	"-v": "--verbose",
+	"-i": "--include",
+	"-f": "--fail",
+	"-g": "--globoff",
+	"-N": "--no-buffer",
+	"-#": "--progress-bar",
+	"-0": "--http1.0",
+	"-1": "--tlsv1",
+	"-2": "--sslv2",
+	"-3": "--sslv3",
+	"-4": "--ipv4",
+	"-6": "--ipv6",
+}
+
+// ParseCurl reads a curl command line into a request
+func ParseCurl(command string) (RequestInput, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return RequestInput{}, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return RequestInput{}, errors.New("the command doesn't start with curl")
+	}
+
+	input := RequestInput{
+		Headers:   map[string][]string{},
+		Timeouts:  communication.DefaultTimeouts,
+		Redirects: communication.RedirectPolicy{Mode: communication.RedirectNone},
+	}
+	var (
+		rawURL     string
+		method     string
+		data       []string
+		dataFile   string
+		get, head  bool
+		follow     bool
+		maxRedirs  = -1
+		parts      []communication.FormPart
+		hasContent bool
+		user       *string
+		digest     bool
+	)
+
+	// option applies a single option, value is ignored by the flags
+	option := func(name, value string) error {
+		switch name {
+		case "--url":
+			rawURL = value
+		case "--request":
+			method = strings.ToUpper(value)
+		case "--header":
+			idx := strings.Index(value, ":")
+			if idx < 0 {
+				// "Name;" sends the header without a value
+				if strings.HasSuffix(value, ";") {
+					addHeader(&input, strings.TrimSuffix(value, ";"), "")
+					return nil
+				}
+				return fmt.Errorf("invalid header %q", value)
+			}
+			name, headerValue := strings.TrimSpace(value[:idx]), strings.TrimSpace(value[idx+1:])
+			if headerValue == "" {
+				// "Name:" removes a header curl would add
+				return nil
+			}
+			addHeader(&input, name, headerValue)
+			hasContent = hasContent || strings.EqualFold(name, "Content-Type")
+		case "--data", "--data-ascii", "--data-binary":
+			if !strings.HasPrefix(value, "@") {
+				// literal values are sent byte for byte
+				data = append(data, value)
+				return nil
+			}
+			if name != "--data-binary" {
+				// curl strips the line breaks of the file, it is read now to do the same
+				if content, err := ioutil.ReadFile(value[1:]); err == nil {
+					data = append(data, strings.NewReplacer("\r", "", "\n", "").Replace(string(content)))
+					return nil
+				}
+			}
+			dataFile = value[1:]
+		case "--data-raw":
+			data = append(data, value)
+		case "--data-urlencode":
+			data = append(data, curlURLEncode(value))
+		case "--json":
+			data = append(data, value)
+			setHeader(&input, "Content-Type", "application/json")
+			setHeader(&input, "Accept", "application/json")
+			hasContent = true
+		case "--form", "--form-string":
+			idx := strings.Index(value, "=")
+			if idx < 0 {
+				return fmt.Errorf("invalid form field %q", value)
+			}
+			part := communication.FormPart{Key: value[:idx], Value: value[idx+1:]}
+			if name == "--form" && (strings.HasPrefix(part.Value, "@") || strings.HasPrefix(part.Value, "<")) {
+				part.File = true
+				part.Value = part.Value[1:]
+				// ;type= and ;filename= are not kept
+				if semi := strings.Index(part.Value, ";"); semi >= 0 {
+					part.Value = part.Value[:semi]
+				}
+			}
+			parts = append(parts, part)
+		case "--user":
+			user = &value
+		case "--digest":
+			digest = true
+		case "--basic":
+			digest = false
+		case "--user-agent":
+			setHeader(&input, "User-Agent", value)
+		case "--referer":
+			setHeader(&input, "Referer", value)
+		case "--cookie":
+			// without a "=" the value names a cookie file
+			if strings.Contains(value, "=") {
+				addHeader(&input, "Cookie", value)
+			}
+		case "--proxy":
+			input.Proxy = communication.ProxyOverride{Mode: communication.ProxyCustom, URL: value}
+		case "--noproxy":
+			if value == "*" {
+				input.Proxy = communication.ProxyOverride{Mode: communication.ProxyDirect}
+			}
+		case "--max-time", "--connect-timeout":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s value %q", name, value)
+			}
+			if name == "--max-time" {
+				input.Timeouts.Total = time.Duration(seconds * float64(time.Second))
+			} else {
+				input.Timeouts.Connect = time.Duration(seconds * float64(time.Second))
+			}
+		case "--max-redirs":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid --max-redirs value %q", value)
+			}
+			maxRedirs = n
+		case "--cacert":
+			input.TLS.CAFiles = append(input.TLS.CAFiles, value)
+		case "--cert":
+			input.TLS.ClientCertFile = value
+		case "--key":
+			input.TLS.ClientKeyFile = value
+		case "--insecure":
+			input.TLS.Verification = communication.TLSSkipVerify
+		case "--location":
+			follow = true
+		case "--get":
+			get = true
+		case "--head":
+			head = true
+		case "--compressed":
+			// responses are always decompressed
+		case "--upload-file":
+			input.BodyMode = communication.BodyBinary
+			input.BodyFile = value
+			if method == "" {
+				method = http.MethodPut
+			}
+		}
+		return nil
+	}
+
+	for idx := 1; idx < len(args); idx++ {
+		arg := args[idx]
+		next := func() (string, error) {
+			idx++
+			if idx >= len(args) {
+				return "", fmt.Errorf("option %s needs a value", arg)
+			}
+			return args[idx], nil
+		}
+
+		switch {
+		case arg == "--":
+			if idx+1 < len(args) {
+				rawURL = args[idx+1]
+			}
+			idx = len(args)
+		case strings.HasPrefix(arg, "--"):
+			name, value, inline := arg, "", false
+			if eq := strings.Index(arg, "="); eq >= 0 {
+				name, value, inline = arg[:eq], arg[eq+1:], true
+			}
+			if curlLongFlag(name) {
+				if err := option(name, ""); err != nil {
+					return RequestInput{}, err
+				}
+				continue
+			}
+			if !curlIgnoredArgOptions[name] && !curlKnownArgOption(name) {
+				return RequestInput{}, fmt.Errorf("unsupported curl option %s", name)
+			}
+			if !inline {
+				var err error
+				if value, err = next(); err != nil {
+					return RequestInput{}, err
+				}
+			}
+			if curlIgnoredArgOptions[name] {
+				continue
+			}
+			if err := option(name, value); err != nil {
+				return RequestInput{}, err
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// short options can be combined, like -sSL or -XPOST
+			for pos := 1; pos < len(arg); pos++ {
+				short := "-" + string(arg[pos])
+				if long, ok := curlFlags[short]; ok {
+					if err := option(long, ""); err != nil {
+						return RequestInput{}, err
+					}
+					continue
+				}
+				long, ok := curlArgOptions[short]
+				if !ok {
+					return RequestInput{}, fmt.Errorf("unsupported curl option %s", short)
+				}
+				value := arg[pos+1:]
+				if value == "" {
+					var err error
+					if value, err = next(); err != nil {
+						return RequestInput{}, err
+					}
+				}
+				if !curlIgnoredArgOptions[long] {
+					if err := option(long, value); err != nil {
+						return RequestInput{}, err
+					}
+				}
+				break
+			}
+		default:
+			if rawURL == "" {
+				rawURL = arg
+			}
+		}
+	}
+
+	if rawURL == "" {
+		return RequestInput{}, errors.New("the curl command has no URL")
+	}
+	// curl defaults to http when the URL has no scheme
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	input.Path = rawURL
+
+	switch {
+	case get && len(data) > 0:
+		// curl appends the data to the query as written
+		separator := "?"
+		if strings.Contains(input.Path, "?") {
+			separator = "&"
+		}
+		input.Path += separator + strings.Join(data, "&")
+	case len(parts) > 0:
+		input.BodyMode = communication.BodyMultipart
+		input.BodyParts = parts
+		dropContentType(&input)
+	case dataFile != "":
+		input.BodyMode = communication.BodyBinary
+		input.BodyFile = dataFile
+	case len(data) > 0:
+		input.BodyMode = communication.BodyRaw
+		input.Body = strings.Join(data, "&")
+		input.RawType = rawTypeOf(resolveHeader(input.Headers, "Content-Type"))
+		if !hasContent {
+			// curl sends data as a form unless a content type is given
+			setHeader(&input, "Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+
+	switch {
+	case method != "":
+		input.Method = method
+	case head:
+		input.Method = http.MethodHead
+	case input.BodyMode != "" && !get:
+		input.Method = http.MethodPost
+	default:
+		input.Method = http.MethodGet
+	}
+	if input.BodyMode != "" {
+		input.ForceBody = !communication.MethodHasBody(input.Method)
+	}
+
+	if follow {
+		input.Redirects = communication.RedirectPolicy{Mode: communication.RedirectFollow}
+		if maxRedirs >= 0 {
+			input.Redirects = communication.RedirectPolicy{Mode: communication.RedirectLimit, Max: maxRedirs}
+		}
+	}
+	// an Authorization header given with -H replaces the one of --user
+	if user != nil && resolveHeader(input.Headers, "Authorization") == "" {
+		// curl asks for the password when it is left out
+		username, password := *user, ""
+		if idx := strings.Index(username, ":"); idx >= 0 {
+			username, password = username[:idx], username[idx+1:]
+		}
+		input.Auth = communication.Auth{Scheme: communication.AuthBasic, Username: username, Password: password}
+		if digest {
+			input.Auth.Scheme = communication.AuthDigest
+		}
+	}
+	return input, nil
+}
+
+func curlLongFlag(name string) bool {
+	for _, long := range curlFlags {
+		if long == name {
+			return true
+		}
+	}
+	switch name {
+	case "--compressed", "--http1.1", "--http2", "--http2-prior-knowledge", "--http3",
+		"--tlsv1.0", "--tlsv1.1", "--tlsv1.2", "--tlsv1.3", "--no-keepalive", "--no-progress-meter",
+		"--path-as-is", "--ssl", "--ssl-reqd", "--tcp-nodelay", "--no-sessionid", "--digest", "--basic":
+		return true
+	}
+	return false
+}
+
+func curlKnownArgOption(name string) bool {
+	for _, long := range curlArgOptions {
+		if long == name {
+			return true
+		}
+	}
+	switch name {
+	case "--url", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode", "--json",
+		"--form-string", "--connect-timeout", "--max-redirs", "--cacert", "--key", "--noproxy":
+		return true
+	}
+	return false
+}
+
+// curlURLEncode encodes a --data-urlencode value the way curl does
+func curlURLEncode(value string) string {
+	if idx := strings.Index(value, "="); idx >= 0 {
+		name := value[:idx]
+		if name == "" {
+			return curlEscape(value[idx+1:])
+		}
+		return name + "=" + curlEscape(value[idx+1:])
+	}
+	return curlEscape(value)
+}
+
+// curlEscape percent-encodes everything but the unreserved characters, spaces
+// become %20 rather than the + of url.QueryEscape
+func curlEscape(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
+
+// splitCommand splits a shell command into its arguments, following the
+// quoting rules of POSIX shells and the $'...' strings of bash
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	runes := []rune(command)
+
+	for idx := 0; idx < len(runes); idx++ {
+		r := runes[idx]
+		switch {
+		case r == '\\':
+			if idx+1 >= len(runes) {
+				continue
+			}
+			idx++
+			// a backslash before a line break continues the command
+			if runes[idx] == '\n' {
+				continue
+			}
+			if runes[idx] == '\r' && idx+1 < len(runes) && runes[idx+1] == '\n' {
+				idx++
+				continue
+			}
+			current.WriteRune(runes[idx])
+			inArg = true
+		case r == '\'':
+			end := indexRune(runes, idx+1, '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			current.WriteString(string(runes[idx+1 : end]))
+			idx = end
+			inArg = true
+		case r == '$' && idx+1 < len(runes) && runes[idx+1] == '\'':
+			idx += 2
+			for ; idx < len(runes) && runes[idx] != '\''; idx++ {
+				if runes[idx] == '\\' && idx+1 < len(runes) {
+					idx++
+					current.WriteString(ansiEscape(runes[idx]))
+					continue
+				}
+				current.WriteRune(runes[idx])
+			}
+			if idx >= len(runes) {
+				return nil, errors.New("unterminated $' quote")
+			}
+			inArg = true
+		case r == '"':
+			idx++
+			for ; idx < len(runes) && runes[idx] != '"'; idx++ {
+				// only these characters can be escaped in double quotes
+				if runes[idx] == '\\' && idx+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[idx+1]) {
+					idx++
+					if runes[idx] == '\n' {
+						continue
+					}
+				}
+				current.WriteRune(runes[idx])
+			}
+			if idx >= len(runes) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// ansiEscape returns the character of a backslash escape in a $'...' string
+func ansiEscape(r rune) string {
+	switch r {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	case '0':
+		return "\x00"
+	case 'e', 'E':
+		return "\x1b"
+	}
+	return string(r)
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for idx := from; idx < len(runes); idx++ {
+		if runes[idx] == r {
+			return idx
+		}
+	}
+	return -1
+}
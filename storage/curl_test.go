@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/lnenad/probster/communication"
+)
+
+func TestParseCurl(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		wantMethod  string
+		wantPath    string
+		wantBody    string
+		wantContent string
+		wantAuth    communication.Auth
+	}{
+		{
+			name:       "plain GET",
+			command:    `curl https://api.example.com/users`,
+			wantMethod: "GET",
+			wantPath:   "https://api.example.com/users",
+		},
+		{
+			name:        "--data-urlencode with spaces",
+			command:     `curl https://api.example.com/search --data-urlencode 'q=hello world'`,
+			wantMethod:  "POST",
+			wantPath:    "https://api.example.com/search",
+			wantBody:    "q=hello%20world",
+			wantContent: "application/x-www-form-urlencoded",
+		},
+		{
+			name:        "--data-urlencode with reserved characters",
+			command:     `curl https://api.example.com/search --data-urlencode 'q=a+b&c=d/é~' --data-urlencode '=only value' --data-urlencode 'bare value'`,
+			wantMethod:  "POST",
+			wantPath:    "https://api.example.com/search",
+			wantBody:    "q=a%2Bb%26c%3Dd%2F%C3%A9~&only%20value&bare%20value",
+			wantContent: "application/x-www-form-urlencoded",
+		},
+		{
+			name:        "--data is sent as written",
+			command:     `curl -d 'a=1 2' -d b=3 -H 'Content-Type: text/plain' https://api.example.com`,
+			wantMethod:  "POST",
+			wantPath:    "https://api.example.com",
+			wantBody:    "a=1 2&b=3",
+			wantContent: "text/plain",
+		},
+		{
+			name:        "multi-line --data is kept",
+			command:     "curl https://api.example.com/events -H 'Content-Type: application/x-ndjson' -d '{\"a\":1}\n{\"b\":\"x\\ny\"}\r\n'",
+			wantMethod:  "POST",
+			wantPath:    "https://api.example.com/events",
+			wantBody:    "{\"a\":1}\n{\"b\":\"x\\ny\"}\r\n",
+			wantContent: "application/x-ndjson",
+		},
+		{
+			name:       "--data-urlencode with --get",
+			command:    `curl -G https://api.example.com/search --data-urlencode 'q=hello world'`,
+			wantMethod: "GET",
+			wantPath:   "https://api.example.com/search?q=hello%20world",
+		},
+		{
+			name:       "--get with a query",
+			command:    `curl --get 'https://api.example.com/search?page=2' -d 'q=a b'`,
+			wantMethod: "GET",
+			wantPath:   "https://api.example.com/search?page=2&q=a b",
+		},
+		{
+			name:       "combined short options",
+			command:    `curl -sSL -XDELETE -u bob:secret https://api.example.com/users/1`,
+			wantMethod: "DELETE",
+			wantPath:   "https://api.example.com/users/1",
+			wantAuth:   communication.Auth{Scheme: communication.AuthBasic, Username: "bob", Password: "secret"},
+		},
+		{
+			name:       "digest auth",
+			command:    `curl --digest --user bob:secret example.com`,
+			wantMethod: "GET",
+			wantPath:   "http://example.com",
+			wantAuth:   communication.Auth{Scheme: communication.AuthDigest, Username: "bob", Password: "secret"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := ParseCurl(tt.command)
+			if err != nil {
+				t.Fatalf("ParseCurl() error = %v", err)
+			}
+			if input.Method != tt.wantMethod {
+				t.Errorf("method = %q, want %q", input.Method, tt.wantMethod)
+			}
+			if input.Path != tt.wantPath {
+				t.Errorf("path = %q, want %q", input.Path, tt.wantPath)
+			}
+			if input.Body != tt.wantBody {
+				t.Errorf("body = %q, want %q", input.Body, tt.wantBody)
+			}
+			if got := resolveHeader(input.Headers, "Content-Type"); got != tt.wantContent {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContent)
+			}
+			if input.Auth != tt.wantAuth {
+				t.Errorf("auth = %+v, want %+v", input.Auth, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestParseCurlDataFiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "body.txt")
+	if err := ioutil.WriteFile(file, []byte("a=1\r\n&b=2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	tests := []struct {
+		name     string
+		command  string
+		wantBody string
+		wantFile string
+	}{
+		{"--data strips the line breaks of the file", "curl https://example.com -d @" + file + " -d c=3", "a=1&b=2&c=3", ""},
+		{"--data-binary sends the file as is", "curl https://example.com --data-binary @" + file, "", file},
+		{"--data with a missing file", "curl https://example.com -d @" + missing, "", missing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := ParseCurl(tt.command)
+			if err != nil {
+				t.Fatalf("ParseCurl() error = %v", err)
+			}
+			if input.Body != tt.wantBody {
+				t.Errorf("body = %q, want %q", input.Body, tt.wantBody)
+			}
+			if input.BodyFile != tt.wantFile {
+				t.Errorf("body file = %q, want %q", input.BodyFile, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestParseCurlErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{"not curl", `wget https://example.com`},
+		{"missing URL", `curl -X POST`},
+		{"missing option value", `curl https://example.com -H`},
+		{"unsupported option", `curl --no-such-option https://example.com`},
+		{"unterminated quote", `curl 'https://example.com`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCurl(tt.command); err == nil {
+				t.Errorf("ParseCurl(%q) succeeded", tt.command)
+			}
+		})
+	}
+}
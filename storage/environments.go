@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xujiajun/nutsdb"
+)
+
+// Environment is a named set of variables substituted into requests
+type Environment struct {
+	Name      string
+	Variables map[string]string
+}
+
+// EnvironmentStorage persists the environments
+type EnvironmentStorage struct {
+	db *nutsdb.DB
+}
+
+const bucketNameEnvironments = "environments"
+
+// variableRegex matches {{name}} references, spaces around the name are allowed
+var variableRegex = regexp.MustCompile(`\{\{\s*([\w.\-]+)\s*\}\}`)
+
+func SetupEnvironments(db *nutsdb.DB) EnvironmentStorage {
+	return EnvironmentStorage{
+		db,
+	}
+}
+
+// GetAll returns every environment sorted by name
+func (e *EnvironmentStorage) GetAll() []Environment {
+	var environments []Environment
+	if err := e.db.View(
+		func(tx *nutsdb.Tx) error {
+			entries, err := tx.GetAll(bucketNameEnvironments)
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range entries {
+				var env Environment
+				err = json.Unmarshal(entry.Value, &env)
+				if err != nil {
+					return err
+				}
+				environments = append(environments, env)
+			}
+
+			return nil
+		}); err != nil {
+		if err == nutsdb.ErrBucketEmpty {
+			return environments
+		} else {
+			log.Fatal(err)
+		}
+	}
+	sort.Slice(environments, func(i, j int) bool {
+		return environments[i].Name < environments[j].Name
+	})
+	return environments
+}
+
+// Get returns the named environment, ok is false when it does not exist
+func (e *EnvironmentStorage) Get(name string) (Environment, bool) {
+	for _, env := range e.GetAll() {
+		if env.Name == name {
+			return env, true
+		}
+	}
+	return Environment{}, false
+}
+
+// Put stores the environment, replacing the one with the same name
+func (e *EnvironmentStorage) Put(env Environment) {
+	val, err := json.Marshal(env)
+	if err != nil {
+		log.Fatal("Error marshaling environment data: ", err)
+	}
+	if err := e.db.Update(
+		func(tx *nutsdb.Tx) error {
+			if err := tx.Put(bucketNameEnvironments, []byte(env.Name), val, 0); err != nil {
+				return err
+			}
+			return nil
+		}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (e *EnvironmentStorage) Remove(name string) {
+	if err := e.db.Update(
+		func(tx *nutsdb.Tx) error {
+			if err := tx.Delete(bucketNameEnvironments, []byte(name)); err != nil {
+				return err
+			}
+			return nil
+		}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Variables returns the variables of the named environment, nil when there is none
+func (e *EnvironmentStorage) Variables(name string) map[string]string {
+	if name == "" {
+		return nil
+	}
+	env, _ := e.Get(name)
+	return env.Variables
+}
+
+// Substitute replaces the {{name}} references in s with their values, the
+// names of the variables that could not be resolved are returned as well
+func Substitute(s string, vars map[string]string) (string, []string) {
+	var unresolved []string
+	out := variableRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		name := variableRegex.FindStringSubmatch(ref)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		unresolved = append(unresolved, name)
+		return ref
+	})
+	return out, unresolved
+}
+
+// UnresolvedRefs returns the byte offsets of the {{name}} references in s that have no value in vars
+func UnresolvedRefs(s string, vars map[string]string) [][]int {
+	var refs [][]int
+	for _, loc := range variableRegex.FindAllStringSubmatchIndex(s, -1) {
+		if _, ok := vars[s[loc[2]:loc[3]]]; !ok {
+			refs = append(refs, loc[:2])
+		}
+	}
+	return refs
+}
+
+// Resolve returns the request with the variables substituted into its URL,
+// headers, body, form fields, assertions, extractions and credentials (enabled query params are part of the URL), along with the unresolved variable names
+func (ri RequestInput) Resolve(vars map[string]string) (RequestInput, []string) {
+	var unresolved []string
+	sub := func(s string) string {
+		out, missing := Substitute(s, vars)
+		unresolved = append(unresolved, missing...)
+		return out
+	}
+
+	resolved := ri
+	resolved.Path = sub(ri.Path)
+	resolved.Body = sub(ri.Body)
+	resolved.BodyFile = sub(ri.BodyFile)
+
+	resolved.Headers = make(map[string][]string, len(ri.Headers))
+	for name, values := range ri.Headers {
+		name = sub(name)
+		for _, v := range values {
+			resolved.Headers[name] = append(resolved.Headers[name], sub(v))
+		}
+	}
+
+	resolved.BodyParts = nil
+	for _, p := range ri.BodyParts {
+		p.Key = sub(p.Key)
+		p.Value = sub(p.Value)
+		resolved.BodyParts = append(resolved.BodyParts, p)
+	}
+
+	resolved.Assertions = nil
+	for _, a := range ri.Assertions {
+		a.Target = sub(a.Target)
+		a.Expected = sub(a.Expected)
+		resolved.Assertions = append(resolved.Assertions, a)
+	}
+
+	resolved.Extractions = nil
+	for _, e := range ri.Extractions {
+		e.Expression = sub(e.Expression)
+		resolved.Extractions = append(resolved.Extractions, e)
+	}
+
+	resolved.Auth.Username = sub(ri.Auth.Username)
+	resolved.Auth.Password = sub(ri.Auth.Password)
+	resolved.Auth.Token = sub(ri.Auth.Token)
+	resolved.Auth.KeyName = sub(ri.Auth.KeyName)
+	resolved.Auth.KeyValue = sub(ri.Auth.KeyValue)
+	resolved.Auth.OAuth2.AuthURL = sub(ri.Auth.OAuth2.AuthURL)
+	resolved.Auth.OAuth2.TokenURL = sub(ri.Auth.OAuth2.TokenURL)
+	resolved.Auth.OAuth2.ClientID = sub(ri.Auth.OAuth2.ClientID)
+	resolved.Auth.OAuth2.ClientSecret = sub(ri.Auth.OAuth2.ClientSecret)
+	resolved.Auth.OAuth2.Scope = sub(ri.Auth.OAuth2.Scope)
+	resolved.Auth.OAuth2.RedirectURL = sub(ri.Auth.OAuth2.RedirectURL)
+
+	return resolved, uniqueStrings(unresolved)
+}
+
+// uniqueStrings drops repeated values, keeping the first occurrence
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Extraction sources, each one reads a different part of the response
+const (
+	ExtractJSONPath = "jsonpath"
+	ExtractHeader   = "header"
+	ExtractRegex    = "regex"
+	ExtractCookie   = "cookie"
+)
+
+// ExtractionSources lists the extraction sources in the order they are offered
+var ExtractionSources = []string{ExtractJSONPath, ExtractHeader, ExtractRegex, ExtractCookie}
+
+// variableNameRegex matches the names {{name}} references can use
+var variableNameRegex = regexp.MustCompile(`^[\w.\-]+$`)
+
+// IsVariableName reports whether {{name}} references can use the name
+func IsVariableName(name string) bool {
+	return variableNameRegex.MatchString(name)
+}
+
+// Extraction stores a value of the response in a variable, later requests
+// reference it as {{name}}
+type Extraction struct {
+	Variable string
+	Source   string
+	// Expression is the JSONPath, the header or cookie name, or a regular
+	// expression matched against the body whose first group is extracted
+	Expression string
+	Disabled   bool
+}
+
+// ExtractionResult is the outcome of an extraction, Error tells why the value couldn't be read
+type ExtractionResult struct {
+	Variable string
+	Value    string
+	Error    string
+}
+
+// RunExtractions reads the values of the enabled extractions from the response
+func RunExtractions(extractions []Extraction, rr RequestResult) []ExtractionResult {
+	var results []ExtractionResult
+	for _, e := range extractions {
+		if e.Disabled {
+			continue
+		}
+		result := ExtractionResult{Variable: e.Variable}
+		value, err := e.value(rr)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Value = value
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// ExtractedVariables returns the variables of the extractions that succeeded,
+// a later extraction of the same variable wins
+func ExtractedVariables(results []ExtractionResult) map[string]string {
+	vars := make(map[string]string)
+	for _, result := range results {
+		if result.Error == "" {
+			vars[result.Variable] = result.Value
+		}
+	}
+	return vars
+}
+
+// value reads the extracted value from the response
+func (e Extraction) value(rr RequestResult) (string, error) {
+	if !IsVariableName(e.Variable) {
+		return "", fmt.Errorf("invalid variable name %q", e.Variable)
+	}
+
+	var value string
+	var found bool
+	switch e.Source {
+	case ExtractJSONPath:
+		var err error
+		value, found, err = jsonPathValue(e.Expression, rr.ResponseBody)
+		if err != nil {
+			return "", err
+		}
+	case ExtractHeader:
+		var values []string
+		for name, vals := range rr.Headers {
+			if strings.EqualFold(name, e.Expression) {
+				values = append(values, vals...)
+			}
+		}
+		value, found = strings.Join(values, ", "), len(values) > 0
+	case ExtractRegex:
+		re, err := regexp.Compile(e.Expression)
+		if err != nil {
+			return "", fmt.Errorf("invalid regular expression: %s", err)
+		}
+		if match := re.FindSubmatch(rr.ResponseBody); match != nil {
+			value, found = string(match[len(match)-1]), true
+			if len(match) > 1 {
+				value = string(match[1])
+			}
+		}
+	case ExtractCookie:
+		value, found = responseCookie(rr, e.Expression)
+	default:
+		return "", fmt.Errorf("unknown extraction source %q", e.Source)
+	}
+	if !found {
+		return "", errors.New("not found in the response")
+	}
+	return value, nil
+}
+
+// responseCookie returns the value of the named cookie set by the response or
+// by the redirects leading to it, the last one set wins
+func responseCookie(rr RequestResult, name string) (string, bool) {
+	header := make(http.Header)
+	for _, hop := range rr.Redirects {
+		addSetCookie(header, hop.Headers)
+	}
+	addSetCookie(header, rr.Headers)
+
+	var value string
+	var found bool
+	for _, c := range (&http.Response{Header: header}).Cookies() {
+		if c.Name == name {
+			value, found = c.Value, true
+		}
+	}
+	return value, found
+}
+
+func addSetCookie(header http.Header, headers map[string][]string) {
+	for name, values := range headers {
+		if strings.EqualFold(name, "Set-Cookie") {
+			for _, v := range values {
+				header.Add("Set-Cookie", v)
+			}
+		}
+	}
+}
+
+// Variables returns the variables extracted from responses
+func (s Settings) Variables() map[string]string {
+	vars := make(map[string]string)
+	switch values := s[SettingVariables].(type) {
+	case map[string]string:
+		for name, v := range values {
+			vars[name] = v
+		}
+	case map[string]interface{}:
+		for name, v := range values {
+			if str, ok := v.(string); ok {
+				vars[name] = str
+			}
+		}
+	}
+	return vars
+}
+
+// SetVariables stores the variables extracted from responses in the settings
+func (s Settings) SetVariables(vars map[string]string) {
+	s[SettingVariables] = vars
+}
+
+// MergeVariables returns the environment variables overlaid with the extracted
+// ones, a freshly extracted value takes precedence over the environment
+func MergeVariables(environment, extracted map[string]string) map[string]string {
+	vars := make(map[string]string, len(environment)+len(extracted))
+	for name, v := range environment {
+		vars[name] = v
+	}
+	for name, v := range extracted {
+		vars[name] = v
+	}
+	return vars
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestMergeVariablesPrecedence(t *testing.T) {
+	environment := map[string]string{"host": "api.example.com", "token": "from-environment"}
+	extracted := map[string]string{"token": "extracted", "id": "42"}
+
+	vars := MergeVariables(environment, extracted)
+	rq, unresolved := RequestInput{
+		Path:    "https://{{host}}/users/{{id}}",
+		Headers: map[string][]string{"Authorization": {"Bearer {{token}}"}},
+	}.Resolve(vars)
+	if len(unresolved) > 0 {
+		t.Fatalf("unresolved variables: %v", unresolved)
+	}
+	if rq.Path != "https://api.example.com/users/42" {
+		t.Errorf("path = %q", rq.Path)
+	}
+	if got := rq.Headers["Authorization"]; len(got) != 1 || got[0] != "Bearer extracted" {
+		t.Errorf("Authorization = %v, want the extracted token", got)
+	}
+	if environment["token"] != "from-environment" || len(environment) != 2 {
+		t.Errorf("MergeVariables changed the environment: %v", environment)
+	}
+}
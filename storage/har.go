@@ -0,0 +1,439 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lnenad/probster/communication"
+)
+
+// HARVersion is the version of the HAR format written by ExportHAR
+const HARVersion = "1.2"
+
+// harDateFormat is the ISO 8601 format of the HAR startedDateTime fields
+const harDateFormat = "2006-01-02T15:04:05.000Z07:00"
+
+type harArchive struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type harPostData struct {
+	MimeType string     `json:"mimeType"`
+	Params   []harParam `json:"params,omitempty"`
+	Text     string     `json:"text"`
+	Comment  string     `json:"comment,omitempty"`
+}
+
+type harParam struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// harTimings holds the phases in milliseconds, -1 marks the ones that did not happen
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// ImportHAR reads the entries of a HAR archive as history entries, keyed
+// by the time their responses were received
+func ImportHAR(data []byte) ([]HistoryEntry, error) {
+	var archive harArchive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, fmt.Errorf("not a valid HAR archive: %s", err)
+	}
+	if archive.Log.Entries == nil {
+		return nil, errors.New("not a valid HAR archive: the log has no entries")
+	}
+
+	var entries []HistoryEntry
+	for idx, entry := range archive.Log.Entries {
+		started, err := time.Parse(time.RFC3339Nano, entry.StartedDateTime)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d has an invalid startedDateTime: %s", idx+1, err)
+		}
+		response, err := entry.result()
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %s", idx+1, err)
+		}
+		entries = append(entries, HistoryEntry{
+			Key: started.Add(response.Dur).Local().Format(HistoryKeyFormat),
+			RR: RequestResponse{
+				Request:  entry.Request.input(),
+				Response: response,
+			},
+		})
+	}
+	return entries, nil
+}
+
+// input converts the HAR request into a request that can be sent again
+func (hr harRequest) input() RequestInput {
+	input := RequestInput{
+		Method:  strings.ToUpper(hr.Method),
+		Path:    hr.URL,
+		Headers: map[string][]string{},
+	}
+	for _, header := range hr.Headers {
+		// HTTP/2 pseudo headers and the length are set when sending
+		if strings.HasPrefix(header.Name, ":") || strings.EqualFold(header.Name, "Content-Length") {
+			continue
+		}
+		name := http.CanonicalHeaderKey(header.Name)
+		input.Headers[name] = append(input.Headers[name], header.Value)
+	}
+
+	if hr.PostData == nil {
+		return input
+	}
+	input.ForceBody = !communication.MethodHasBody(input.Method)
+	mimeType := communication.MediaType(hr.PostData.MimeType)
+	switch {
+	case len(hr.PostData.Params) > 0 && mimeType == "application/x-www-form-urlencoded":
+		input.BodyMode = communication.BodyForm
+		for _, param := range hr.PostData.Params {
+			input.BodyParts = append(input.BodyParts, communication.FormPart{Key: param.Name, Value: param.Value})
+		}
+	case len(hr.PostData.Params) > 0 && mimeType == "multipart/form-data":
+		input.BodyMode = communication.BodyMultipart
+		for _, param := range hr.PostData.Params {
+			if param.FileName != "" {
+				// the archive doesn't carry the file, only its name
+				input.BodyParts = append(input.BodyParts, communication.FormPart{Key: param.Name, Value: param.FileName, File: true})
+			} else {
+				input.BodyParts = append(input.BodyParts, communication.FormPart{Key: param.Name, Value: param.Value})
+			}
+		}
+		dropContentType(&input)
+	default:
+		input.BodyMode = communication.BodyRaw
+		input.Body = hr.PostData.Text
+		input.RawType = communication.RawText
+		if strings.HasSuffix(mimeType, "json") {
+			input.RawType = communication.RawJSON
+		} else if strings.HasSuffix(mimeType, "xml") {
+			input.RawType = communication.RawXML
+		}
+	}
+	return input
+}
+
+// result converts the HAR response and timings into a request result
+func (he harEntry) result() (RequestResult, error) {
+	result := RequestResult{
+		StatusCode:  he.Response.Status,
+		Headers:     map[string][]string{},
+		Dur:         harDuration(he.Time),
+		EncodedSize: he.Response.BodySize,
+	}
+	for _, header := range he.Response.Headers {
+		if strings.HasPrefix(header.Name, ":") {
+			continue
+		}
+		// lowercase like the responses recorded by ResponseHeaders
+		name := strings.ToLower(header.Name)
+		result.Headers[name] = append(result.Headers[name], header.Value)
+	}
+
+	content := he.Response.Content
+	if content.Encoding == "base64" {
+		body, err := base64.StdEncoding.DecodeString(content.Text)
+		if err != nil {
+			return result, fmt.Errorf("the response body is not valid base64: %s", err)
+		}
+		result.ResponseBody = body
+	} else {
+		result.ResponseBody = []byte(content.Text)
+	}
+	if result.EncodedSize <= 0 {
+		result.EncodedSize = int64(len(result.ResponseBody))
+	}
+
+	// browsers record requests that never got a response with status 0
+	result.Outcome = communication.OutcomeCompleted
+	if he.Response.Status == 0 {
+		result.Outcome = communication.OutcomeCancelled
+	}
+
+	t := he.Timings
+	var at time.Duration
+	phase := func(d time.Duration) communication.Phase {
+		p := communication.Phase{Start: at, Duration: d}
+		at += d
+		return p
+	}
+	at = harDuration(t.Blocked)
+	result.Timing.DNSLookup = phase(harDuration(t.DNS))
+	// connect includes the TLS handshake in HAR
+	ssl := harDuration(t.SSL)
+	result.Timing.TCPConnect = phase(harDuration(t.Connect))
+	result.Timing.TCPConnect.Duration -= ssl
+	if ssl > 0 {
+		result.Timing.TLSHandshake = communication.Phase{Start: at - ssl, Duration: ssl}
+	}
+	result.Timing.TimeToFirstByte = phase(harDuration(t.Send) + harDuration(t.Wait))
+	result.Timing.ContentTransfer = phase(harDuration(t.Receive))
+	result.Timing.Total = result.Dur
+
+	return result, nil
+}
+
+// ExportHAR writes the history entries as a HAR archive created by the given Probster version
+func ExportHAR(entries []HistoryEntry, version string) ([]byte, error) {
+	archive := harArchive{
+		Log: harLog{
+			Version: HARVersion,
+			Creator: harCreator{Name: "Probster", Version: version},
+			Entries: []harEntry{},
+		},
+	}
+	for _, entry := range entries {
+		harEntry, err := newHAREntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		archive.Log.Entries = append(archive.Log.Entries, harEntry)
+	}
+	return json.MarshalIndent(archive, "", "  ")
+}
+
+func newHAREntry(entry HistoryEntry) (harEntry, error) {
+	rq, rs := entry.RR.Request, entry.RR.Response
+
+	finished, err := time.ParseInLocation(HistoryKeyFormat, entry.Key, time.Local)
+	if err != nil {
+		return harEntry{}, fmt.Errorf("history entry %q has an invalid key: %s", entry.Key, err)
+	}
+
+	// a body file that no longer exists leaves the body out instead of failing the export
+	headers, body, bodyErr := rq.Outgoing()
+	if bodyErr != nil {
+		headers, body = rq.Headers, nil
+	}
+
+	request := harRequest{
+		Method:      rq.Method,
+		URL:         rq.Path,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harCookie{},
+		Headers:     harHeaders(headers),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	for _, cookie := range (&http.Request{Header: headers}).Cookies() {
+		request.Cookies = append(request.Cookies, harCookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	for _, param := range communication.ParseQuery(rq.Path) {
+		request.QueryString = append(request.QueryString, harNameValue{Name: param.Key, Value: param.Value})
+	}
+	if body != nil || bodyErr != nil {
+		request.PostData = &harPostData{
+			MimeType: resolveHeader(headers, "Content-Type"),
+			Text:     string(body),
+		}
+		if bodyErr != nil {
+			request.PostData.Comment = fmt.Sprintf("the body couldn't be read: %s", bodyErr)
+			switch {
+			case request.PostData.MimeType != "":
+			case rq.BodyMode == communication.BodyMultipart:
+				request.PostData.MimeType = "multipart/form-data"
+			case rq.BodyMode == communication.BodyBinary:
+				request.PostData.MimeType = "application/octet-stream"
+			}
+		}
+		if rq.BodyMode == communication.BodyForm || rq.BodyMode == communication.BodyMultipart {
+			for _, part := range rq.BodyParts {
+				if part.Disabled {
+					continue
+				}
+				param := harParam{Name: part.Key, Value: part.Value}
+				if part.File {
+					param = harParam{Name: part.Key, FileName: part.Value}
+				}
+				request.PostData.Params = append(request.PostData.Params, param)
+			}
+		}
+	}
+
+	contentType := resolveHeader(rs.Headers, "Content-Type")
+	response := harResponse{
+		Status:      rs.StatusCode,
+		StatusText:  http.StatusText(rs.StatusCode),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harCookie{},
+		Headers:     harHeaders(rs.Headers),
+		Content: harContent{
+			Size:     int64(len(rs.ResponseBody)),
+			MimeType: contentType,
+		},
+		RedirectURL: resolveHeader(rs.Headers, "Location"),
+		HeadersSize: -1,
+		BodySize:    rs.EncodedSize,
+	}
+	if response.BodySize == 0 {
+		response.BodySize = int64(len(rs.ResponseBody))
+	}
+	for _, cookie := range (&http.Response{Header: rs.Headers}).Cookies() {
+		c := harCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			HTTPOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+		}
+		if !cookie.Expires.IsZero() {
+			c.Expires = cookie.Expires.Format(harDateFormat)
+		}
+		response.Cookies = append(response.Cookies, c)
+	}
+	if communication.IsBinary(contentType, rs.ResponseBody) {
+		response.Content.Text = base64.StdEncoding.EncodeToString(rs.ResponseBody)
+		response.Content.Encoding = "base64"
+	} else {
+		response.Content.Text = string(rs.ResponseBody)
+	}
+
+	t := rs.Timing
+	optional := func(p communication.Phase) float64 {
+		if p.Duration == 0 {
+			return -1
+		}
+		return harMillis(p.Duration)
+	}
+	timings := harTimings{
+		Blocked: -1,
+		DNS:     optional(t.DNSLookup),
+		Connect: optional(communication.Phase{Duration: t.TCPConnect.Duration + t.TLSHandshake.Duration}),
+		Send:    0,
+		Wait:    harMillis(t.TimeToFirstByte.Duration),
+		Receive: harMillis(t.ContentTransfer.Duration),
+		SSL:     optional(t.TLSHandshake),
+	}
+
+	return harEntry{
+		StartedDateTime: finished.Add(-rs.Dur).Format(harDateFormat),
+		Time:            harMillis(rs.Dur),
+		Request:         request,
+		Response:        response,
+		Timings:         timings,
+	}, nil
+}
+
+// resolveHeader returns the first value of the named header, matching the name case-insensitively
+func resolveHeader(headers map[string][]string, name string) string {
+	for key, values := range headers {
+		if strings.EqualFold(key, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func harHeaders(headers map[string][]string) []harNameValue {
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := []harNameValue{}
+	for _, name := range names {
+		for _, value := range headers[name] {
+			list = append(list, harNameValue{Name: name, Value: value})
+		}
+	}
+	return list
+}
+
+// harDuration converts HAR milliseconds to a duration, -1 (not applicable) is zero
+func harDuration(ms float64) time.Duration {
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+func harMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lnenad/probster/communication"
+)
+
+func TestImportHARResponseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers string
+		want    map[string][]string
+	}{
+		{
+			name:    "canonical names",
+			headers: `[{"name": "Content-Type", "value": "application/json"}]`,
+			want:    map[string][]string{"content-type": {"application/json"}},
+		},
+		{
+			name:    "lowercase names",
+			headers: `[{"name": "content-type", "value": "text/html; charset=utf-8"}]`,
+			want:    map[string][]string{"content-type": {"text/html; charset=utf-8"}},
+		},
+		{
+			name:    "repeated names in mixed case",
+			headers: `[{"name": "Set-Cookie", "value": "a=1"}, {"name": "set-cookie", "value": "b=2"}]`,
+			want:    map[string][]string{"set-cookie": {"a=1", "b=2"}},
+		},
+		{
+			name:    "pseudo headers",
+			headers: `[{"name": ":status", "value": "200"}, {"name": "ETag", "value": "x"}]`,
+			want:    map[string][]string{"etag": {"x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"log": {"version": "1.2", "entries": [{
+				"startedDateTime": "2024-01-02T03:04:05.000Z",
+				"time": 10,
+				"request": {"method": "GET", "url": "https://example.com/", "headers": []},
+				"response": {"status": 200, "headers": ` + tt.headers + `, "content": {"text": "{}"}},
+				"timings": {"send": 1, "wait": 8, "receive": 1}
+			}]}}`
+			entries, err := ImportHAR([]byte(data))
+			if err != nil {
+				t.Fatalf("ImportHAR() error = %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("ImportHAR() returned %d entries, want 1", len(entries))
+			}
+			if got := entries[0].RR.Response.Headers; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("response headers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportHARMissingBodyFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.bin")
+	entries := []HistoryEntry{
+		{
+			Key: "20240102030405.00000",
+			RR: RequestResponse{
+				Request: RequestInput{Method: "PUT", Path: "https://example.com/upload", BodyMode: communication.BodyBinary, BodyFile: missing},
+			},
+		},
+		{
+			Key: "20240102030406.00000",
+			RR: RequestResponse{
+				Request: RequestInput{Method: "POST", Path: "https://example.com/form", BodyMode: communication.BodyMultipart, BodyParts: []communication.FormPart{
+					{Key: "name", Value: "probster"},
+					{Key: "file", Value: missing, File: true},
+				}},
+			},
+		},
+		{
+			Key: "20240102030407.00000",
+			RR: RequestResponse{
+				Request: RequestInput{Method: "POST", Path: "https://example.com/raw", BodyMode: communication.BodyRaw, RawType: communication.RawJSON, Body: `{"a":1}`},
+			},
+		},
+	}
+
+	data, err := ExportHAR(entries, "test")
+	if err != nil {
+		t.Fatalf("ExportHAR() error = %v", err)
+	}
+	var archive harArchive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		t.Fatalf("invalid HAR: %v", err)
+	}
+	if len(archive.Log.Entries) != len(entries) {
+		t.Fatalf("exported %d entries, want %d", len(archive.Log.Entries), len(entries))
+	}
+
+	tests := []struct {
+		url      string
+		mimeType string
+		text     string
+		comment  bool
+		params   int
+	}{
+		{"https://example.com/upload", "application/octet-stream", "", true, 0},
+		{"https://example.com/form", "multipart/form-data", "", true, 2},
+		{"https://example.com/raw", "application/json", `{"a":1}`, false, 0},
+	}
+	for i, tt := range tests {
+		request := archive.Log.Entries[i].Request
+		if request.URL != tt.url {
+			t.Errorf("entry %d url = %q, want %q", i, request.URL, tt.url)
+		}
+		if request.PostData == nil {
+			t.Errorf("%s: postData is missing", tt.url)
+			continue
+		}
+		if !strings.HasPrefix(request.PostData.MimeType, tt.mimeType) {
+			t.Errorf("%s: mimeType = %q, want %q", tt.url, request.PostData.MimeType, tt.mimeType)
+		}
+		if request.PostData.Text != tt.text {
+			t.Errorf("%s: text = %q, want %q", tt.url, request.PostData.Text, tt.text)
+		}
+		if (request.PostData.Comment != "") != tt.comment {
+			t.Errorf("%s: comment = %q, want a comment: %v", tt.url, request.PostData.Comment, tt.comment)
+		}
+		if len(request.PostData.Params) != tt.params {
+			t.Errorf("%s: %d params, want %d", tt.url, len(request.PostData.Params), tt.params)
+		}
+	}
+}
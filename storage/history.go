@@ -2,10 +2,13 @@ package storage
 
 import (
 	"encoding/json"
+	"net/http"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/lnenad/probster/communication"
 	"github.com/xujiajun/nutsdb"
 )
 
@@ -14,14 +17,91 @@ const HistoryKeyFormat = "20060102150405.00000"
 type RequestResponse struct {
 	Request  RequestInput
 	Response RequestResult
+	// Group names where an imported entry came from, like the title of an OpenAPI document
+	Group string
 }
 
 // RequestInput holds the request information
 type RequestInput struct {
-	Body    string
-	Method  string
-	Path    string
-	Headers map[string][]string
+	Body      string
+	Method    string
+	Path      string
+	Headers   map[string][]string
+	Timeouts  communication.Timeouts
+	Redirects communication.RedirectPolicy
+	TLS       communication.TLSOptions
+	Proxy     communication.ProxyOverride
+	// Params lists the query params of Path, including the disabled ones
+	Params []communication.QueryParam
+	// DisableCookies sends the request without the cookie jar
+	DisableCookies bool
+	// ForceBody sends the body with methods that usually don't carry one
+	ForceBody bool
+	// BodyMode picks how the body is built, Body holds the raw text,
+	// BodyParts the form fields and BodyFile the binary file
+	BodyMode  string
+	RawType   string
+	BodyParts []communication.FormPart
+	BodyFile  string
+	// Assertions are checked against the response once it is received
+	Assertions []Assertion
+	// Extractions store values of the response in variables for later requests
+	Extractions []Extraction
+	// PreRequestScript runs before the request is sent and can change it,
+	// PostResponseScript inspects the response
+	PreRequestScript   string
+	PostResponseScript string
+	// Auth holds the credentials, they are added by communication.Send
+	// instead of being stored with the headers
+	Auth communication.Auth
+}
+
+// BodySpec returns how the body of the request is built
+func (ri RequestInput) BodySpec() communication.BodySpec {
+	return communication.BodySpec{
+		Mode:    ri.BodyMode,
+		Raw:     ri.Body,
+		RawType: ri.RawType,
+		Parts:   ri.BodyParts,
+		File:    ri.BodyFile,
+	}
+}
+
+// Outgoing returns the headers and the body that get sent with the request,
+// the Content-Type of the body mode is added unless a header already sets it
+func (ri RequestInput) Outgoing() (map[string][]string, []byte, error) {
+	if !ri.ForceBody && !communication.MethodHasBody(ri.Method) {
+		return ri.Headers, nil, nil
+	}
+	body, contentType, err := ri.BodySpec().Build()
+	if err != nil || contentType == "" {
+		return ri.Headers, body, err
+	}
+	headers := map[string][]string{}
+	for name, values := range ri.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			return ri.Headers, body, nil
+		}
+		headers[name] = values
+	}
+	headers["Content-Type"] = []string{contentType}
+	return headers, body, nil
+}
+
+// SendOptions builds the transport options of the request, applying the global settings
+func (ri RequestInput) SendOptions(settings Settings, cs *CookieStorage, tokens *communication.TokenCache) communication.Options {
+	opts := communication.Options{
+		Timeouts:  ri.Timeouts,
+		Redirects: ri.Redirects,
+		TLS:       settings.TLSOptions().Merge(ri.TLS),
+		Proxy:     settings.ProxyOptions().Override(ri.Proxy),
+		Auth:      ri.Auth,
+		Tokens:    tokens,
+	}
+	if !ri.DisableCookies {
+		opts.Jar = cs
+	}
+	return opts
 }
 
 // RequestResult holds response information
@@ -30,6 +110,33 @@ type RequestResult struct {
 	Headers      map[string][]string
 	ResponseBody []byte
 	Dur          time.Duration
+	Timing       communication.Timing
+	Redirects    []communication.Hop
+	TLS          *communication.TLSInfo
+	// Outcome is one of the communication.Outcome* values, empty for
+	// entries recorded before outcomes were tracked
+	Outcome string
+	// EncodedSize is the size of ResponseBody before its Content-Encoding was decoded
+	EncodedSize int64
+	// Tests holds the outcome of the request assertions
+	Tests []AssertionResult
+	// Extracted holds the outcome of the request extractions
+	Extracted []ExtractionResult
+}
+
+// ResponseHeaders returns the response headers the way the history stores them, with lowercase names
+func ResponseHeaders(headers http.Header) map[string][]string {
+	responseHeaders := make(map[string][]string)
+	for n, vals := range headers {
+		name := strings.ToLower(n)
+		responseHeaders[name] = append(responseHeaders[name], vals...)
+	}
+	return responseHeaders
+}
+
+// Completed reports whether the request received a response
+func (rr RequestResult) Completed() bool {
+	return rr.Outcome == "" || rr.Outcome == communication.OutcomeCompleted
 }
 
 type HistoryList []HistoryEntry
@@ -109,6 +216,47 @@ func (h *HistoryStorage) RequestCompleted(key []byte, reqRes RequestResponse) {
 	}
 }
 
+// Import stores entries recorded elsewhere, keys already taken are moved
+// forward so no entry is overwritten. It returns the entries with their final keys.
+func (h *HistoryStorage) Import(entries []HistoryEntry) []HistoryEntry {
+	taken := map[string]bool{}
+	for _, entry := range h.GetAllRequests() {
+		taken[entry.Key] = true
+	}
+	var imported []HistoryEntry
+	for _, entry := range entries {
+		for taken[entry.Key] {
+			t, err := time.ParseInLocation(HistoryKeyFormat, entry.Key, time.Local)
+			if err != nil {
+				t = time.Now()
+			}
+			entry.Key = t.Add(10 * time.Microsecond).Format(HistoryKeyFormat)
+		}
+		taken[entry.Key] = true
+		h.RequestCompleted([]byte(entry.Key), entry.RR)
+		imported = append(imported, entry)
+	}
+	return imported
+}
+
+// AddUnsent stores requests that were not sent yet as a group of history
+// entries, keyed so the first request is listed on top
+func (h *HistoryStorage) AddUnsent(group string, requests []RequestInput) []HistoryEntry {
+	now := time.Now()
+	var entries []HistoryEntry
+	for idx := len(requests) - 1; idx >= 0; idx-- {
+		entries = append(entries, HistoryEntry{
+			Key: now.Add(time.Duration(len(requests)-1-idx) * 10 * time.Microsecond).Format(HistoryKeyFormat),
+			RR: RequestResponse{
+				Request:  requests[idx],
+				Response: RequestResult{Outcome: communication.OutcomeNotSent},
+				Group:    group,
+			},
+		})
+	}
+	return h.Import(entries)
+}
+
 func (h *HistoryStorage) RemoveEntry(key string) {
 	if err := h.db.Update(
 		func(tx *nutsdb.Tx) error {
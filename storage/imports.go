@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/lnenad/probster/communication"
+)
+
+// ImportResult holds what was read from a collection exported by another tool
+type ImportResult struct {
+	// Format names the tool and version the file was exported from
+	Format       string
+	Collections  []*CollectionItem
+	Environments []Environment
+	// Unsupported describes the features that were skipped, one per line
+	Unsupported []string
+}
+
+// Requests counts the requests in the imported collections
+func (ir ImportResult) Requests() int {
+	var count func(items []*CollectionItem) int
+	count = func(items []*CollectionItem) int {
+		n := 0
+		for _, item := range items {
+			if !item.IsFolder() {
+				n++
+			}
+			n += count(item.Items)
+		}
+		return n
+	}
+	return count(ir.Collections)
+}
+
+func (ir *ImportResult) unsupported(where, format string, args ...interface{}) {
+	ir.Unsupported = append(ir.Unsupported, where+": "+fmt.Sprintf(format, args...))
+}
+
+// ImportCollections reads a Postman v2.1 collection or environment, or an
+// Insomnia v4 export, into collections and environments
+func ImportCollections(data []byte) (ImportResult, error) {
+	var probe struct {
+		Info struct {
+			Schema string `json:"schema"`
+		} `json:"info"`
+		Type         string      `json:"_type"`
+		ExportFormat int         `json:"__export_format"`
+		Scope        string      `json:"_postman_variable_scope"`
+		Requests     interface{} `json:"requests"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return ImportResult{}, fmt.Errorf("not a valid JSON export: %s", err)
+	}
+
+	switch {
+	case strings.Contains(probe.Info.Schema, "/collection/v2."):
+		return importPostmanCollection(data)
+	case probe.Scope != "":
+		return importPostmanEnvironment(data)
+	case probe.Type == "export" && probe.ExportFormat == 4:
+		return importInsomnia(data)
+	case probe.Type == "export":
+		return ImportResult{}, fmt.Errorf("Insomnia export format %d is not supported, please export the data in the v4 format", probe.ExportFormat)
+	case probe.Requests != nil:
+		return ImportResult{}, errors.New("Postman v1 collections are not supported, please export the collection as v2.1")
+	}
+	return ImportResult{}, errors.New("unrecognized file, expected a Postman v2.1 collection or environment, or an Insomnia v4 export")
+}
+
+// importedAuth is an auth block of an imported request
+type importedAuth struct {
+	Kind     string
+	Username string
+	Password string
+	Token    string
+	// Prefix replaces "Bearer" in the Authorization header
+	Prefix  string
+	Key     string
+	Value   string
+	InQuery bool
+	// OAuth2 holds the OAuth 2.0 configuration, an empty Grant marks grants that aren't supported
+	OAuth2 communication.OAuth2
+}
+
+// apply stores the credentials in the Auth of the request, bearer tokens with
+// a custom prefix are added as a header instead.
+// It returns a description of the problem when the auth can't be imported.
+func (ia importedAuth) apply(input *RequestInput) string {
+	switch ia.Kind {
+	case "", "none", "noauth", "inherit":
+		return ""
+	case "basic", "digest":
+		input.Auth = communication.Auth{Scheme: ia.Kind, Username: ia.Username, Password: ia.Password}
+	case "oauth2":
+		if ia.OAuth2.Grant == "" {
+			return "OAuth 2.0 auth with the implicit grant"
+		}
+		input.Auth = communication.Auth{Scheme: communication.AuthOAuth2, OAuth2: ia.OAuth2}
+		if ia.OAuth2.Grant == communication.OAuth2Password {
+			input.Auth.Username, input.Auth.Password = ia.Username, ia.Password
+		}
+	case "bearer":
+		if ia.Prefix != "" && ia.Prefix != "Bearer" {
+			setHeader(input, "Authorization", ia.Prefix+" "+ia.Token)
+			return ""
+		}
+		input.Auth = communication.Auth{Scheme: communication.AuthBearer, Token: ia.Token}
+	case "apikey":
+		if ia.Key == "" {
+			return ""
+		}
+		input.Auth = communication.Auth{Scheme: communication.AuthAPIKey, KeyName: ia.Key, KeyValue: ia.Value, KeyIn: communication.APIKeyHeader}
+		if ia.InQuery {
+			input.Auth.KeyIn = communication.APIKeyQuery
+		}
+	default:
+		return fmt.Sprintf("%s auth", ia.Kind)
+	}
+	return ""
+}
+
+// setHeader sets the header unless the request already has it
+func setHeader(input *RequestInput, name, value string) {
+	for key := range input.Headers {
+		if strings.EqualFold(key, name) {
+			return
+		}
+	}
+	if input.Headers == nil {
+		input.Headers = map[string][]string{}
+	}
+	input.Headers[http.CanonicalHeaderKey(name)] = []string{value}
+}
+
+// addHeader adds an imported header to the request, pseudo headers are skipped
+func addHeader(input *RequestInput, name, value string) {
+	if name == "" || strings.HasPrefix(name, ":") {
+		return
+	}
+	if input.Headers == nil {
+		input.Headers = map[string][]string{}
+	}
+	name = http.CanonicalHeaderKey(name)
+	input.Headers[name] = append(input.Headers[name], value)
+}
+
+// dropContentType removes the Content-Type header of a multipart request,
+// the boundary it names doesn't match the body that gets built
+func dropContentType(input *RequestInput) {
+	for name := range input.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			delete(input.Headers, name)
+		}
+	}
+}
+
+// rawTypeOf picks the raw body type from a language or a content type
+func rawTypeOf(kind string) string {
+	kind = strings.ToLower(communication.MediaType(kind))
+	switch {
+	case kind == "json" || strings.HasSuffix(kind, "json"):
+		return communication.RawJSON
+	case kind == "xml" || strings.HasSuffix(kind, "xml"):
+		return communication.RawXML
+	}
+	return communication.RawText
+}
+
+// withQueryParams appends the params to the query of the request URL, the
+// request keeps the disabled ones so they stay in the params tab
+func withQueryParams(input *RequestInput, params []communication.QueryParam) {
+	if len(params) == 0 {
+		return
+	}
+	all := append(communication.ParseQuery(input.Path), params...)
+	input.Path = communication.WithQuery(input.Path, all)
+	for _, p := range input.Params {
+		if p.Disabled {
+			all = append(all, p)
+		}
+	}
+	input.Params = all
+}
+
+// flattenVariables turns nested variable values into dotted names
+func flattenVariables(prefix string, value interface{}, vars map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flattenVariables(name, v[key], vars)
+		}
+	case string:
+		vars[prefix] = v
+	case nil:
+		vars[prefix] = ""
+	case []interface{}:
+		encoded, _ := json.Marshal(v)
+		vars[prefix] = string(encoded)
+	default:
+		vars[prefix] = fmt.Sprint(v)
+	}
+}
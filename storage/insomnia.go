@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lnenad/probster/communication"
+)
+
+type insomniaExport struct {
+	Resources []insomniaResource `json:"resources"`
+}
+
+// insomniaResource holds the fields of every resource type, Type tells them apart
+type insomniaResource struct {
+	ID          string  `json:"_id"`
+	Type        string  `json:"_type"`
+	ParentID    string  `json:"parentId"`
+	Name        string  `json:"name"`
+	MetaSortKey float64 `json:"metaSortKey"`
+
+	// requests
+	Method         string          `json:"method"`
+	URL            string          `json:"url"`
+	Body           insomniaBody    `json:"body"`
+	Headers        []insomniaParam `json:"headers"`
+	Parameters     []insomniaParam `json:"parameters"`
+	Authentication struct {
+		Type     string `json:"type"`
+		Disabled bool   `json:"disabled"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Token    string `json:"token"`
+		Prefix   string `json:"prefix"`
+		Key      string `json:"key"`
+		Value    string `json:"value"`
+		AddTo    string `json:"addTo"`
+		// OAuth 2.0
+		GrantType         string `json:"grantType"`
+		AuthorizationURL  string `json:"authorizationUrl"`
+		AccessTokenURL    string `json:"accessTokenUrl"`
+		ClientID          string `json:"clientId"`
+		ClientSecret      string `json:"clientSecret"`
+		Scope             string `json:"scope"`
+		RedirectURL       string `json:"redirectUrl"`
+		CredentialsInBody bool   `json:"credentialsInBody"`
+	} `json:"authentication"`
+
+	// environments
+	Data map[string]interface{} `json:"data"`
+}
+
+type insomniaBody struct {
+	MimeType string          `json:"mimeType"`
+	Text     string          `json:"text"`
+	Params   []insomniaParam `json:"params"`
+	FileName string          `json:"fileName"`
+}
+
+type insomniaParam struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+	Type     string `json:"type"`
+	FileName string `json:"fileName"`
+}
+
+// insomniaVariableRegex matches {{ _.name }} references, Probster names them {{name}}
+var insomniaVariableRegex = regexp.MustCompile(`\{\{\s*_\.([\w.\-]+)\s*\}\}`)
+
+// insomniaTagRegex matches template tags like {% response ... %}
+var insomniaTagRegex = regexp.MustCompile(`\{%.*?%\}`)
+
+// insomniaText converts the variable references of s and reports the template tags it uses
+func insomniaText(result *ImportResult, where, s string) string {
+	if tags := insomniaTagRegex.FindAllString(s, -1); len(tags) > 0 {
+		for _, tag := range uniqueStrings(tags) {
+			result.unsupported(where, "template tag %s", tag)
+		}
+	}
+	return insomniaVariableRegex.ReplaceAllString(s, "{{$1}}")
+}
+
+func importInsomnia(data []byte) (ImportResult, error) {
+	var export insomniaExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return ImportResult{}, fmt.Errorf("not a valid Insomnia export: %s", err)
+	}
+	result := ImportResult{Format: "Insomnia v4"}
+
+	children := map[string][]insomniaResource{}
+	byID := map[string]insomniaResource{}
+	for _, resource := range export.Resources {
+		children[resource.ParentID] = append(children[resource.ParentID], resource)
+		byID[resource.ID] = resource
+	}
+	for _, list := range children {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].MetaSortKey < list[j].MetaSortKey
+		})
+	}
+
+	var convert func(parentID, where string) []*CollectionItem
+	convert = func(parentID, where string) []*CollectionItem {
+		var items []*CollectionItem
+		for _, resource := range children[parentID] {
+			path := where + " / " + resource.Name
+			switch resource.Type {
+			case "request_group":
+				folder := NewCollectionItem(resource.Name, nil)
+				folder.Items = convert(resource.ID, path)
+				items = append(items, folder)
+			case "request":
+				input := insomniaInput(&result, path, resource)
+				items = append(items, NewCollectionItem(resource.Name, &input))
+			case "grpc_request", "websocket_request":
+				result.unsupported(path, "%s", strings.Replace(resource.Type, "_", " ", -1))
+			}
+		}
+		return items
+	}
+
+	for _, resource := range export.Resources {
+		switch resource.Type {
+		case "workspace":
+			collection := NewCollectionItem(resource.Name, nil)
+			collection.Items = convert(resource.ID, resource.Name)
+			result.Collections = append(result.Collections, collection)
+			result.Environments = append(result.Environments, insomniaEnvironments(resource, children)...)
+		case "unit_test_suite":
+			result.unsupported(resource.Name, "unit tests")
+		}
+	}
+	// requests exported without their workspace
+	orphans := NewCollectionItem("Insomnia", nil)
+	for _, resource := range export.Resources {
+		if _, ok := byID[resource.ParentID]; ok || (resource.Type != "request" && resource.Type != "request_group") {
+			continue
+		}
+		if resource.Type == "request_group" {
+			folder := NewCollectionItem(resource.Name, nil)
+			folder.Items = convert(resource.ID, orphans.Name+" / "+resource.Name)
+			orphans.Items = append(orphans.Items, folder)
+		} else {
+			input := insomniaInput(&result, orphans.Name+" / "+resource.Name, resource)
+			orphans.Items = append(orphans.Items, NewCollectionItem(resource.Name, &input))
+		}
+	}
+	if len(orphans.Items) > 0 {
+		result.Collections = append(result.Collections, orphans)
+	}
+	return result, nil
+}
+
+// insomniaEnvironments merges the base environment of the workspace into each
+// sub environment, the base alone is imported when it has no sub environments
+func insomniaEnvironments(workspace insomniaResource, children map[string][]insomniaResource) []Environment {
+	var environments []Environment
+	for _, base := range children[workspace.ID] {
+		if base.Type != "environment" {
+			continue
+		}
+		baseVars := map[string]string{}
+		flattenVariables("", base.Data, baseVars)
+
+		subs := 0
+		for _, env := range children[base.ID] {
+			if env.Type != "environment" {
+				continue
+			}
+			vars := map[string]string{}
+			for name, value := range baseVars {
+				vars[name] = value
+			}
+			flattenVariables("", env.Data, vars)
+			environments = append(environments, Environment{Name: workspace.Name + " - " + env.Name, Variables: vars})
+			subs++
+		}
+		if subs == 0 && len(baseVars) > 0 {
+			environments = append(environments, Environment{Name: workspace.Name, Variables: baseVars})
+		}
+	}
+	return environments
+}
+
+func insomniaInput(result *ImportResult, where string, resource insomniaResource) RequestInput {
+	input := RequestInput{
+		Method:  strings.ToUpper(resource.Method),
+		Path:    insomniaText(result, where, resource.URL),
+		Headers: map[string][]string{},
+	}
+	if input.Method == "" {
+		input.Method = "GET"
+	}
+
+	var params []communication.QueryParam
+	for _, p := range resource.Parameters {
+		params = append(params, communication.QueryParam{
+			Key:      insomniaText(result, where, p.Name),
+			Value:    insomniaText(result, where, p.Value),
+			Disabled: p.Disabled,
+		})
+	}
+	withQueryParams(&input, params)
+
+	for _, h := range resource.Headers {
+		if !h.Disabled {
+			addHeader(&input, insomniaText(result, where, h.Name), insomniaText(result, where, h.Value))
+		}
+	}
+
+	body := resource.Body
+	mimeType := communication.MediaType(body.MimeType)
+	switch {
+	case mimeType == "application/x-www-form-urlencoded":
+		input.BodyMode = communication.BodyForm
+		for _, p := range body.Params {
+			input.BodyParts = append(input.BodyParts, communication.FormPart{
+				Key:      insomniaText(result, where, p.Name),
+				Value:    insomniaText(result, where, p.Value),
+				Disabled: p.Disabled,
+			})
+		}
+	case mimeType == "multipart/form-data":
+		input.BodyMode = communication.BodyMultipart
+		dropContentType(&input)
+		for _, p := range body.Params {
+			part := communication.FormPart{
+				Key:      insomniaText(result, where, p.Name),
+				Value:    insomniaText(result, where, p.Value),
+				Disabled: p.Disabled,
+			}
+			if p.Type == "file" {
+				part.File = true
+				part.Value = p.FileName
+			}
+			input.BodyParts = append(input.BodyParts, part)
+		}
+	case body.FileName != "":
+		input.BodyMode = communication.BodyBinary
+		input.BodyFile = body.FileName
+	case body.Text != "":
+		input.BodyMode = communication.BodyRaw
+		input.Body = insomniaText(result, where, body.Text)
+		input.RawType = rawTypeOf(mimeType)
+	}
+	if input.BodyMode != "" {
+		input.ForceBody = !communication.MethodHasBody(input.Method)
+	}
+
+	auth := resource.Authentication
+	if !auth.Disabled {
+		imported := importedAuth{
+			Kind:     auth.Type,
+			Username: insomniaText(result, where, auth.Username),
+			Password: insomniaText(result, where, auth.Password),
+			Token:    insomniaText(result, where, auth.Token),
+			Prefix:   auth.Prefix,
+			Key:      insomniaText(result, where, auth.Key),
+			Value:    insomniaText(result, where, auth.Value),
+			InQuery:  auth.AddTo == "queryParams",
+		}
+		if auth.Type == "oauth2" {
+			imported.OAuth2 = communication.OAuth2{
+				AuthURL:           insomniaText(result, where, auth.AuthorizationURL),
+				TokenURL:          insomniaText(result, where, auth.AccessTokenURL),
+				ClientID:          insomniaText(result, where, auth.ClientID),
+				ClientSecret:      insomniaText(result, where, auth.ClientSecret),
+				Scope:             insomniaText(result, where, auth.Scope),
+				RedirectURL:       insomniaText(result, where, auth.RedirectURL),
+				CredentialsInBody: auth.CredentialsInBody,
+			}
+			switch auth.GrantType {
+			case communication.OAuth2ClientCredentials, communication.OAuth2Password, communication.OAuth2AuthorizationCode:
+				imported.OAuth2.Grant = auth.GrantType
+			}
+		}
+		problem := imported.apply(&input)
+		if problem != "" {
+			result.unsupported(where, "%s", problem)
+		}
+	}
+	return input
+}
@@ -0,0 +1,548 @@
+package storage
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lnenad/probster/communication"
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPISpec holds the requests generated from an OpenAPI 3 or Swagger 2 document
+type OpenAPISpec struct {
+	Title string
+	// Requests lists one request per operation, in the order of the document
+	Requests []RequestInput
+}
+
+// openAPIMethods are the operations of a path item in the order they are listed
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// pathParamRegex matches the {name} parameters of a path template
+var pathParamRegex = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// maxSchemaDepth stops the example generation of recursive schemas
+const maxSchemaDepth = 8
+
+type openAPIDoc struct {
+	root    map[string]interface{}
+	swagger bool
+}
+
+// ImportOpenAPI generates a request for each operation of an OpenAPI 3 or
+// Swagger 2 document, given as JSON or YAML
+func ImportOpenAPI(data []byte) (OpenAPISpec, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return OpenAPISpec{}, fmt.Errorf("not a valid OpenAPI document: %s", err)
+	}
+	keepTimestamps(&node)
+	var root map[string]interface{}
+	if err := node.Decode(&root); err != nil {
+		return OpenAPISpec{}, fmt.Errorf("not a valid OpenAPI document: %s", err)
+	}
+
+	doc := openAPIDoc{root: root}
+	if version, ok := root["swagger"]; ok && fmt.Sprint(version) == "2.0" {
+		doc.swagger = true
+	} else if version, ok := root["openapi"].(string); !ok || !strings.HasPrefix(version, "3.") {
+		return OpenAPISpec{}, errors.New("unsupported document, expected an OpenAPI 3 or Swagger 2 specification")
+	}
+
+	spec := OpenAPISpec{Title: "OpenAPI"}
+	if info, ok := root["info"].(map[string]interface{}); ok {
+		if title, ok := info["title"].(string); ok && title != "" {
+			spec.Title = title
+		}
+	}
+
+	base := doc.baseURL()
+	// the paths are read from the node to keep the order of the document
+	for _, path := range orderedKeys(&node, "paths") {
+		item := doc.resolve(mapValue(doc.root["paths"], path))
+		for _, method := range openAPIMethods {
+			operation, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			spec.Requests = append(spec.Requests, doc.request(base, path, method, item, operation))
+		}
+	}
+	if len(spec.Requests) == 0 {
+		return spec, errors.New("the document has no operations")
+	}
+	return spec, nil
+}
+
+// orderedKeys returns the keys of the top level mapping named key in document order
+func orderedKeys(node *yaml.Node, key string) []string {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		if node.Content[idx].Value != key || node.Content[idx+1].Kind != yaml.MappingNode {
+			continue
+		}
+		var keys []string
+		value := node.Content[idx+1]
+		for k := 0; k+1 < len(value.Content); k += 2 {
+			keys = append(keys, value.Content[k].Value)
+		}
+		return keys
+	}
+	return nil
+}
+
+func mapValue(value interface{}, key string) interface{} {
+	if m, ok := value.(map[string]interface{}); ok {
+		return m[key]
+	}
+	return nil
+}
+
+// resolve follows the $ref of a local reference, other values are returned as they are
+func (doc openAPIDoc) resolve(value interface{}) map[string]interface{} {
+	for depth := 0; depth < maxSchemaDepth; depth++ {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil
+		}
+		value = doc.root
+		for _, part := range strings.Split(ref[2:], "/") {
+			part = strings.Replace(strings.Replace(part, "~1", "/", -1), "~0", "~", -1)
+			value = mapValue(value, part)
+		}
+	}
+	return nil
+}
+
+// baseURL returns the URL the paths are relative to, {{baseUrl}} when the document doesn't name a server
+func (doc openAPIDoc) baseURL() string {
+	if doc.swagger {
+		host, _ := doc.root["host"].(string)
+		basePath, _ := doc.root["basePath"].(string)
+		basePath = strings.TrimSuffix(basePath, "/")
+		if host == "" {
+			return "{{baseUrl}}" + basePath
+		}
+		scheme := "https"
+		if schemes, ok := doc.root["schemes"].([]interface{}); ok && len(schemes) > 0 {
+			scheme = fmt.Sprint(schemes[0])
+		}
+		return scheme + "://" + host + basePath
+	}
+
+	servers, _ := doc.root["servers"].([]interface{})
+	if len(servers) == 0 {
+		return "{{baseUrl}}"
+	}
+	server, _ := servers[0].(map[string]interface{})
+	url, _ := server["url"].(string)
+	// server variables are replaced with their defaults
+	if variables, ok := server["variables"].(map[string]interface{}); ok {
+		for name, variable := range variables {
+			if def := mapValue(variable, "default"); def != nil {
+				url = strings.Replace(url, "{"+name+"}", fmt.Sprint(def), -1)
+			}
+		}
+	}
+	url = strings.TrimSuffix(url, "/")
+	if !strings.Contains(url, "://") {
+		return "{{baseUrl}}" + url
+	}
+	return url
+}
+
+// parameters merges the parameters of the path item with the ones of the operation
+func (doc openAPIDoc) parameters(item, operation map[string]interface{}) []map[string]interface{} {
+	var params []map[string]interface{}
+	index := map[string]int{}
+	for _, source := range []interface{}{item["parameters"], operation["parameters"]} {
+		list, _ := source.([]interface{})
+		for _, p := range list {
+			param := doc.resolve(p)
+			if param == nil {
+				continue
+			}
+			id := fmt.Sprint(param["in"]) + ":" + fmt.Sprint(param["name"])
+			if idx, ok := index[id]; ok {
+				params[idx] = param
+				continue
+			}
+			index[id] = len(params)
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+func (doc openAPIDoc) request(base, path, method string, item, operation map[string]interface{}) RequestInput {
+	input := RequestInput{
+		Method: strings.ToUpper(method),
+		// path params become variables so they can be set in the environment
+		Path:    base + pathParamRegex.ReplaceAllString(path, "{{$1}}"),
+		Headers: map[string][]string{},
+	}
+
+	var query []communication.QueryParam
+	var cookies []string
+	var formParams []map[string]interface{}
+	for _, param := range doc.parameters(item, operation) {
+		name, _ := param["name"].(string)
+		required, _ := param["required"].(bool)
+		switch param["in"] {
+		case "query":
+			query = append(query, communication.QueryParam{
+				Key:      name,
+				Value:    doc.paramExample(param),
+				Disabled: !required,
+			})
+		case "header":
+			// optional headers are only added when the document gives an example
+			if required || param["example"] != nil || param["x-example"] != nil {
+				input.Headers[http.CanonicalHeaderKey(name)] = []string{doc.paramExample(param)}
+			}
+		case "cookie":
+			if required {
+				cookies = append(cookies, name+"="+doc.paramExample(param))
+			}
+		case "body":
+			doc.jsonBody(&input, doc.consumes(operation), param["schema"], param["example"])
+		case "formData":
+			formParams = append(formParams, param)
+		}
+	}
+	if len(query) > 0 {
+		input.Path = communication.WithQuery(input.Path, query)
+		input.Params = query
+	}
+	if len(cookies) > 0 {
+		input.Headers["Cookie"] = []string{strings.Join(cookies, "; ")}
+	}
+	if len(formParams) > 0 {
+		doc.swaggerForm(&input, operation, formParams)
+	}
+	if body := doc.resolve(operation["requestBody"]); body != nil {
+		doc.requestBody(&input, body)
+	}
+	if input.BodyMode != "" {
+		input.ForceBody = !communication.MethodHasBody(input.Method)
+	}
+	return input
+}
+
+// consumes returns the first media type a Swagger 2 operation accepts
+func (doc openAPIDoc) consumes(operation map[string]interface{}) string {
+	for _, source := range []interface{}{operation["consumes"], doc.root["consumes"]} {
+		if list, ok := source.([]interface{}); ok && len(list) > 0 {
+			return fmt.Sprint(list[0])
+		}
+	}
+	return "application/json"
+}
+
+func (doc openAPIDoc) swaggerForm(input *RequestInput, operation map[string]interface{}, params []map[string]interface{}) {
+	input.BodyMode = communication.BodyForm
+	if strings.Contains(doc.consumes(operation), "multipart") {
+		input.BodyMode = communication.BodyMultipart
+	}
+	for _, param := range params {
+		name, _ := param["name"].(string)
+		part := communication.FormPart{Key: name}
+		if param["type"] == "file" {
+			input.BodyMode = communication.BodyMultipart
+			part.File = true
+		} else {
+			part.Value = doc.paramExample(param)
+		}
+		input.BodyParts = append(input.BodyParts, part)
+	}
+}
+
+// requestBody fills the body from the preferred media type of an OpenAPI 3 request body
+func (doc openAPIDoc) requestBody(input *RequestInput, body map[string]interface{}) {
+	content, _ := body["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return
+	}
+	var mediaTypes []string
+	for mediaType := range content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	preferred := mediaTypes[0]
+	rank := func(mediaType string) int {
+		switch {
+		case mediaType == "application/json":
+			return 0
+		case strings.HasSuffix(mediaType, "+json"):
+			return 1
+		case mediaType == "application/x-www-form-urlencoded":
+			return 2
+		case mediaType == "multipart/form-data":
+			return 3
+		case strings.HasSuffix(mediaType, "xml"):
+			return 4
+		case strings.HasPrefix(mediaType, "text/"):
+			return 5
+		}
+		return 6
+	}
+	for _, mediaType := range mediaTypes {
+		if rank(mediaType) < rank(preferred) {
+			preferred = mediaType
+		}
+	}
+
+	media := doc.resolve(content[preferred])
+	example := media["example"]
+	if examples, ok := media["examples"].(map[string]interface{}); ok && example == nil {
+		var names []string
+		for name := range examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) > 0 {
+			example = doc.resolve(examples[names[0]])["value"]
+		}
+	}
+
+	switch {
+	case preferred == "application/x-www-form-urlencoded" || preferred == "multipart/form-data":
+		input.BodyMode = communication.BodyForm
+		if preferred == "multipart/form-data" {
+			input.BodyMode = communication.BodyMultipart
+		}
+		schema := doc.resolve(media["schema"])
+		values, _ := example.(map[string]interface{})
+		if values == nil {
+			values, _ = doc.example(media["schema"], nil).(map[string]interface{})
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range sortedKeys(values) {
+			part := communication.FormPart{Key: name, Value: exampleString(values[name])}
+			if property := doc.resolve(properties[name]); property["format"] == "binary" {
+				part.File = true
+				part.Value = ""
+			}
+			input.BodyParts = append(input.BodyParts, part)
+		}
+	default:
+		doc.jsonBody(input, preferred, media["schema"], example)
+	}
+}
+
+// jsonBody sets a raw body generated from the schema, or from the example when there is one
+func (doc openAPIDoc) jsonBody(input *RequestInput, mediaType string, schema, example interface{}) {
+	if example == nil {
+		example = doc.example(schema, nil)
+	}
+	input.BodyMode = communication.BodyRaw
+	input.RawType = rawTypeOf(mediaType)
+	switch {
+	case input.RawType == communication.RawJSON:
+		encoded, _ := json.MarshalIndent(example, "", "  ")
+		input.Body = string(encoded)
+	case input.RawType == communication.RawXML:
+		if s, ok := example.(string); ok {
+			input.Body = s
+		} else {
+			input.Body = xmlExample("root", example)
+		}
+	default:
+		input.Body = exampleString(example)
+	}
+	if mediaType != "" {
+		setHeader(input, "Content-Type", mediaType)
+	}
+}
+
+// paramExample returns the example value of a parameter as it is written in a URL or a header
+func (doc openAPIDoc) paramExample(param map[string]interface{}) string {
+	for _, key := range []string{"example", "x-example", "default"} {
+		if value, ok := param[key]; ok {
+			return exampleString(value)
+		}
+	}
+	if examples, ok := param["examples"].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(examples) {
+			return exampleString(doc.resolve(examples[name])["value"])
+		}
+	}
+	if param["schema"] != nil {
+		return exampleString(doc.example(param["schema"], nil))
+	}
+	// Swagger 2 parameters carry the schema fields themselves
+	return exampleString(doc.example(param, nil))
+}
+
+// example generates an example value from a schema, refs lists the
+// references being expanded so recursive schemas end
+func (doc openAPIDoc) example(value interface{}, refs []string) interface{} {
+	if ref, ok := mapValue(value, "$ref").(string); ok {
+		for _, seen := range refs {
+			if seen == ref {
+				return nil
+			}
+		}
+		refs = append(refs[:len(refs):len(refs)], ref)
+	}
+	schema := doc.resolve(value)
+	if schema == nil || len(refs) > maxSchemaDepth {
+		return nil
+	}
+	for _, key := range []string{"example", "x-example", "default", "const"} {
+		if value, ok := schema[key]; ok {
+			return value
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{}
+		for _, sub := range allOf {
+			if values, ok := doc.example(sub, refs).(map[string]interface{}); ok {
+				for name, value := range values {
+					merged[name] = value
+				}
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if choices, ok := schema[key].([]interface{}); ok && len(choices) > 0 {
+			return doc.example(choices[0], refs)
+		}
+	}
+
+	kind, _ := schema["type"].(string)
+	if types, ok := schema["type"].([]interface{}); ok && len(types) > 0 {
+		kind = fmt.Sprint(types[0])
+	}
+	if kind == "" && schema["properties"] != nil {
+		kind = "object"
+	}
+	format, _ := schema["format"].(string)
+	switch kind {
+	case "object":
+		values := map[string]interface{}{}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, property := range properties {
+			// recursive properties are left out
+			if value := doc.example(property, refs); value != nil {
+				values[name] = value
+			}
+		}
+		return values
+	case "array":
+		item := doc.example(schema["items"], refs)
+		if item == nil {
+			return []interface{}{}
+		}
+		return []interface{}{item}
+	case "integer", "number":
+		if minimum, ok := schema["minimum"]; ok {
+			return minimum
+		}
+		return 0
+	case "boolean":
+		return true
+	case "string", "file":
+		switch format {
+		case "date-time":
+			return "2024-01-01T00:00:00Z"
+		case "date":
+			return "2024-01-01"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+		case "uri", "url":
+			return "https://example.com"
+		case "binary", "byte":
+			return ""
+		}
+		return "string"
+	}
+	return nil
+}
+
+// keepTimestamps retags the unquoted dates of the document as strings, yaml
+// would decode them to time.Time and lose the text of the examples
+func keepTimestamps(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!timestamp" {
+		node.Tag = "!!str"
+	}
+	for _, child := range node.Content {
+		keepTimestamps(child)
+	}
+}
+
+// exampleString formats an example value for a URL, a header or a form field
+func exampleString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			items = append(items, exampleString(item))
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+	return fmt.Sprint(value)
+}
+
+// xmlExample writes an example value as XML elements named after the properties
+func xmlExample(name string, value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var b strings.Builder
+		b.WriteString("<" + name + ">")
+		for _, key := range sortedKeys(v) {
+			b.WriteString(xmlExample(key, v[key]))
+		}
+		b.WriteString("</" + name + ">")
+		return b.String()
+	case []interface{}:
+		var b strings.Builder
+		for _, item := range v {
+			b.WriteString(xmlExample(name, item))
+		}
+		return b.String()
+	}
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(exampleString(value)))
+	return "<" + name + ">" + b.String() + "</" + name + ">"
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestImportOpenAPIExamples(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		wantPath string
+		wantBody string
+	}{
+		{
+			name: "unquoted date in a query param",
+			document: `
+openapi: 3.0.0
+info: {title: Dates}
+servers: [{url: "https://api.example.com"}]
+paths:
+  /events:
+    get:
+      parameters:
+        - {name: since, in: query, required: true, schema: {type: string, format: date}, example: 2024-01-01}
+`,
+			wantPath: "https://api.example.com/events?since=2024-01-01",
+		},
+		{
+			name: "unquoted date time in a query param",
+			document: `
+openapi: 3.0.0
+info: {title: Dates}
+servers: [{url: "https://api.example.com"}]
+paths:
+  /events:
+    get:
+      parameters:
+        - {name: at, in: query, required: true, example: 2024-01-01T10:00:00Z}
+`,
+			wantPath: "https://api.example.com/events?at=2024-01-01T10%3A00%3A00Z",
+		},
+		{
+			name: "unquoted date in a JSON body example",
+			document: `
+openapi: 3.0.0
+info: {title: Dates}
+servers: [{url: "https://api.example.com"}]
+paths:
+  /events:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                day: {type: string, format: date, example: 2024-01-01}
+`,
+			wantPath: "https://api.example.com/events",
+			wantBody: "{\n  \"day\": \"2024-01-01\"\n}",
+		},
+		{
+			name:     "JSON document",
+			document: `{"openapi": "3.0.0", "info": {"title": "Dates"}, "servers": [{"url": "https://api.example.com"}], "paths": {"/events": {"get": {"parameters": [{"name": "since", "in": "query", "required": true, "example": "2024-01-01"}]}}}}`,
+			wantPath: "https://api.example.com/events?since=2024-01-01",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ImportOpenAPI([]byte(tt.document))
+			if err != nil {
+				t.Fatalf("ImportOpenAPI() error = %v", err)
+			}
+			if len(spec.Requests) != 1 {
+				t.Fatalf("ImportOpenAPI() returned %d requests, want 1", len(spec.Requests))
+			}
+			rq := spec.Requests[0]
+			if rq.Path != tt.wantPath {
+				t.Errorf("path = %q, want %q", rq.Path, tt.wantPath)
+			}
+			if rq.Body != tt.wantBody {
+				t.Errorf("body = %q, want %q", rq.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lnenad/probster/communication"
+)
+
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Auth     *postmanAuth      `json:"auth"`
+	Event    []postmanEvent    `json:"event"`
+	Variable []postmanVariable `json:"variable"`
+}
+
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []postmanItem   `json:"item"`
+	Request json.RawMessage `json:"request"`
+	Auth    *postmanAuth    `json:"auth"`
+	Event   []postmanEvent  `json:"event"`
+}
+
+type postmanRequest struct {
+	Method string          `json:"method"`
+	Header []postmanKV     `json:"header"`
+	URL    json.RawMessage `json:"url"`
+	Body   *postmanBody    `json:"body"`
+	Auth   *postmanAuth    `json:"auth"`
+}
+
+type postmanURL struct {
+	Raw      string      `json:"raw"`
+	Query    []postmanKV `json:"query"`
+	Variable []postmanKV `json:"variable"`
+}
+
+type postmanKV struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+	// Type is "file" for form data files, Src holds their path
+	Type string          `json:"type"`
+	Src  json.RawMessage `json:"src"`
+}
+
+type postmanBody struct {
+	Mode       string      `json:"mode"`
+	Raw        string      `json:"raw"`
+	URLEncoded []postmanKV `json:"urlencoded"`
+	FormData   []postmanKV `json:"formdata"`
+	File       struct {
+		Src string `json:"src"`
+	} `json:"file"`
+	GraphQL struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+	Disabled bool `json:"disabled"`
+}
+
+type postmanAuth struct {
+	Type string `json:"type"`
+	// the params of each auth type, a list in v2.1 and an object in v2.0
+	Params map[string]json.RawMessage `json:"-"`
+}
+
+func (pa *postmanAuth) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if kind, ok := raw["type"]; ok {
+		if err := json.Unmarshal(kind, &pa.Type); err != nil {
+			return err
+		}
+	}
+	pa.Params = raw
+	return nil
+}
+
+// param returns the value of a param of the auth type
+func (pa *postmanAuth) param(name string) string {
+	raw, ok := pa.Params[pa.Type]
+	if !ok {
+		return ""
+	}
+	var list []struct {
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &list); err == nil {
+		for _, p := range list {
+			if p.Key == name {
+				return postmanString(p.Value)
+			}
+		}
+		return ""
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err == nil {
+		return postmanString(object[name])
+	}
+	return ""
+}
+
+func (pa *postmanAuth) imported() importedAuth {
+	ia := importedAuth{
+		Kind:     pa.Type,
+		Username: pa.param("username"),
+		Password: pa.param("password"),
+		Token:    pa.param("token"),
+		Key:      pa.param("key"),
+		Value:    pa.param("value"),
+		InQuery:  pa.param("in") == "query",
+	}
+	if pa.Type == "oauth2" {
+		ia.OAuth2 = communication.OAuth2{
+			AuthURL:           pa.param("authUrl"),
+			TokenURL:          pa.param("accessTokenUrl"),
+			ClientID:          pa.param("clientId"),
+			ClientSecret:      pa.param("clientSecret"),
+			Scope:             pa.param("scope"),
+			RedirectURL:       pa.param("redirect_uri"),
+			CredentialsInBody: pa.param("client_authentication") == "body",
+		}
+		switch pa.param("grant_type") {
+		case "client_credentials":
+			ia.OAuth2.Grant = communication.OAuth2ClientCredentials
+		case "password_credentials":
+			ia.OAuth2.Grant = communication.OAuth2Password
+		case "", "authorization_code", "authorization_code_with_pkce":
+			// PKCE is sent to every server, Postman defaults to the authorization code grant
+			ia.OAuth2.Grant = communication.OAuth2AuthorizationCode
+		}
+	}
+	return ia
+}
+
+type postmanEvent struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec interface{} `json:"exec"`
+	} `json:"script"`
+}
+
+type postmanVariable struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Disabled bool        `json:"disabled"`
+	Enabled  *bool       `json:"enabled"`
+}
+
+type postmanEnvironment struct {
+	Name   string            `json:"name"`
+	Values []postmanVariable `json:"values"`
+}
+
+// postmanDynamicRegex matches Postman's dynamic variables like {{$guid}}
+var postmanDynamicRegex = regexp.MustCompile(`\{\{\s*\$[\w.]+\s*\}\}`)
+
+func postmanString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func postmanVariables(variables []postmanVariable) map[string]string {
+	vars := map[string]string{}
+	for _, v := range variables {
+		if v.Key == "" || v.Disabled || (v.Enabled != nil && !*v.Enabled) {
+			continue
+		}
+		vars[v.Key] = postmanString(v.Value)
+	}
+	return vars
+}
+
+func importPostmanEnvironment(data []byte) (ImportResult, error) {
+	var env postmanEnvironment
+	if err := json.Unmarshal(data, &env); err != nil {
+		return ImportResult{}, fmt.Errorf("not a valid Postman environment: %s", err)
+	}
+	name := env.Name
+	if name == "" {
+		name = "Postman"
+	}
+	return ImportResult{
+		Format:       "Postman environment",
+		Environments: []Environment{{Name: name, Variables: postmanVariables(env.Values)}},
+	}, nil
+}
+
+func importPostmanCollection(data []byte) (ImportResult, error) {
+	var collection postmanCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return ImportResult{}, fmt.Errorf("not a valid Postman collection: %s", err)
+	}
+
+	result := ImportResult{Format: "Postman collection v2.1"}
+	if strings.Contains(collection.Info.Schema, "/v2.0.") {
+		result.Format = "Postman collection v2.0"
+	}
+	name := collection.Info.Name
+	if name == "" {
+		name = "Postman"
+	}
+
+	root := NewCollectionItem(name, nil)
+	postmanEvents(&result, name, collection.Event)
+	root.Items = postmanItems(&result, name, collection.Item, collection.Auth)
+	result.Collections = []*CollectionItem{root}
+
+	if vars := postmanVariables(collection.Variable); len(vars) > 0 {
+		result.Environments = append(result.Environments, Environment{Name: name, Variables: vars})
+	}
+	return result, nil
+}
+
+func postmanEvents(result *ImportResult, where string, events []postmanEvent) {
+	for _, event := range events {
+		if strings.TrimSpace(postmanScript(event.Script.Exec)) == "" {
+			continue
+		}
+		switch event.Listen {
+		case "prerequest":
+			result.unsupported(where, "pre-request script")
+		case "test":
+			result.unsupported(where, "test script")
+		default:
+			result.unsupported(where, "%s script", event.Listen)
+		}
+	}
+}
+
+func postmanScript(exec interface{}) string {
+	switch v := exec.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var lines []string
+		for _, line := range v {
+			lines = append(lines, postmanString(line))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return ""
+}
+
+// isFolder reports whether the item holds other items, folders are exported
+// without a request or with "request": null
+func (pi postmanItem) isFolder() bool {
+	request := bytes.TrimSpace(pi.Request)
+	return len(pi.Item) > 0 || len(request) == 0 || bytes.Equal(request, []byte("null"))
+}
+
+// postmanItems converts folders and requests, auth is inherited from the closest parent that sets it
+func postmanItems(result *ImportResult, parent string, items []postmanItem, auth *postmanAuth) []*CollectionItem {
+	var converted []*CollectionItem
+	for _, item := range items {
+		where := parent + " / " + item.Name
+		postmanEvents(result, where, item.Event)
+
+		if item.isFolder() {
+			folderAuth := auth
+			if item.Auth != nil && item.Auth.Type != "inherit" {
+				folderAuth = item.Auth
+			}
+			folder := NewCollectionItem(item.Name, nil)
+			folder.Items = postmanItems(result, where, item.Item, folderAuth)
+			converted = append(converted, folder)
+			continue
+		}
+
+		input, err := postmanInput(result, where, item.Request, auth)
+		if err != nil {
+			result.unsupported(where, "%s", err)
+			continue
+		}
+		converted = append(converted, NewCollectionItem(item.Name, &input))
+	}
+	return converted
+}
+
+func postmanInput(result *ImportResult, where string, data json.RawMessage, auth *postmanAuth) (RequestInput, error) {
+	var request postmanRequest
+	// a request can be given as just its URL
+	var rawURL string
+	if err := json.Unmarshal(data, &rawURL); err == nil {
+		request.URL, _ = json.Marshal(rawURL)
+	} else if err := json.Unmarshal(data, &request); err != nil {
+		return RequestInput{}, fmt.Errorf("invalid request: %s", err)
+	}
+
+	input := RequestInput{
+		Method:  strings.ToUpper(request.Method),
+		Headers: map[string][]string{},
+	}
+	if input.Method == "" {
+		input.Method = "GET"
+	}
+
+	var url postmanURL
+	if err := json.Unmarshal(request.URL, &url.Raw); err != nil {
+		if err := json.Unmarshal(request.URL, &url); err != nil {
+			return RequestInput{}, fmt.Errorf("invalid request URL: %s", err)
+		}
+	}
+	input.Path = url.Raw
+	// path variables like :id are replaced with their values
+	for _, v := range url.Variable {
+		if v.Key != "" && v.Value != "" {
+			input.Path = strings.Replace(input.Path, "/:"+v.Key, "/"+v.Value, -1)
+		}
+	}
+	var disabled []communication.QueryParam
+	for _, q := range url.Query {
+		if q.Disabled {
+			disabled = append(disabled, communication.QueryParam{Key: q.Key, Value: q.Value, Disabled: true})
+		}
+	}
+	if len(disabled) > 0 {
+		input.Params = append(communication.ParseQuery(input.Path), disabled...)
+	}
+
+	for _, h := range request.Header {
+		if !h.Disabled {
+			addHeader(&input, h.Key, h.Value)
+		}
+	}
+
+	if request.Body != nil && !request.Body.Disabled {
+		postmanApplyBody(result, where, &input, request.Body)
+	}
+
+	if request.Auth != nil && request.Auth.Type != "inherit" {
+		auth = request.Auth
+	}
+	if auth != nil {
+		if problem := auth.imported().apply(&input); problem != "" {
+			result.unsupported(where, "%s", problem)
+		}
+	}
+
+	if postmanDynamicRegex.MatchString(input.Path + input.Body + fmt.Sprint(input.Headers) + fmt.Sprint(input.BodyParts)) {
+		result.unsupported(where, "dynamic variables like {{$guid}}")
+	}
+	return input, nil
+}
+
+func postmanApplyBody(result *ImportResult, where string, input *RequestInput, body *postmanBody) {
+	switch body.Mode {
+	case "raw":
+		if body.Raw == "" {
+			return
+		}
+		input.BodyMode = communication.BodyRaw
+		input.Body = body.Raw
+		input.RawType = rawTypeOf(body.Options.Raw.Language)
+	case "urlencoded":
+		input.BodyMode = communication.BodyForm
+		for _, kv := range body.URLEncoded {
+			input.BodyParts = append(input.BodyParts, communication.FormPart{Key: kv.Key, Value: kv.Value, Disabled: kv.Disabled})
+		}
+	case "formdata":
+		input.BodyMode = communication.BodyMultipart
+		dropContentType(input)
+		for _, kv := range body.FormData {
+			part := communication.FormPart{Key: kv.Key, Value: kv.Value, Disabled: kv.Disabled}
+			if kv.Type == "file" {
+				part.File = true
+				part.Value = ""
+				var src interface{}
+				if kv.Src != nil {
+					if err := json.Unmarshal(kv.Src, &src); err != nil {
+						result.unsupported(where, "the file of the form field %q", kv.Key)
+					}
+				}
+				switch v := src.(type) {
+				case string:
+					part.Value = v
+				case []interface{}:
+					if len(v) > 1 {
+						result.unsupported(where, "multiple files in the form field %q", kv.Key)
+					}
+					if len(v) > 0 {
+						part.Value = postmanString(v[0])
+					}
+				}
+			}
+			input.BodyParts = append(input.BodyParts, part)
+		}
+	case "file":
+		input.BodyMode = communication.BodyBinary
+		input.BodyFile = body.File.Src
+	case "graphql":
+		query := map[string]interface{}{"query": body.GraphQL.Query}
+		if strings.TrimSpace(body.GraphQL.Variables) != "" {
+			query["variables"] = json.RawMessage(body.GraphQL.Variables)
+		}
+		encoded, err := json.MarshalIndent(query, "", "  ")
+		if err != nil {
+			result.unsupported(where, "GraphQL variables that are not valid JSON")
+			return
+		}
+		input.BodyMode = communication.BodyRaw
+		input.Body = string(encoded)
+		input.RawType = communication.RawJSON
+	case "":
+		return
+	default:
+		result.unsupported(where, "%s body", body.Mode)
+		return
+	}
+	input.ForceBody = !communication.MethodHasBody(input.Method)
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+// collectionTree renders the items as "name" for folders and "name=METHOD url" for requests
+func collectionTree(items []*CollectionItem) string {
+	var parts []string
+	for _, item := range items {
+		if item.IsFolder() {
+			parts = append(parts, item.Name+"["+collectionTree(item.Items)+"]")
+		} else {
+			parts = append(parts, item.Name+"="+item.Request.Method+" "+item.Request.Path)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestImportPostmanFolders(t *testing.T) {
+	tests := []struct {
+		name  string
+		items string
+		want  string
+	}{
+		{
+			name:  "folder without a request",
+			items: `[{"name": "users", "item": [{"name": "list", "request": {"method": "GET", "url": "https://api.example.com/users"}}]}]`,
+			want:  "users[list=GET https://api.example.com/users]",
+		},
+		{
+			name:  "folder with a null request",
+			items: `[{"name": "users", "request": null, "item": [{"name": "create", "request": {"method": "POST", "url": "https://api.example.com/users"}}]}]`,
+			want:  "users[create=POST https://api.example.com/users]",
+		},
+		{
+			name:  "empty folder with a null request",
+			items: `[{"name": "empty", "request": null, "item": []}]`,
+			want:  "empty[]",
+		},
+		{
+			name:  "nested folders",
+			items: `[{"name": "a", "request": null, "item": [{"name": "b", "item": [{"name": "get", "request": "https://api.example.com/b"}]}]}]`,
+			want:  "a[b[get=GET https://api.example.com/b]]",
+		},
+		{
+			name:  "request next to a folder",
+			items: `[{"name": "ping", "request": {"method": "head", "url": {"raw": "https://api.example.com/ping"}}}, {"name": "f", "request": null, "item": [{"name": "x", "request": "https://api.example.com/x"}]}]`,
+			want:  "ping=HEAD https://api.example.com/ping f[x=GET https://api.example.com/x]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"info": {"name": "API", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"}, "item": ` + tt.items + `}`
+			result, err := ImportCollections([]byte(data))
+			if err != nil {
+				t.Fatalf("ImportCollections() error = %v", err)
+			}
+			if len(result.Collections) != 1 {
+				t.Fatalf("ImportCollections() returned %d collections, want 1", len(result.Collections))
+			}
+			if got := collectionTree(result.Collections[0].Items); got != tt.want {
+				t.Errorf("items = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
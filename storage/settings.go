@@ -5,6 +5,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/lnenad/probster/communication"
 	"github.com/xujiajun/nutsdb"
 )
 
@@ -24,12 +25,95 @@ const bucketNameSettings = "settings"
 const SettingCheckUpdates = "checkUpdates"
 const SettingTheme = "theme"
 
+// SettingEnvironment holds the name of the active environment
+const SettingEnvironment = "environment"
+
+// SettingVariables holds the variables extracted from responses
+const SettingVariables = "variables"
+
+const SettingTLSInsecureSkipVerify = "tlsInsecureSkipVerify"
+const SettingTLSCAFiles = "tlsCAFiles"
+const SettingTLSClientCertFile = "tlsClientCertFile"
+const SettingTLSClientKeyFile = "tlsClientKeyFile"
+const SettingTLSMinVersion = "tlsMinVersion"
+const SettingTLSMaxVersion = "tlsMaxVersion"
+const SettingTLSServerName = "tlsServerName"
+
+const SettingProxyHTTP = "proxyHTTP"
+const SettingProxyHTTPS = "proxyHTTPS"
+const SettingProxySOCKS5 = "proxySOCKS5"
+const SettingProxyUsername = "proxyUsername"
+const SettingProxyPassword = "proxyPassword"
+const SettingProxyBypass = "proxyBypass"
+
 func SetupSettings(db *nutsdb.DB) SettingsStorage {
 	return SettingsStorage{
 		db,
 	}
 }
 
+// TLSOptions returns the global TLS configuration
+func (s Settings) TLSOptions() communication.TLSOptions {
+	var t communication.TLSOptions
+	t.InsecureSkipVerify, _ = s[SettingTLSInsecureSkipVerify].(bool)
+	t.CAFiles = s.stringList(SettingTLSCAFiles)
+	t.ClientCertFile, _ = s[SettingTLSClientCertFile].(string)
+	t.ClientKeyFile, _ = s[SettingTLSClientKeyFile].(string)
+	t.MinVersion, _ = s[SettingTLSMinVersion].(string)
+	t.MaxVersion, _ = s[SettingTLSMaxVersion].(string)
+	t.ServerName, _ = s[SettingTLSServerName].(string)
+	return t
+}
+
+// SetTLSOptions stores the global TLS configuration in the settings
+func (s Settings) SetTLSOptions(t communication.TLSOptions) {
+	s[SettingTLSInsecureSkipVerify] = t.InsecureSkipVerify
+	s[SettingTLSCAFiles] = t.CAFiles
+	s[SettingTLSClientCertFile] = t.ClientCertFile
+	s[SettingTLSClientKeyFile] = t.ClientKeyFile
+	s[SettingTLSMinVersion] = t.MinVersion
+	s[SettingTLSMaxVersion] = t.MaxVersion
+	s[SettingTLSServerName] = t.ServerName
+}
+
+// ProxyOptions returns the global proxy configuration
+func (s Settings) ProxyOptions() communication.ProxyOptions {
+	var p communication.ProxyOptions
+	p.HTTP, _ = s[SettingProxyHTTP].(string)
+	p.HTTPS, _ = s[SettingProxyHTTPS].(string)
+	p.SOCKS5, _ = s[SettingProxySOCKS5].(string)
+	p.Username, _ = s[SettingProxyUsername].(string)
+	p.Password, _ = s[SettingProxyPassword].(string)
+	p.Bypass = s.stringList(SettingProxyBypass)
+	return p
+}
+
+// SetProxyOptions stores the global proxy configuration in the settings
+func (s Settings) SetProxyOptions(p communication.ProxyOptions) {
+	s[SettingProxyHTTP] = p.HTTP
+	s[SettingProxyHTTPS] = p.HTTPS
+	s[SettingProxySOCKS5] = p.SOCKS5
+	s[SettingProxyUsername] = p.Username
+	s[SettingProxyPassword] = p.Password
+	s[SettingProxyBypass] = p.Bypass
+}
+
+// stringList reads a list setting, lists come back from the database as []interface{}
+func (s Settings) stringList(key string) []string {
+	var list []string
+	switch values := s[key].(type) {
+	case []string:
+		list = values
+	case []interface{}:
+		for _, v := range values {
+			if str, ok := v.(string); ok {
+				list = append(list, str)
+			}
+		}
+	}
+	return list
+}
+
 func (h *SettingsStorage) GetAll() Settings {
 	setList := make(Settings)
 	if err := h.db.View(
@@ -0,0 +1,663 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lnenad/probster/communication"
+)
+
+// Snippet formats a request can be copied as
+const (
+	SnippetCurl   = "curl"
+	SnippetGo     = "go"
+	SnippetPython = "python"
+	SnippetFetch  = "fetch"
+	SnippetHTTPie = "httpie"
+	SnippetWget   = "wget"
+)
+
+// SnippetFormat is a snippet format along with the name it is offered under
+type SnippetFormat struct {
+	ID   string
+	Name string
+}
+
+// SnippetFormats lists the snippet formats in the order they are offered
+var SnippetFormats = []SnippetFormat{
+	{SnippetCurl, "curl"},
+	{SnippetGo, "Go net/http"},
+	{SnippetPython, "Python requests"},
+	{SnippetFetch, "JavaScript fetch"},
+	{SnippetHTTPie, "HTTPie"},
+	{SnippetWget, "wget"},
+}
+
+// snippetRequest is a request prepared for code generation. Unlike Outgoing
+// it doesn't read the files of the body, the snippets read them instead.
+type snippetRequest struct {
+	method    string
+	url       string
+	headers   []snippetHeader
+	mode      string
+	body      string
+	parts     []communication.FormPart
+	file      string
+	insecure  bool
+	redirects communication.RedirectPolicy
+	tls       communication.TLSOptions
+	proxy     communication.ProxyOverride
+	// digest holds the credentials answering digest challenges, the other
+	// schemes are sent as headers or query params
+	digest *communication.Auth
+}
+
+type snippetHeader struct {
+	name  string
+	value string
+}
+
+// hasBody reports whether the request sends a body
+func (sr snippetRequest) hasBody() bool {
+	return sr.mode != communication.BodyNone
+}
+
+// headerValues joins repeated headers, for the snippets that keep headers in a map
+func (sr snippetRequest) headerValues() []snippetHeader {
+	var joined []snippetHeader
+	for _, h := range sr.headers {
+		if n := len(joined); n > 0 && joined[n-1].name == h.name {
+			joined[n-1].value += ", " + h.value
+			continue
+		}
+		joined = append(joined, h)
+	}
+	return joined
+}
+
+func prepareSnippet(rq RequestInput) (snippetRequest, error) {
+	sr := snippetRequest{
+		method:    strings.ToUpper(rq.Method),
+		url:       rq.Path,
+		mode:      communication.BodyNone,
+		insecure:  rq.TLS.SkipVerify(),
+		redirects: rq.Redirects,
+		tls:       rq.TLS,
+		proxy:     rq.Proxy,
+	}
+	if sr.method == "" {
+		sr.method = http.MethodGet
+	}
+
+	headers := map[string][]string{}
+	for name, values := range rq.Headers {
+		headers[name] = values
+	}
+	name, value := rq.Auth.Header()
+	if rq.Auth.Scheme == communication.AuthOAuth2 {
+		// the token is obtained by Probster, the snippet leaves a placeholder for it
+		name, value = "Authorization", "Bearer <access token>"
+	}
+	if name != "" {
+		for key := range headers {
+			if strings.EqualFold(key, name) {
+				delete(headers, key)
+			}
+		}
+		headers[name] = []string{value}
+	}
+	if rq.Auth.Scheme == communication.AuthDigest {
+		sr.digest = &rq.Auth
+	}
+	if query := rq.Auth.Query(""); query != "" {
+		separator := "?"
+		if strings.Contains(sr.url, "?") {
+			separator = "&"
+		}
+		sr.url += separator + query
+	}
+	if rq.ForceBody || communication.MethodHasBody(sr.method) {
+		contentType := ""
+		switch rq.BodyMode {
+		case "", communication.BodyRaw, communication.BodyForm:
+			body, ct, err := rq.BodySpec().Build()
+			if err != nil {
+				return sr, err
+			}
+			if len(body) > 0 || rq.BodyMode != "" {
+				sr.mode, sr.body, contentType = communication.BodyRaw, string(body), ct
+			}
+		case communication.BodyMultipart:
+			sr.mode = communication.BodyMultipart
+			for _, p := range rq.BodyParts {
+				if !p.Disabled {
+					sr.parts = append(sr.parts, p)
+				}
+			}
+			// the boundary of the header wouldn't match the body the tools build
+			input := RequestInput{Headers: headers}
+			dropContentType(&input)
+		case communication.BodyBinary:
+			if rq.BodyFile == "" {
+				return sr, errors.New("no file selected for the binary body")
+			}
+			sr.mode, sr.file, contentType = communication.BodyBinary, rq.BodyFile, "application/octet-stream"
+		}
+		if contentType != "" && resolveHeader(headers, "Content-Type") == "" {
+			headers["Content-Type"] = []string{contentType}
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range headers[name] {
+			sr.headers = append(sr.headers, snippetHeader{name, value})
+		}
+	}
+	return sr, nil
+}
+
+// Snippet generates the code that sends the request in the provided format
+func Snippet(format string, rq RequestInput) (string, error) {
+	sr, err := prepareSnippet(rq)
+	if err != nil {
+		return "", err
+	}
+	if sr.digest != nil && (format == SnippetGo || format == SnippetFetch) {
+		return "", errors.New("the snippet can't answer digest challenges, pick curl, Python, HTTPie or wget")
+	}
+	switch format {
+	case SnippetCurl:
+		return curlSnippet(sr), nil
+	case SnippetGo:
+		return goSnippet(sr), nil
+	case SnippetPython:
+		return pythonSnippet(sr), nil
+	case SnippetFetch:
+		return fetchSnippet(sr), nil
+	case SnippetHTTPie:
+		return httpieSnippet(sr), nil
+	case SnippetWget:
+		return wgetSnippet(sr)
+	}
+	return "", fmt.Errorf("unknown snippet format %q", format)
+}
+
+// shellQuote quotes the argument for POSIX shells, arguments made of safe
+// characters are kept as they are
+func shellQuote(arg string) string {
+	safe := arg != ""
+	for _, r := range arg {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@%+,", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+// shellCommand joins the arguments of a command, one option per line
+func shellCommand(lines [][]string) string {
+	out := make([]string, len(lines))
+	for idx, args := range lines {
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = shellQuote(arg)
+		}
+		out[idx] = strings.Join(quoted, " ")
+	}
+	return strings.Join(out, " \\\n  ")
+}
+
+func curlSnippet(sr snippetRequest) string {
+	first := []string{"curl"}
+	switch {
+	case sr.method == http.MethodHead && !sr.hasBody():
+		first = append(first, "--head")
+	case sr.method != http.MethodGet || sr.hasBody():
+		first = append(first, "-X", sr.method)
+	}
+	first = append(first, sr.url)
+	lines := [][]string{first}
+
+	switch sr.redirects.Mode {
+	case "", communication.RedirectFollow:
+		lines = append(lines, []string{"-L"})
+	case communication.RedirectLimit:
+		lines = append(lines, []string{"-L", "--max-redirs", strconv.Itoa(sr.redirects.Max)})
+	}
+	if sr.insecure {
+		lines = append(lines, []string{"-k"})
+	}
+	for _, ca := range sr.tls.CAFiles {
+		lines = append(lines, []string{"--cacert", ca})
+	}
+	if sr.tls.ClientCertFile != "" {
+		lines = append(lines, []string{"--cert", sr.tls.ClientCertFile})
+	}
+	if sr.tls.ClientKeyFile != "" {
+		lines = append(lines, []string{"--key", sr.tls.ClientKeyFile})
+	}
+	switch sr.proxy.Mode {
+	case communication.ProxyCustom:
+		lines = append(lines, []string{"-x", sr.proxy.URL})
+	case communication.ProxyDirect:
+		lines = append(lines, []string{"--noproxy", "*"})
+	}
+	if sr.digest != nil {
+		lines = append(lines, []string{"--digest", "-u", sr.digest.Username + ":" + sr.digest.Password})
+	}
+
+	for _, h := range sr.headers {
+		if h.value == "" {
+			// "Name:" removes the header in curl, "Name;" sends it empty
+			lines = append(lines, []string{"-H", h.name + ";"})
+		} else {
+			lines = append(lines, []string{"-H", h.name + ": " + h.value})
+		}
+	}
+
+	switch sr.mode {
+	case communication.BodyRaw:
+		lines = append(lines, []string{"--data-raw", sr.body})
+	case communication.BodyMultipart:
+		for _, p := range sr.parts {
+			if p.File {
+				lines = append(lines, []string{"-F", p.Key + "=@" + p.Value})
+			} else {
+				lines = append(lines, []string{"--form-string", p.Key + "=" + p.Value})
+			}
+		}
+	case communication.BodyBinary:
+		lines = append(lines, []string{"--data-binary", "@" + sr.file})
+	}
+	return shellCommand(lines)
+}
+
+func wgetSnippet(sr snippetRequest) (string, error) {
+	if sr.mode == communication.BodyMultipart {
+		return "", errors.New("wget can't send multipart form data")
+	}
+	lines := [][]string{{"wget", "--quiet", "--output-document=-"}}
+	if sr.method != http.MethodGet || sr.hasBody() {
+		lines = append(lines, []string{"--method=" + sr.method})
+	}
+
+	switch sr.redirects.Mode {
+	case communication.RedirectNone:
+		lines = append(lines, []string{"--max-redirect=0"})
+	case communication.RedirectLimit:
+		lines = append(lines, []string{"--max-redirect=" + strconv.Itoa(sr.redirects.Max)})
+	}
+	if sr.insecure {
+		lines = append(lines, []string{"--no-check-certificate"})
+	}
+	for _, ca := range sr.tls.CAFiles {
+		lines = append(lines, []string{"--ca-certificate=" + ca})
+	}
+	if sr.tls.ClientCertFile != "" {
+		lines = append(lines, []string{"--certificate=" + sr.tls.ClientCertFile})
+	}
+	if sr.tls.ClientKeyFile != "" {
+		lines = append(lines, []string{"--private-key=" + sr.tls.ClientKeyFile})
+	}
+	switch sr.proxy.Mode {
+	case communication.ProxyCustom:
+		lines = append(lines, []string{"-e", "use_proxy=on", "-e", "http_proxy=" + sr.proxy.URL, "-e", "https_proxy=" + sr.proxy.URL})
+	case communication.ProxyDirect:
+		lines = append(lines, []string{"--no-proxy"})
+	}
+	if sr.digest != nil {
+		lines = append(lines, []string{"--user=" + sr.digest.Username, "--password=" + sr.digest.Password})
+	}
+
+	for _, h := range sr.headers {
+		lines = append(lines, []string{"--header=" + h.name + ": " + h.value})
+	}
+	switch sr.mode {
+	case communication.BodyRaw:
+		lines = append(lines, []string{"--body-data=" + sr.body})
+	case communication.BodyBinary:
+		lines = append(lines, []string{"--body-file=" + sr.file})
+	}
+	lines = append(lines, []string{sr.url})
+	return shellCommand(lines), nil
+}
+
+// httpieKey escapes the characters HTTPie reads as request item separators
+func httpieKey(key string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ":", `\:`, "=", `\=`, "@", `\@`, ";", `\;`)
+	return replacer.Replace(key)
+}
+
+func httpieSnippet(sr snippetRequest) string {
+	first := []string{"http"}
+	switch sr.redirects.Mode {
+	case "", communication.RedirectFollow:
+		first = append(first, "--follow")
+	case communication.RedirectLimit:
+		first = append(first, "--follow", "--max-redirects="+strconv.Itoa(sr.redirects.Max))
+	}
+	if sr.insecure {
+		first = append(first, "--verify=no")
+	}
+	if sr.tls.ClientCertFile != "" {
+		first = append(first, "--cert="+sr.tls.ClientCertFile)
+	}
+	if sr.tls.ClientKeyFile != "" {
+		first = append(first, "--cert-key="+sr.tls.ClientKeyFile)
+	}
+	if sr.proxy.Mode == communication.ProxyCustom {
+		first = append(first, "--proxy=http:"+sr.proxy.URL, "--proxy=https:"+sr.proxy.URL)
+	}
+	if sr.digest != nil {
+		first = append(first, "--auth-type=digest", "--auth="+sr.digest.Username+":"+sr.digest.Password)
+	}
+	if sr.mode == communication.BodyMultipart {
+		first = append(first, "--multipart")
+	}
+	lines := [][]string{append(first, sr.method, sr.url)}
+
+	for _, h := range sr.headers {
+		if h.value == "" {
+			lines = append(lines, []string{h.name + ";"})
+		} else {
+			lines = append(lines, []string{h.name + ":" + h.value})
+		}
+	}
+
+	command := ""
+	switch sr.mode {
+	case communication.BodyRaw:
+		lines = append(lines, []string{"--raw", sr.body})
+	case communication.BodyMultipart:
+		for _, p := range sr.parts {
+			if p.File {
+				lines = append(lines, []string{httpieKey(p.Key) + "@" + p.Value})
+			} else {
+				lines = append(lines, []string{httpieKey(p.Key) + "=" + p.Value})
+			}
+		}
+	case communication.BodyBinary:
+		// the redirection is added unquoted, shellQuote would turn it into an argument
+		command = " < " + shellQuote(sr.file)
+	}
+	return shellCommand(lines) + command
+}
+
+// jsString returns a double quoted string literal, valid in both JavaScript and Python
+func jsString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// goString returns a Go string literal, multi-line text is kept readable as a raw string
+func goString(s string) string {
+	if strings.Contains(s, "\n") && !strings.ContainsAny(s, "`\r") && utf8.ValidString(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+func goSnippet(sr snippetRequest) string {
+	imports := map[string]bool{"fmt": true, "io": true, "log": true, "net/http": true}
+	var code, helpers strings.Builder
+
+	body := "nil"
+	switch sr.mode {
+	case communication.BodyRaw:
+		imports["strings"] = true
+		body = "body"
+		fmt.Fprintf(&code, "body := strings.NewReader(%s)\n", goString(sr.body))
+	case communication.BodyMultipart:
+		imports["bytes"] = true
+		imports["mime/multipart"] = true
+		body = "body"
+		code.WriteString("body := &bytes.Buffer{}\nform := multipart.NewWriter(body)\n")
+		for _, p := range sr.parts {
+			if p.File {
+				fmt.Fprintf(&code, "addFile(form, %s, %s)\n", strconv.Quote(p.Key), strconv.Quote(p.Value))
+			} else {
+				fmt.Fprintf(&code, "form.WriteField(%s, %s)\n", strconv.Quote(p.Key), strconv.Quote(p.Value))
+			}
+		}
+		code.WriteString("if err := form.Close(); err != nil {\nlog.Fatal(err)\n}\n\n")
+		for _, p := range sr.parts {
+			if p.File {
+				imports["os"] = true
+				imports["path/filepath"] = true
+				helpers.WriteString(`
+func addFile(form *multipart.Writer, field, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	part, err := form.CreateFormFile(field, filepath.Base(path))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		log.Fatal(err)
+	}
+}
+`)
+				break
+			}
+		}
+	case communication.BodyBinary:
+		imports["os"] = true
+		body = "body"
+		fmt.Fprintf(&code, "body, err := os.Open(%s)\nif err != nil {\nlog.Fatal(err)\n}\ndefer body.Close()\n\n", strconv.Quote(sr.file))
+	}
+
+	fmt.Fprintf(&code, "req, err := http.NewRequest(%s, %s, %s)\nif err != nil {\nlog.Fatal(err)\n}\n", strconv.Quote(sr.method), strconv.Quote(sr.url), body)
+	if sr.mode == communication.BodyMultipart {
+		code.WriteString("req.Header.Set(\"Content-Type\", form.FormDataContentType())\n")
+	}
+	for _, h := range sr.headers {
+		fmt.Fprintf(&code, "req.Header.Add(%s, %s)\n", strconv.Quote(h.name), strconv.Quote(h.value))
+	}
+	code.WriteString("\n")
+
+	var client []string
+	if sr.proxy.Mode == communication.ProxyCustom {
+		imports["net/url"] = true
+		fmt.Fprintf(&code, "proxyURL, err := url.Parse(%s)\nif err != nil {\nlog.Fatal(err)\n}\n", strconv.Quote(sr.proxy.URL))
+	}
+	var transport []string
+	switch sr.proxy.Mode {
+	case communication.ProxyCustom:
+		transport = append(transport, "Proxy: http.ProxyURL(proxyURL),")
+	case communication.ProxyDirect:
+		transport = append(transport, "Proxy: nil,")
+	}
+	if sr.insecure {
+		imports["crypto/tls"] = true
+		transport = append(transport, "TLSClientConfig: &tls.Config{InsecureSkipVerify: true},")
+	}
+	if len(transport) > 0 {
+		client = append(client, "Transport: &http.Transport{\n"+strings.Join(transport, "\n")+"\n},")
+	}
+	switch sr.redirects.Mode {
+	case communication.RedirectNone:
+		client = append(client, "CheckRedirect: func(req *http.Request, via []*http.Request) error {\nreturn http.ErrUseLastResponse\n},")
+	case communication.RedirectLimit:
+		client = append(client, fmt.Sprintf("CheckRedirect: func(req *http.Request, via []*http.Request) error {\nif len(via) > %d {\nreturn http.ErrUseLastResponse\n}\nreturn nil\n},", sr.redirects.Max))
+	}
+	if len(client) > 0 {
+		code.WriteString("client := &http.Client{\n" + strings.Join(client, "\n") + "\n}\n")
+	} else {
+		code.WriteString("client := http.DefaultClient\n")
+	}
+	code.WriteString(`res, err := client.Do(req)
+if err != nil {
+	log.Fatal(err)
+}
+defer res.Body.Close()
+
+data, err := io.ReadAll(res.Body)
+if err != nil {
+	log.Fatal(err)
+}
+fmt.Println(res.Status)
+fmt.Println(string(data))
+`)
+
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, strconv.Quote(path))
+	}
+	sort.Strings(paths)
+	src := fmt.Sprintf("package main\n\nimport (\n%s\n)\n\nfunc main() {\n%s}\n%s", strings.Join(paths, "\n"), code.String(), helpers.String())
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return src
+	}
+	return string(formatted)
+}
+
+func pythonSnippet(sr snippetRequest) string {
+	var code strings.Builder
+	code.WriteString("import requests\n")
+	if sr.digest != nil {
+		code.WriteString("from requests.auth import HTTPDigestAuth\n")
+	}
+	code.WriteString("\n")
+	fmt.Fprintf(&code, "url = %s\n", jsString(sr.url))
+	args := []string{jsString(sr.method), "url"}
+
+	if headers := sr.headerValues(); len(headers) > 0 {
+		code.WriteString("headers = {\n")
+		for _, h := range headers {
+			fmt.Fprintf(&code, "    %s: %s,\n", jsString(h.name), jsString(h.value))
+		}
+		code.WriteString("}\n")
+		args = append(args, "headers=headers")
+	}
+
+	switch sr.mode {
+	case communication.BodyRaw:
+		data := jsString(sr.body)
+		// str bodies are sent as latin-1
+		for _, r := range sr.body {
+			if r > 0x7f {
+				data += `.encode("utf-8")`
+				break
+			}
+		}
+		fmt.Fprintf(&code, "data = %s\n", data)
+		args = append(args, "data=data")
+	case communication.BodyMultipart:
+		// text fields go to files as well, data alone would be sent urlencoded
+		code.WriteString("files = [\n")
+		for _, p := range sr.parts {
+			if p.File {
+				fmt.Fprintf(&code, "    (%s, open(%s, \"rb\")),\n", jsString(p.Key), jsString(p.Value))
+			} else {
+				fmt.Fprintf(&code, "    (%s, (None, %s)),\n", jsString(p.Key), jsString(p.Value))
+			}
+		}
+		code.WriteString("]\n")
+		args = append(args, "files=files")
+	case communication.BodyBinary:
+		fmt.Fprintf(&code, "data = open(%s, \"rb\")\n", jsString(sr.file))
+		args = append(args, "data=data")
+	}
+
+	if sr.proxy.Mode == communication.ProxyCustom {
+		fmt.Fprintf(&code, "proxies = {\"http\": %s, \"https\": %s}\n", jsString(sr.proxy.URL), jsString(sr.proxy.URL))
+		args = append(args, "proxies=proxies")
+	}
+	if sr.insecure {
+		args = append(args, "verify=False")
+	}
+	if sr.tls.ClientCertFile != "" {
+		if sr.tls.ClientKeyFile != "" {
+			args = append(args, fmt.Sprintf("cert=(%s, %s)", jsString(sr.tls.ClientCertFile), jsString(sr.tls.ClientKeyFile)))
+		} else {
+			args = append(args, "cert="+jsString(sr.tls.ClientCertFile))
+		}
+	}
+	if sr.redirects.Mode == communication.RedirectNone {
+		args = append(args, "allow_redirects=False")
+	}
+	if sr.digest != nil {
+		args = append(args, fmt.Sprintf("auth=HTTPDigestAuth(%s, %s)", jsString(sr.digest.Username), jsString(sr.digest.Password)))
+	}
+
+	// only a session limits the number of redirects
+	if sr.redirects.Mode == communication.RedirectLimit {
+		fmt.Fprintf(&code, "\nsession = requests.Session()\nsession.max_redirects = %d\n", sr.redirects.Max)
+		fmt.Fprintf(&code, "response = session.request(%s)\n", strings.Join(args, ", "))
+	} else {
+		fmt.Fprintf(&code, "\nresponse = requests.request(%s)\n", strings.Join(args, ", "))
+	}
+	code.WriteString("print(response.status_code)\nprint(response.text)\n")
+	return code.String()
+}
+
+func fetchSnippet(sr snippetRequest) string {
+	var code strings.Builder
+	// browsers can't read files by path, the snippet reads them with node
+	readsFiles := sr.mode == communication.BodyBinary
+	for _, p := range sr.parts {
+		readsFiles = readsFiles || p.File
+	}
+	if readsFiles {
+		code.WriteString("import { openAsBlob } from \"node:fs\";\n\n")
+	}
+	if sr.mode == communication.BodyMultipart {
+		code.WriteString("const form = new FormData();\n")
+		for _, p := range sr.parts {
+			if p.File {
+				fmt.Fprintf(&code, "form.append(%s, await openAsBlob(%s), %s);\n", jsString(p.Key), jsString(p.Value), jsString(filepath.Base(p.Value)))
+			} else {
+				fmt.Fprintf(&code, "form.append(%s, %s);\n", jsString(p.Key), jsString(p.Value))
+			}
+		}
+		code.WriteString("\n")
+	}
+
+	fmt.Fprintf(&code, "const response = await fetch(%s, {\n", jsString(sr.url))
+	fmt.Fprintf(&code, "  method: %s,\n", jsString(sr.method))
+	if headers := sr.headerValues(); len(headers) > 0 {
+		code.WriteString("  headers: {\n")
+		for _, h := range headers {
+			fmt.Fprintf(&code, "    %s: %s,\n", jsString(h.name), jsString(h.value))
+		}
+		code.WriteString("  },\n")
+	}
+	switch sr.mode {
+	case communication.BodyRaw:
+		fmt.Fprintf(&code, "  body: %s,\n", jsString(sr.body))
+	case communication.BodyMultipart:
+		code.WriteString("  body: form,\n")
+	case communication.BodyBinary:
+		fmt.Fprintf(&code, "  body: await openAsBlob(%s),\n", jsString(sr.file))
+	}
+	if sr.redirects.Mode == communication.RedirectNone {
+		code.WriteString("  redirect: \"manual\",\n")
+	}
+	code.WriteString("});\nconsole.log(response.status);\nconsole.log(await response.text());\n")
+	return code.String()
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lnenad/probster/communication"
+)
+
+func TestPythonSnippetRedirects(t *testing.T) {
+	tests := []struct {
+		name      string
+		redirects communication.RedirectPolicy
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:      "follow",
+			redirects: communication.RedirectPolicy{Mode: communication.RedirectFollow},
+			want:      []string{`response = requests.request("GET", url)`},
+			notWant:   []string{"allow_redirects", "max_redirects"},
+		},
+		{
+			name:      "none",
+			redirects: communication.RedirectPolicy{Mode: communication.RedirectNone},
+			want:      []string{`response = requests.request("GET", url, allow_redirects=False)`},
+			notWant:   []string{"max_redirects"},
+		},
+		{
+			name:      "limit",
+			redirects: communication.RedirectPolicy{Mode: communication.RedirectLimit, Max: 3},
+			want:      []string{"session = requests.Session()\nsession.max_redirects = 3\n", `response = session.request("GET", url)`},
+			notWant:   []string{"allow_redirects", "requests.request("},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Snippet(SnippetPython, RequestInput{Method: "GET", Path: "https://example.com/", Redirects: tt.redirects})
+			if err != nil {
+				t.Fatalf("Snippet() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(code, want) {
+					t.Errorf("snippet doesn't contain %q:\n%s", want, code)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(code, notWant) {
+					t.Errorf("snippet contains %q:\n%s", notWant, code)
+				}
+			}
+		})
+	}
+}
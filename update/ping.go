@@ -1,8 +1,10 @@
 package update
 
 import (
+	"context"
 	"encoding/json"
 	"runtime"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -15,6 +17,13 @@ const path = "version.json"
 
 var failedAttempts = 0
 
+var checkOptions = communication.Options{
+	Timeouts: communication.Timeouts{
+		Connect: 5 * time.Second,
+		Total:   10 * time.Second,
+	},
+}
+
 type VersionResult struct {
 	Windows string
 	Ubuntu  string
@@ -22,9 +31,12 @@ type VersionResult struct {
 }
 
 func CheckVersion(current *gv.Version) (bool, string) {
-	_, response, err := communication.Send(serverURL+path, "GET", nil, "")
+	var response []byte
+	result, err := communication.Send(context.Background(), serverURL+path, "GET", nil, nil, checkOptions)
 	if err != nil {
 		failedAttempts++
+	} else {
+		response = result.Body
 	}
 
 	var vr VersionResult
@@ -1,12 +1,23 @@
 package window
 
-import "github.com/gotk3/gotk3/gtk"
+import (
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/communication"
+)
 
-func GetActionbar() (*gtk.ActionBar, *gtk.CheckButton, *gtk.Label, *gtk.Label) {
+func GetActionbar() (*gtk.ActionBar, *gtk.CheckButton, *gtk.ComboBoxText, *gtk.Label, *gtk.Label) {
 	actionBar, _ := gtk.ActionBarNew()
 
 	highlight, _ := gtk.CheckButtonNewWithLabel("Syntax Highlighting")
 
+	charset, _ := gtk.ComboBoxTextNew()
+	charset.Append("", "Charset: Auto")
+	for _, name := range communication.Charsets {
+		charset.Append(name, name)
+	}
+	charset.SetActiveID("")
+	charset.SetTooltipText("Decode the response body with this charset instead of the one sent by the server")
+
 	responseStatusLbl, _ := gtk.LabelNew("Status Code: ---")
 	responseStatusLbl.SetMarginTop(10)
 	responseStatusLbl.SetMarginBottom(10)
@@ -19,8 +30,9 @@ func GetActionbar() (*gtk.ActionBar, *gtk.CheckButton, *gtk.Label, *gtk.Label) {
 	requestDurationLbl.SetMarginEnd(20)
 	requestDurationLbl.SetHAlign(gtk.ALIGN_END)
 	actionBar.PackStart(highlight)
+	actionBar.PackStart(charset)
 	actionBar.PackEnd(responseStatusLbl)
 	actionBar.PackEnd(requestDurationLbl)
 
-	return actionBar, highlight, responseStatusLbl, requestDurationLbl
+	return actionBar, highlight, charset, responseStatusLbl, requestDurationLbl
 }
@@ -0,0 +1,330 @@
+package window
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// IDs to access the assertion columns by
+const (
+	AssertionColumnEnabled = iota
+	AssertionColumnKind
+	AssertionColumnTarget
+	AssertionColumnOperator
+	AssertionColumnExpected
+)
+
+// IDs to access the test result columns by
+const (
+	TestColumnResult = iota
+	TestColumnName
+	TestColumnActual
+)
+
+// RequestAssertions holds the widgets of the request "Assertions" tab
+type RequestAssertions struct {
+	store *gtk.ListStore
+}
+
+// Assertions returns the assertions listed in the tab, including the disabled ones
+func (ra *RequestAssertions) Assertions() []storage.Assertion {
+	var assertions []storage.Assertion
+	iter, ok := ra.store.GetIterFirst()
+	for ok {
+		enabled, _ := ra.store.GetValue(iter, AssertionColumnEnabled)
+		enabledVal, _ := enabled.GoValue()
+		var columns [4]string
+		for idx, id := range []int{AssertionColumnKind, AssertionColumnTarget, AssertionColumnOperator, AssertionColumnExpected} {
+			value, _ := ra.store.GetValue(iter, id)
+			columns[idx], _ = value.GetString()
+		}
+		assertions = append(assertions, storage.Assertion{
+			Kind:     columns[0],
+			Target:   columns[1],
+			Operator: columns[2],
+			Expected: columns[3],
+			Disabled: enabledVal != true,
+		})
+		ok = ra.store.IterNext(iter)
+	}
+	return assertions
+}
+
+func (ra *RequestAssertions) add(a storage.Assertion) {
+	err := ra.store.Set(ra.store.Append(),
+		[]int{AssertionColumnEnabled, AssertionColumnKind, AssertionColumnTarget, AssertionColumnOperator, AssertionColumnExpected},
+		[]interface{}{!a.Disabled, a.Kind, a.Target, a.Operator, a.Expected})
+	if err != nil {
+		log.Fatal("Unable to add row:", err)
+	}
+}
+
+// Load displays the assertions of a stored request
+func (ra *RequestAssertions) Load(rq storage.RequestInput) {
+	ra.store.Clear()
+	for _, a := range rq.Assertions {
+		ra.add(a)
+	}
+}
+
+// Apply stores the assertions of the tab into the request
+func (ra *RequestAssertions) Apply(rq *storage.RequestInput) {
+	rq.Assertions = ra.Assertions()
+}
+
+// Reset clears the assertions tab
+func (ra *RequestAssertions) Reset() {
+	ra.store.Clear()
+}
+
+// choicesStore returns a single column list store holding the choices of a combo cell
+func choicesStore(choices []string) *gtk.ListStore {
+	store, err := gtk.ListStoreNew(glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+	for _, choice := range choices {
+		if err := store.SetValue(store.Append(), 0, choice); err != nil {
+			log.Fatal("Unable to add row:", err)
+		}
+	}
+	return store
+}
+
+func getRequestAssertions() (*gtk.Grid, *RequestAssertions) {
+	assertionsGrid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create assertionsGrid:", err)
+	}
+	assertionsGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	treeView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	treeView.SetHExpand(true)
+	treeView.SetVExpand(true)
+	treeView.SetTooltipText("Header assertions name the header in Target, JSONPath and XPath assertions hold their expression.\nStatus codes can be matched as 2xx, time is in milliseconds.")
+
+	assertionsStore, err := gtk.ListStoreNew(glib.TYPE_BOOLEAN, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+	treeView.SetModel(assertionsStore)
+
+	ra := &RequestAssertions{store: assertionsStore}
+
+	toggleRenderer, err := gtk.CellRendererToggleNew()
+	if err != nil {
+		log.Fatal("Unable to create toggle cell renderer:", err)
+	}
+	toggleRenderer.Connect("toggled", func(crt *gtk.CellRendererToggle, row string) {
+		rowIter, err := assertionsStore.GetIterFromString(row)
+		if err != nil {
+			log.Fatal("Unable to get row iter:", err)
+		}
+		enabled, _ := assertionsStore.GetValue(rowIter, AssertionColumnEnabled)
+		enabledVal, _ := enabled.GoValue()
+		assertionsStore.SetValue(rowIter, AssertionColumnEnabled, enabledVal != true)
+	})
+	toggleColumn, err := gtk.TreeViewColumnNewWithAttribute("", toggleRenderer, "active", AssertionColumnEnabled)
+	if err != nil {
+		log.Fatal("Unable to create cell column:", err)
+	}
+	treeView.AppendColumn(toggleColumn)
+
+	columns := []struct {
+		id      int
+		title   string
+		choices []string
+	}{
+		{AssertionColumnKind, "Kind", storage.AssertionKinds},
+		{AssertionColumnTarget, "Target", nil},
+		{AssertionColumnOperator, "Operator", storage.AssertionOperators},
+		{AssertionColumnExpected, "Expected Value", nil},
+	}
+	for _, c := range columns {
+		columnID := c.id
+		edited := func(row string, value string) {
+			rowIter, err := assertionsStore.GetIterFromString(row)
+			if err != nil {
+				log.Fatal("Unable to get row iter:", err)
+			}
+			assertionsStore.SetValue(rowIter, columnID, value)
+		}
+		var renderer gtk.ICellRenderer
+		if c.choices != nil {
+			comboRenderer, err := gtk.CellRendererComboNew()
+			if err != nil {
+				log.Fatal("Unable to create combo cell renderer:", err)
+			}
+			comboRenderer.SetProperty("model", choicesStore(c.choices).Object)
+			comboRenderer.SetProperty("text-column", 0)
+			comboRenderer.SetProperty("has-entry", false)
+			comboRenderer.SetProperty("editable", true)
+			comboRenderer.Connect("edited", func(crc *gtk.CellRendererCombo, row string, value string) {
+				edited(row, value)
+			})
+			renderer = comboRenderer
+		} else {
+			textRenderer, err := gtk.CellRendererTextNew()
+			if err != nil {
+				log.Fatal("Unable to create text cell renderer:", err)
+			}
+			textRenderer.SetProperty("editable", true)
+			textRenderer.Connect("edited", func(crt *gtk.CellRendererText, row string, value string) {
+				edited(row, value)
+			})
+			renderer = textRenderer
+		}
+		column, err := gtk.TreeViewColumnNewWithAttribute(c.title, renderer, "text", c.id)
+		if err != nil {
+			log.Fatal("Unable to create cell column:", err)
+		}
+		column.SetResizable(true)
+		treeView.AppendColumn(column)
+	}
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(treeView)
+	scrolledWindow.SetVExpand(true)
+
+	buttonBox, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	if err != nil {
+		log.Fatal("Unable to create assertions button box:", err)
+	}
+	setMargins(buttonBox, 5, 5, 5, 0)
+
+	deleteAssertionBtn, _ := gtk.ButtonNewWithLabel("Delete selected assertion")
+	addAssertionBtn, _ := gtk.ButtonNewWithLabel("Add a new assertion")
+
+	addAssertionBtn.Connect("clicked", func() {
+		ra.add(storage.Assertion{Kind: storage.AssertStatus, Operator: storage.OpEquals, Expected: "200"})
+	})
+
+	deleteAssertionBtn.Connect("clicked", func() {
+		selection, err := treeView.GetSelection()
+		if err != nil {
+			log.Fatal("Unable to get tree view selection:", err)
+		}
+		selection.GetSelectedRows(&assertionsStore.TreeModel).Foreach(func(item interface{}) {
+			iter, err := assertionsStore.GetIter(item.(*gtk.TreePath))
+			if err != nil {
+				log.Fatal("Unable to get tree view iter:", err)
+			}
+			assertionsStore.Remove(iter)
+		})
+	})
+
+	buttonBox.SetVAlign(gtk.ALIGN_END)
+	buttonBox.PackEnd(deleteAssertionBtn, false, false, 3)
+	buttonBox.PackEnd(addAssertionBtn, false, false, 3)
+
+	sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
+
+	assertionsGrid.Add(scrolledWindow)
+	assertionsGrid.Add(sep)
+	assertionsGrid.Add(buttonBox)
+
+	return assertionsGrid, ra
+}
+
+// TestsView lists the assertion results of a response
+type TestsView struct {
+	store *gtk.ListStore
+	label *gtk.Label
+}
+
+// SetTests replaces the displayed results, the tab label shows how many
+// assertions passed, extractions are listed after them
+func (tv *TestsView) SetTests(results []storage.AssertionResult, extracted []storage.ExtractionResult) {
+	tv.store.Clear()
+	passed := 0
+	for _, result := range results {
+		mark := `<span foreground='red'>FAIL</span>`
+		if result.Passed {
+			mark = `<span foreground='green'>PASS</span>`
+			passed++
+		}
+		actual := result.Actual
+		if result.Error != "" {
+			actual = result.Error
+		}
+		tv.add(mark, result.Name, actual)
+	}
+	for _, result := range extracted {
+		mark, value := `<span foreground='blue'>SET</span>`, result.Value
+		if result.Error != "" {
+			mark, value = `<span foreground='orange'>MISS</span>`, result.Error
+		}
+		tv.add(mark, "{{"+result.Variable+"}}", value)
+	}
+	if len(results) == 0 {
+		tv.label.SetText("Tests")
+	} else {
+		tv.label.SetText(fmt.Sprintf("Tests (%d/%d)", passed, len(results)))
+	}
+}
+
+func (tv *TestsView) add(mark, name, value string) {
+	if runes := []rune(value); len(runes) > 200 {
+		value = string(runes[:200]) + "…"
+	}
+	err := tv.store.Set(tv.store.Append(),
+		[]int{TestColumnResult, TestColumnName, TestColumnActual},
+		[]interface{}{mark, name, value})
+	if err != nil {
+		log.Fatal("Unable to add row:", err)
+	}
+}
+
+func getTestsView(label *gtk.Label) (*gtk.ScrolledWindow, *TestsView) {
+	treeView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	treeView.SetHExpand(true)
+	treeView.SetVExpand(true)
+
+	testsStore, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+	treeView.SetModel(testsStore)
+
+	for _, c := range []struct {
+		id        int
+		title     string
+		attribute string
+	}{
+		{TestColumnResult, "Result", "markup"},
+		{TestColumnName, "Check", "text"},
+		{TestColumnActual, "Actual Value", "text"},
+	} {
+		cellRenderer, err := gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal("Unable to create text cell renderer:", err)
+		}
+		column, err := gtk.TreeViewColumnNewWithAttribute(c.title, cellRenderer, c.attribute, c.id)
+		if err != nil {
+			log.Fatal("Unable to create cell column:", err)
+		}
+		column.SetResizable(true)
+		treeView.AppendColumn(column)
+	}
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(treeView)
+
+	return scrolledWindow, &TestsView{testsStore, label}
+}
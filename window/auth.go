@@ -0,0 +1,332 @@
+package window
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/communication"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestAuth holds the widgets of the request "Auth" tab
+type RequestAuth struct {
+	scheme   *gtk.ComboBoxText
+	username *gtk.Entry
+	password *gtk.Entry
+	token    *gtk.Entry
+	keyName  *gtk.Entry
+	keyValue *gtk.Entry
+	keyIn    *gtk.ComboBoxText
+
+	grant             *gtk.ComboBoxText
+	authURL           *gtk.Entry
+	tokenURL          *gtk.Entry
+	clientID          *gtk.Entry
+	clientSecret      *gtk.Entry
+	scope             *gtk.Entry
+	redirectURL       *gtk.Entry
+	credentialsInBody *gtk.CheckButton
+	getToken          *gtk.Button
+	forgetToken       *gtk.Button
+	tokenStatus       *gtk.Label
+
+	tokens       *communication.TokenCache
+	settings     *storage.Settings
+	cs           *storage.CookieStorage
+	environments *EnvironmentSwitcher
+	errorDiag    *ErrorDialog
+}
+
+// Load displays the credentials of a stored request
+func (ra *RequestAuth) Load(rq storage.RequestInput) {
+	auth := rq.Auth
+	if auth.KeyIn == "" {
+		auth.KeyIn = communication.APIKeyHeader
+	}
+	if auth.OAuth2.Grant == "" {
+		auth.OAuth2.Grant = communication.OAuth2ClientCredentials
+	}
+	ra.scheme.SetActiveID(auth.Scheme)
+	ra.username.SetText(auth.Username)
+	ra.password.SetText(auth.Password)
+	ra.token.SetText(auth.Token)
+	ra.keyName.SetText(auth.KeyName)
+	ra.keyValue.SetText(auth.KeyValue)
+	ra.keyIn.SetActiveID(auth.KeyIn)
+	ra.grant.SetActiveID(auth.OAuth2.Grant)
+	ra.authURL.SetText(auth.OAuth2.AuthURL)
+	ra.tokenURL.SetText(auth.OAuth2.TokenURL)
+	ra.clientID.SetText(auth.OAuth2.ClientID)
+	ra.clientSecret.SetText(auth.OAuth2.ClientSecret)
+	ra.scope.SetText(auth.OAuth2.Scope)
+	ra.redirectURL.SetText(auth.OAuth2.RedirectURL)
+	ra.credentialsInBody.SetActive(auth.OAuth2.CredentialsInBody)
+	ra.updateSensitivity()
+	ra.updateTokenStatus()
+}
+
+// Apply stores the credentials of the tab into the request, only the fields
+// of the selected scheme are kept
+func (ra *RequestAuth) Apply(rq *storage.RequestInput) {
+	rq.Auth = ra.auth()
+}
+
+// Reset clears the credentials
+func (ra *RequestAuth) Reset() {
+	ra.Load(storage.RequestInput{})
+}
+
+func (ra *RequestAuth) auth() communication.Auth {
+	auth := communication.Auth{Scheme: ra.scheme.GetActiveID()}
+	switch auth.Scheme {
+	case communication.AuthBasic, communication.AuthDigest:
+		auth.Username = entryText(ra.username)
+		auth.Password = entryText(ra.password)
+	case communication.AuthBearer:
+		auth.Token = entryText(ra.token)
+	case communication.AuthAPIKey:
+		auth.KeyName = entryText(ra.keyName)
+		auth.KeyValue = entryText(ra.keyValue)
+		auth.KeyIn = ra.keyIn.GetActiveID()
+	case communication.AuthOAuth2:
+		auth.OAuth2 = communication.OAuth2{
+			Grant:             ra.grant.GetActiveID(),
+			TokenURL:          entryText(ra.tokenURL),
+			ClientID:          entryText(ra.clientID),
+			ClientSecret:      entryText(ra.clientSecret),
+			Scope:             entryText(ra.scope),
+			CredentialsInBody: ra.credentialsInBody.GetActive(),
+		}
+		switch auth.OAuth2.Grant {
+		case communication.OAuth2Password:
+			auth.Username = entryText(ra.username)
+			auth.Password = entryText(ra.password)
+		case communication.OAuth2AuthorizationCode:
+			auth.OAuth2.AuthURL = entryText(ra.authURL)
+			auth.OAuth2.RedirectURL = entryText(ra.redirectURL)
+		}
+	}
+	return auth
+}
+
+// resolvedAuth returns the credentials of the tab with the variables
+// substituted, along with the unresolved variable names
+func (ra *RequestAuth) resolvedAuth() (communication.Auth, []string) {
+	resolved, unresolved := storage.RequestInput{Auth: ra.auth()}.Resolve(ra.environments.Variables())
+	return resolved.Auth, unresolved
+}
+
+// updateSensitivity enables the fields used by the selected scheme
+func (ra *RequestAuth) updateSensitivity() {
+	scheme := ra.scheme.GetActiveID()
+	oauth2 := scheme == communication.AuthOAuth2
+	grant := ra.grant.GetActiveID()
+	userPass := scheme == communication.AuthBasic || scheme == communication.AuthDigest || (oauth2 && grant == communication.OAuth2Password)
+	ra.username.SetSensitive(userPass)
+	ra.password.SetSensitive(userPass)
+	ra.token.SetSensitive(scheme == communication.AuthBearer)
+	ra.keyName.SetSensitive(scheme == communication.AuthAPIKey)
+	ra.keyValue.SetSensitive(scheme == communication.AuthAPIKey)
+	ra.keyIn.SetSensitive(scheme == communication.AuthAPIKey)
+
+	for _, entry := range []*gtk.Entry{ra.tokenURL, ra.clientID, ra.clientSecret, ra.scope} {
+		entry.SetSensitive(oauth2)
+	}
+	ra.grant.SetSensitive(oauth2)
+	ra.authURL.SetSensitive(oauth2 && grant == communication.OAuth2AuthorizationCode)
+	ra.redirectURL.SetSensitive(oauth2 && grant == communication.OAuth2AuthorizationCode)
+	ra.credentialsInBody.SetSensitive(oauth2)
+	ra.getToken.SetSensitive(oauth2)
+	ra.forgetToken.SetSensitive(oauth2)
+}
+
+// updateTokenStatus shows the expiry of the cached token
+func (ra *RequestAuth) updateTokenStatus() {
+	if ra.scheme.GetActiveID() != communication.AuthOAuth2 {
+		ra.tokenStatus.SetText("")
+		return
+	}
+	auth, _ := ra.resolvedAuth()
+	token, ok := ra.tokens.Cached(auth)
+	switch {
+	case !ok:
+		ra.tokenStatus.SetText("No token yet, one is obtained when the request is sent")
+	case token.Valid(time.Now()) && token.Expiry.IsZero():
+		ra.tokenStatus.SetText("Token cached without an expiry")
+	case token.Valid(time.Now()):
+		ra.tokenStatus.SetText(fmt.Sprintf("Token cached until %s", token.Expiry.Format("15:04:05")))
+	case token.RefreshToken != "":
+		ra.tokenStatus.SetText("Token expired, it is refreshed when the request is sent")
+	default:
+		ra.tokenStatus.SetText("Token expired, a new one is obtained when the request is sent")
+	}
+}
+
+// requestToken obtains a new token in the background
+func (ra *RequestAuth) requestToken() {
+	auth, unresolved := ra.resolvedAuth()
+	if len(unresolved) > 0 {
+		ra.errorDiag.ShowError(fmt.Sprintf("Unresolved variables: {{%s}}\nDefine them in the active environment or extract them from a response", strings.Join(unresolved, "}}, {{")))
+		return
+	}
+	opts := storage.RequestInput{Auth: auth}.SendOptions(*ra.settings, ra.cs, ra.tokens)
+
+	ra.getToken.SetSensitive(false)
+	ra.tokenStatus.SetText("Waiting for the token...")
+	go func() {
+		_, err := ra.tokens.Renew(context.Background(), auth, opts)
+		glib.IdleAdd(func() {
+			ra.getToken.SetSensitive(ra.scheme.GetActiveID() == communication.AuthOAuth2)
+			ra.updateTokenStatus()
+			if err != nil {
+				ra.errorDiag.ShowError(fmt.Sprintf("Unable to get the OAuth 2.0 token.\n%s", err))
+			}
+		})
+	}()
+}
+
+// getTokenCache returns the cache of the OAuth 2.0 tokens, the authorization
+// page is shown in the browser
+func getTokenCache(errorDiag *ErrorDialog) *communication.TokenCache {
+	return communication.NewTokenCache(func(authURL string) error {
+		if err := communication.OpenBrowser(authURL); err != nil {
+			log.Warnf("Unable to open the browser: %s", err)
+			glib.IdleAdd(func() {
+				errorDiag.ShowError(fmt.Sprintf("Unable to open the browser, please open the authorization page:\n%s", authURL))
+			})
+		}
+		return nil
+	})
+}
+
+func attachSectionLabel(grid *gtk.Grid, markup string, row int) {
+	lbl, _ := gtk.LabelNew("")
+	lbl.SetMarkup(markup)
+	lbl.SetHAlign(gtk.ALIGN_START)
+	lbl.SetMarginTop(10)
+	grid.Attach(lbl, 0, row, 2, 1)
+}
+
+func attachCombo(grid *gtk.Grid, label string, row int) *gtk.ComboBoxText {
+	lbl, _ := gtk.LabelNew(label)
+	lbl.SetHAlign(gtk.ALIGN_START)
+	combo, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		log.Fatal("Unable to create ComboBoxText:", err)
+	}
+	grid.Attach(lbl, 0, row, 1, 1)
+	grid.Attach(combo, 1, row, 1, 1)
+	return combo
+}
+
+func getRequestAuth(
+	tokens *communication.TokenCache,
+	settings *storage.Settings,
+	cs *storage.CookieStorage,
+	environments *EnvironmentSwitcher,
+	errorDiag *ErrorDialog,
+) (*gtk.ScrolledWindow, *RequestAuth) {
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create authGrid:", err)
+	}
+	grid.SetRowSpacing(5)
+	grid.SetColumnSpacing(10)
+	setMargins(grid, 10, 10, 10, 10)
+
+	ra := &RequestAuth{
+		tokens:       tokens,
+		settings:     settings,
+		cs:           cs,
+		environments: environments,
+		errorDiag:    errorDiag,
+	}
+	ra.scheme = attachCombo(grid, "Type", 0)
+	ra.scheme.Append(communication.AuthNone, "No auth")
+	ra.scheme.Append(communication.AuthBasic, "Basic auth")
+	ra.scheme.Append(communication.AuthBearer, "Bearer token")
+	ra.scheme.Append(communication.AuthAPIKey, "API key")
+	ra.scheme.Append(communication.AuthDigest, "Digest auth")
+	ra.scheme.Append(communication.AuthOAuth2, "OAuth 2.0")
+
+	attachSectionLabel(grid, "<b>Username and password</b> (Basic, Digest and the OAuth 2.0 password grant)", 1)
+	ra.username = attachEntry(grid, "Username", "", 2)
+	ra.password = attachEntry(grid, "Password", "", 3)
+	ra.password.SetVisibility(false)
+
+	attachSectionLabel(grid, "<b>Bearer token</b>", 4)
+	ra.token = attachEntry(grid, "Token", "Sent as Authorization: Bearer <token>", 5)
+
+	attachSectionLabel(grid, "<b>API key</b>", 6)
+	ra.keyName = attachEntry(grid, "Key", "X-API-Key", 7)
+	ra.keyValue = attachEntry(grid, "Value", "", 8)
+	ra.keyIn = attachCombo(grid, "Add to", 9)
+	ra.keyIn.Append(communication.APIKeyHeader, "Header")
+	ra.keyIn.Append(communication.APIKeyQuery, "Query params")
+
+	attachSectionLabel(grid, "<b>OAuth 2.0</b>", 10)
+	ra.grant = attachCombo(grid, "Grant", 11)
+	ra.grant.Append(communication.OAuth2ClientCredentials, "Client credentials")
+	ra.grant.Append(communication.OAuth2Password, "Password")
+	ra.grant.Append(communication.OAuth2AuthorizationCode, "Authorization code with PKCE")
+	ra.tokenURL = attachEntry(grid, "Token URL", "https://auth.example.com/oauth/token", 12)
+	ra.authURL = attachEntry(grid, "Authorization URL", "https://auth.example.com/oauth/authorize", 13)
+	ra.redirectURL = attachEntry(grid, "Redirect URL", communication.DefaultRedirectURL+" (a missing port picks a free one)", 14)
+	ra.clientID = attachEntry(grid, "Client ID", "", 15)
+	ra.clientSecret = attachEntry(grid, "Client secret", "", 16)
+	ra.clientSecret.SetVisibility(false)
+	ra.scope = attachEntry(grid, "Scope", "read write", 17)
+	ra.credentialsInBody, err = gtk.CheckButtonNewWithLabel("Send the client credentials in the body instead of Basic auth")
+	if err != nil {
+		log.Fatal("Unable to create CheckButton:", err)
+	}
+	grid.Attach(ra.credentialsInBody, 1, 18, 1, 1)
+
+	tokenBox, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	if err != nil {
+		log.Fatal("Unable to create tokenBox:", err)
+	}
+	ra.getToken, _ = gtk.ButtonNewWithLabel("Get new token")
+	ra.forgetToken, _ = gtk.ButtonNewWithLabel("Forget token")
+	ra.tokenStatus, _ = gtk.LabelNew("")
+	ra.tokenStatus.SetHAlign(gtk.ALIGN_START)
+	tokenBox.PackStart(ra.getToken, false, false, 0)
+	tokenBox.PackStart(ra.forgetToken, false, false, 0)
+	tokenBox.PackStart(ra.tokenStatus, true, true, 5)
+	grid.Attach(tokenBox, 1, 19, 1, 1)
+
+	noteLbl, _ := gtk.LabelNew("The credentials are added when the request is sent and take precedence over headers of the same name, {{variables}} can be used in every field. OAuth 2.0 tokens are kept until the app is closed and refreshed before they expire.")
+	noteLbl.SetHAlign(gtk.ALIGN_START)
+	noteLbl.SetLineWrap(true)
+	noteLbl.SetMarginTop(10)
+	grid.Attach(noteLbl, 0, 20, 2, 1)
+
+	ra.scheme.Connect("changed", func() {
+		ra.updateSensitivity()
+		ra.updateTokenStatus()
+	})
+	ra.grant.Connect("changed", func() {
+		ra.updateSensitivity()
+		ra.updateTokenStatus()
+	})
+	ra.getToken.Connect("clicked", ra.requestToken)
+	ra.forgetToken.Connect("clicked", func() {
+		auth, _ := ra.resolvedAuth()
+		ra.tokens.Forget(auth)
+		ra.updateTokenStatus()
+	})
+	ra.Reset()
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(grid)
+
+	return scrolledWindow, ra
+}
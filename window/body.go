@@ -0,0 +1,404 @@
+package window
+
+import (
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/communication"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// IDs to access the form part columns by
+const (
+	PartColumnEnabled = iota
+	PartColumnKey
+	PartColumnValue
+	PartColumnFile
+)
+
+var bodyModes = []struct{ id, label string }{
+	{communication.BodyNone, "None"},
+	{communication.BodyRaw, "Raw"},
+	{communication.BodyForm, "x-www-form-urlencoded"},
+	{communication.BodyMultipart, "Multipart form"},
+	{communication.BodyBinary, "Binary file"},
+}
+
+var rawTypes = []struct{ id, label string }{
+	{"", "Unspecified"},
+	{communication.RawText, "Text"},
+	{communication.RawJSON, "JSON"},
+	{communication.RawXML, "XML"},
+}
+
+// RequestBody holds the widgets of the request "Body" tab
+type RequestBody struct {
+	forceBody *gtk.CheckButton
+	mode      *gtk.ComboBoxText
+	rawType   *gtk.ComboBoxText
+	text      *gtk.TextView
+	form      *PartsEditor
+	multipart *PartsEditor
+	file      *gtk.FileChooserButton
+}
+
+// MarkUnresolved highlights the variables of the raw body that have no value
+func (rb *RequestBody) MarkUnresolved(vars map[string]string) {
+	buff, err := rb.text.GetBuffer()
+	if err != nil {
+		log.Fatal("Unable to retrieve TextBuffer:", err)
+	}
+	markUnresolvedText(buff, vars)
+}
+
+// MethodChanged offers to send the body when the method does not usually carry one
+func (rb *RequestBody) MethodChanged(method string) {
+	rb.forceBody.SetVisible(!communication.MethodHasBody(method))
+}
+
+// Load displays the body of a stored request
+func (rb *RequestBody) Load(rq storage.RequestInput) {
+	rqTxtBuff, _ := rb.text.GetBuffer()
+	rqTxtBuff.SetText(rq.Body)
+	rb.forceBody.SetActive(rq.ForceBody)
+	rb.MethodChanged(rq.Method)
+
+	mode := rq.BodyMode
+	if mode == "" {
+		mode = communication.BodyRaw
+	}
+	rb.mode.SetActiveID(mode)
+	rb.rawType.SetActiveID(rq.RawType)
+
+	rb.form.SetParts(nil)
+	rb.multipart.SetParts(nil)
+	switch mode {
+	case communication.BodyForm:
+		rb.form.SetParts(rq.BodyParts)
+	case communication.BodyMultipart:
+		rb.multipart.SetParts(rq.BodyParts)
+	}
+
+	if rq.BodyFile != "" {
+		rb.file.SetFilename(rq.BodyFile)
+	} else {
+		rb.file.UnselectAll()
+	}
+}
+
+// Apply stores the body currently set in the body tab into the request
+func (rb *RequestBody) Apply(rq *storage.RequestInput) {
+	body, err := getText(rb.text)
+	if err != nil {
+		log.Fatal("Unable to retrieve text from requestTextView:", err)
+	}
+	rq.Body = body
+	rq.ForceBody = rb.forceBody.GetActive()
+	rq.BodyMode = rb.mode.GetActiveID()
+	rq.RawType = rb.rawType.GetActiveID()
+	switch rq.BodyMode {
+	case communication.BodyForm:
+		rq.BodyParts = rb.form.Parts()
+	case communication.BodyMultipart:
+		rq.BodyParts = rb.multipart.Parts()
+	}
+	rq.BodyFile = rb.file.GetFilename()
+}
+
+// Reset clears the body tab
+func (rb *RequestBody) Reset(method string) {
+	rb.Load(storage.RequestInput{Method: method})
+}
+
+func getRequestBody(environments *EnvironmentSwitcher) (*gtk.Grid, *RequestBody) {
+	bodyGrid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create bodyGrid:", err)
+	}
+	bodyGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	modeBox, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	if err != nil {
+		log.Fatal("Unable to create modeBox:", err)
+	}
+	setMargins(modeBox, 5, 5, 5, 5)
+
+	mode, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		log.Fatal("Unable to create ComboBoxText:", err)
+	}
+	for _, m := range bodyModes {
+		mode.Append(m.id, m.label)
+	}
+
+	rawType, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		log.Fatal("Unable to create ComboBoxText:", err)
+	}
+	for _, t := range rawTypes {
+		rawType.Append(t.id, t.label)
+	}
+	rawType.SetTooltipText("The Content-Type the raw body is sent with")
+	rawType.SetNoShowAll(true)
+
+	forceBody, err := gtk.CheckButtonNewWithLabel("Send the body with this method")
+	if err != nil {
+		log.Fatal("Unable to create CheckButton:", err)
+	}
+	forceBody.SetNoShowAll(true)
+
+	modeBox.PackStart(mode, false, false, 0)
+	modeBox.PackStart(rawType, false, false, 0)
+	modeBox.PackEnd(forceBody, false, false, 0)
+
+	stack, err := gtk.StackNew()
+	if err != nil {
+		log.Fatal("Unable to create Stack:", err)
+	}
+	stack.SetVExpand(true)
+	stack.SetHExpand(true)
+
+	noneLbl, err := gtk.LabelNew("This request has no body")
+	if err != nil {
+		log.Fatal("Unable to create label:", err)
+	}
+	requestBodyWindow, requestText := getScrollableTextView("Request")
+	if buff, err := requestText.GetBuffer(); err == nil {
+		buff.Connect("changed", func() {
+			markUnresolvedText(buff, environments.Variables())
+		})
+	}
+	formGrid, form := getPartsEditor(false)
+	multipartGrid, multipart := getPartsEditor(true)
+
+	fileGrid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create fileGrid:", err)
+	}
+	setMargins(fileGrid, 10, 10, 10, 10)
+	fileGrid.SetColumnSpacing(10)
+	fileLbl, err := gtk.LabelNew("File")
+	if err != nil {
+		log.Fatal("Unable to create label:", err)
+	}
+	file, err := gtk.FileChooserButtonNew("Select the body file", gtk.FILE_CHOOSER_ACTION_OPEN)
+	if err != nil {
+		log.Fatal("Unable to create FileChooserButton:", err)
+	}
+	file.SetHExpand(true)
+	fileGrid.Attach(fileLbl, 0, 0, 1, 1)
+	fileGrid.Attach(file, 1, 0, 1, 1)
+
+	stack.AddNamed(noneLbl, communication.BodyNone)
+	stack.AddNamed(requestBodyWindow, communication.BodyRaw)
+	stack.AddNamed(formGrid, communication.BodyForm)
+	stack.AddNamed(multipartGrid, communication.BodyMultipart)
+	stack.AddNamed(fileGrid, communication.BodyBinary)
+
+	mode.Connect("changed", func() {
+		id := mode.GetActiveID()
+		if id == "" {
+			return
+		}
+		stack.SetVisibleChildName(id)
+		rawType.SetVisible(id == communication.BodyRaw)
+	})
+
+	bodyGrid.Add(modeBox)
+	bodyGrid.Add(stack)
+
+	return bodyGrid, &RequestBody{forceBody, mode, rawType, requestText, form, multipart, file}
+}
+
+// PartsEditor lists the fields of an urlencoded or multipart form
+type PartsEditor struct {
+	store *gtk.ListStore
+}
+
+// Parts returns the fields listed in the editor
+func (pe *PartsEditor) Parts() []communication.FormPart {
+	var parts []communication.FormPart
+	iter, ok := pe.store.GetIterFirst()
+	for ok {
+		enabled, _ := pe.store.GetValue(iter, PartColumnEnabled)
+		file, _ := pe.store.GetValue(iter, PartColumnFile)
+		enabledVal, _ := enabled.GoValue()
+		fileVal, _ := file.GoValue()
+		key, _ := getStringValue(pe.store, iter, PartColumnKey)
+		value, _ := getStringValue(pe.store, iter, PartColumnValue)
+		parts = append(parts, communication.FormPart{
+			Key:      key,
+			Value:    value,
+			File:     fileVal == true,
+			Disabled: enabledVal != true,
+		})
+		ok = pe.store.IterNext(iter)
+	}
+	return parts
+}
+
+// SetParts replaces the fields listed in the editor
+func (pe *PartsEditor) SetParts(parts []communication.FormPart) {
+	pe.store.Clear()
+	for _, p := range parts {
+		pe.add(p)
+	}
+}
+
+func (pe *PartsEditor) add(p communication.FormPart) {
+	err := pe.store.Set(pe.store.Append(),
+		[]int{PartColumnEnabled, PartColumnKey, PartColumnValue, PartColumnFile},
+		[]interface{}{!p.Disabled, p.Key, p.Value, p.File})
+	if err != nil {
+		log.Fatal("Unable to add row:", err)
+	}
+}
+
+// toggleColumn flips a boolean column of the edited row
+func (pe *PartsEditor) toggleColumn(id int) func(crt *gtk.CellRendererToggle, row string) {
+	return func(crt *gtk.CellRendererToggle, row string) {
+		rowIter, err := pe.store.GetIterFromString(row)
+		if err != nil {
+			log.Fatal("Unable to get row iter:", err)
+		}
+		value, _ := pe.store.GetValue(rowIter, id)
+		current, _ := value.GoValue()
+		pe.store.SetValue(rowIter, id, current != true)
+	}
+}
+
+func getPartsEditor(withFiles bool) (*gtk.Grid, *PartsEditor) {
+	partsGrid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create partsGrid:", err)
+	}
+	partsGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	treeView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	treeView.SetHExpand(true)
+	treeView.SetVExpand(true)
+
+	partsStore, err := gtk.ListStoreNew(glib.TYPE_BOOLEAN, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_BOOLEAN)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+	treeView.SetModel(partsStore)
+
+	pe := &PartsEditor{partsStore}
+
+	toggleIDs := []int{PartColumnEnabled}
+	if withFiles {
+		toggleIDs = append(toggleIDs, PartColumnFile)
+	}
+	for _, id := range toggleIDs {
+		title := ""
+		if id == PartColumnFile {
+			title = "File"
+		}
+		toggleRenderer, err := gtk.CellRendererToggleNew()
+		if err != nil {
+			log.Fatal("Unable to create toggle cell renderer:", err)
+		}
+		toggleRenderer.Connect("toggled", pe.toggleColumn(id))
+		column, err := gtk.TreeViewColumnNewWithAttribute(title, toggleRenderer, "active", id)
+		if err != nil {
+			log.Fatal("Unable to create cell column:", err)
+		}
+		treeView.AppendColumn(column)
+	}
+
+	for _, id := range []int{PartColumnKey, PartColumnValue} {
+		title := "Field Name"
+		if id == PartColumnValue {
+			title = "Field Value"
+		}
+		cellRenderer, err := gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal("Unable to create text cell renderer:", err)
+		}
+		cellRenderer.SetProperty("editable", true)
+		columnID := id
+		cellRenderer.Connect("edited", func(crt *gtk.CellRendererText, row string, value string) {
+			rowIter, err := partsStore.GetIterFromString(row)
+			if err != nil {
+				log.Fatal("Unable to get row iter:", err)
+			}
+			partsStore.SetValue(rowIter, columnID, value)
+		})
+		column, err := gtk.TreeViewColumnNewWithAttribute(title, cellRenderer, "text", id)
+		if err != nil {
+			log.Fatal("Unable to create cell column:", err)
+		}
+		column.SetResizable(true)
+		treeView.AppendColumn(column)
+	}
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(treeView)
+	scrolledWindow.SetVExpand(true)
+
+	buttonBox, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	if err != nil {
+		log.Fatal("Unable to create parts button box:", err)
+	}
+	setMargins(buttonBox, 5, 5, 5, 0)
+
+	deletePartBtn, _ := gtk.ButtonNewWithLabel("Delete selected field")
+	addPartBtn, _ := gtk.ButtonNewWithLabel("Add a new field")
+
+	addPartBtn.Connect("clicked", func() {
+		pe.add(communication.FormPart{Key: "name", Value: "value"})
+	})
+
+	deletePartBtn.Connect("clicked", func() {
+		selection, err := treeView.GetSelection()
+		if err != nil {
+			log.Fatal("Unable to get tree view selection:", err)
+		}
+		selection.GetSelectedRows(&partsStore.TreeModel).Foreach(func(item interface{}) {
+			iter, err := partsStore.GetIter(item.(*gtk.TreePath))
+			if err != nil {
+				log.Fatal("Unable to get tree view iter:", err)
+			}
+			partsStore.Remove(iter)
+		})
+	})
+
+	buttonBox.SetVAlign(gtk.ALIGN_END)
+	buttonBox.PackEnd(deletePartBtn, false, false, 3)
+	if withFiles {
+		addFileBtn, _ := gtk.ButtonNewWithLabel("Add a file")
+		addFileBtn.Connect("clicked", func() {
+			dialog, err := gtk.FileChooserDialogNewWith2Buttons(
+				"Select the file to upload",
+				nil,
+				gtk.FILE_CHOOSER_ACTION_OPEN,
+				"Cancel", gtk.RESPONSE_CANCEL,
+				"Select", gtk.RESPONSE_ACCEPT,
+			)
+			if err != nil {
+				log.Fatal("Unable to create FileChooserDialog:", err)
+			}
+			if dialog.Run() == gtk.RESPONSE_ACCEPT {
+				pe.add(communication.FormPart{Key: "file", Value: dialog.GetFilename(), File: true})
+			}
+			dialog.Destroy()
+		})
+		buttonBox.PackEnd(addFileBtn, false, false, 3)
+	}
+	buttonBox.PackEnd(addPartBtn, false, false, 3)
+
+	sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
+
+	partsGrid.Add(scrolledWindow)
+	partsGrid.Add(sep)
+	partsGrid.Add(buttonBox)
+
+	return partsGrid, pe
+}
@@ -0,0 +1,96 @@
+package window
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/communication"
+	log "github.com/sirupsen/logrus"
+)
+
+// certificateExpiryWarning is how close to its expiry a certificate gets flagged
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+const certificateDateFormat = "2006-01-02 15:04:05 MST"
+
+// CertificateView shows the TLS connection details and the peer certificate chain
+type CertificateView struct {
+	warningLbl *gtk.Label
+	treeView   *gtk.TreeView
+	store      *gtk.TreeStore
+}
+
+// SetTLSInfo replaces the displayed connection details, nil clears the view
+func (cv *CertificateView) SetTLSInfo(info *communication.TLSInfo) {
+	cv.store.Clear()
+	cv.warningLbl.SetVisible(false)
+	if info == nil {
+		AddRowToTreeStore(cv.store, nil, "No TLS connection", "")
+		return
+	}
+
+	connection := AddRowToTreeStore(cv.store, nil, "Connection", "")
+	AddRowToTreeStore(cv.store, connection, "TLS version", info.Version)
+	AddRowToTreeStore(cv.store, connection, "Cipher suite", info.CipherSuite)
+	AddRowToTreeStore(cv.store, connection, "ALPN protocol", info.ALPN)
+	AddRowToTreeStore(cv.store, connection, "Server name", info.ServerName)
+
+	var warnings []string
+	now := time.Now()
+	for idx, cert := range info.Certificates {
+		status := "Valid"
+		if cert.Expired(now) {
+			status = "Expired or not yet valid"
+			warnings = append(warnings, fmt.Sprintf("Certificate #%d (%s) is not valid at this time", idx+1, cert.Subject))
+		} else if cert.ExpiresWithin(now, certificateExpiryWarning) {
+			status = fmt.Sprintf("Expires in %d days", int(cert.NotAfter.Sub(now).Hours()/24))
+			warnings = append(warnings, fmt.Sprintf("Certificate #%d (%s) expires on %s", idx+1, cert.Subject, cert.NotAfter.Format(certificateDateFormat)))
+		}
+
+		iter := AddRowToTreeStore(cv.store, nil, fmt.Sprintf("Certificate #%d", idx+1), cert.Subject)
+		AddRowToTreeStore(cv.store, iter, "Status", status)
+		AddRowToTreeStore(cv.store, iter, "Subject", cert.Subject)
+		AddRowToTreeStore(cv.store, iter, "Issuer", cert.Issuer)
+		AddRowToTreeStore(cv.store, iter, "Subject alternative names", strings.Join(cert.SANs, ", "))
+		AddRowToTreeStore(cv.store, iter, "Serial number", cert.SerialNumber)
+		AddRowToTreeStore(cv.store, iter, "Valid from", cert.NotBefore.Format(certificateDateFormat))
+		AddRowToTreeStore(cv.store, iter, "Valid until", cert.NotAfter.Format(certificateDateFormat))
+		AddRowToTreeStore(cv.store, iter, "Public key", cert.KeyAlgorithm)
+		AddRowToTreeStore(cv.store, iter, "Signature algorithm", cert.SignatureAlgorithm)
+	}
+
+	if len(warnings) > 0 {
+		cv.warningLbl.SetMarkup(fmt.Sprintf("<span foreground='red'>%s</span>", html.EscapeString(strings.Join(warnings, "\n"))))
+		cv.warningLbl.SetVisible(true)
+	}
+
+	// Show every section expanded, the chain is usually short
+	cv.treeView.ExpandAll()
+}
+
+func getCertificateView() (*gtk.Grid, *CertificateView) {
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create certificate grid:", err)
+	}
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	warningLbl, _ := gtk.LabelNew("")
+	warningLbl.SetHAlign(gtk.ALIGN_START)
+	warningLbl.SetNoShowAll(true)
+	setMargins(warningLbl, 5, 5, 5, 5)
+
+	scrolledWindow, treeView, treeStore := setupTreeStoreView("Field", "Value")
+	scrolledWindow.SetVExpand(true)
+
+	grid.Add(warningLbl)
+	grid.Add(scrolledWindow)
+
+	cv := &CertificateView{warningLbl, treeView, treeStore}
+	cv.SetTLSInfo(nil)
+
+	return grid, cv
+}
@@ -0,0 +1,385 @@
+package window
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	evbus "github.com/asaskevich/EventBus"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// IDs to access the collection tree columns by
+const (
+	CollectionColumnLabel = iota
+	CollectionColumnID
+)
+
+// CollectionsView shows the saved request collections as a tree
+type CollectionsView struct {
+	cs          *storage.CollectionStorage
+	win         *gtk.ApplicationWindow
+	store       *gtk.TreeStore
+	view        *gtk.TreeView
+	collections []*storage.CollectionItem
+	// items indexes every listed item by ID
+	items map[string]*storage.CollectionItem
+	// loading is set while the tree is refilled
+	loading bool
+}
+
+// Reload refills the tree from the storage
+func (cv *CollectionsView) Reload() {
+	cv.loading = true
+	defer func() { cv.loading = false }()
+
+	cv.collections = cv.cs.GetAll()
+	cv.items = map[string]*storage.CollectionItem{}
+	cv.store.Clear()
+	cv.addItems(nil, cv.collections)
+	cv.view.ExpandAll()
+}
+
+func (cv *CollectionsView) addItems(parent *gtk.TreeIter, items []*storage.CollectionItem) {
+	for _, item := range items {
+		cv.items[item.ID] = item
+		label := fmt.Sprintf("<b>%s</b>", html.EscapeString(item.Name))
+		if !item.IsFolder() {
+			label = fmt.Sprintf("<span foreground='grey'>%s</span> %s", html.EscapeString(item.Request.Method), html.EscapeString(item.Name))
+		}
+		iter := cv.store.Append(parent)
+		cv.store.SetValue(iter, CollectionColumnLabel, label)
+		cv.store.SetValue(iter, CollectionColumnID, item.ID)
+		cv.addItems(iter, item.Items)
+	}
+}
+
+// readItems rebuilds the item tree from the rows of the tree store
+func (cv *CollectionsView) readItems(parent *gtk.TreeIter) []*storage.CollectionItem {
+	var items []*storage.CollectionItem
+	for idx := 0; idx < cv.store.IterNChildren(parent); idx++ {
+		iter := &gtk.TreeIter{}
+		if !cv.store.IterNthChild(iter, parent, idx) {
+			break
+		}
+		value, err := cv.store.GetValue(iter, CollectionColumnID)
+		if err != nil {
+			log.Fatal("Unable to get collection item id:", err)
+		}
+		id, _ := value.GetString()
+		item, ok := cv.items[id]
+		if !ok {
+			continue
+		}
+		copied := *item
+		copied.Items = cv.readItems(iter)
+		items = append(items, &copied)
+	}
+	return items
+}
+
+// reordered stores the tree after the user rearranged it
+func (cv *CollectionsView) reordered() {
+	cv.cs.SaveAll(storage.NormalizeCollections(cv.readItems(nil)))
+	cv.Reload()
+}
+
+// selected returns the selected item, nil when nothing is selected
+func (cv *CollectionsView) selected() *storage.CollectionItem {
+	selection, err := cv.view.GetSelection()
+	if err != nil {
+		log.Fatal("Unable to get tree view selection:", err)
+	}
+	_, iter, ok := selection.GetSelected()
+	if !ok {
+		return nil
+	}
+	value, err := cv.store.GetValue(iter, CollectionColumnID)
+	if err != nil {
+		log.Fatal("Unable to get collection item id:", err)
+	}
+	id, _ := value.GetString()
+	return cv.items[id]
+}
+
+// rootOf returns the collection holding the item
+func (cv *CollectionsView) rootOf(id string) *storage.CollectionItem {
+	for _, collection := range cv.collections {
+		if collection.Find(id) != nil {
+			return collection
+		}
+	}
+	return nil
+}
+
+// folderPaths lists every collection and folder with its full path, in tree order
+func (cv *CollectionsView) folderPaths() ([]string, []*storage.CollectionItem) {
+	var paths []string
+	var folders []*storage.CollectionItem
+	var walk func(prefix string, items []*storage.CollectionItem)
+	walk = func(prefix string, items []*storage.CollectionItem) {
+		for _, item := range items {
+			if !item.IsFolder() {
+				continue
+			}
+			path := prefix + item.Name
+			paths = append(paths, path)
+			folders = append(folders, item)
+			walk(path+" / ", item.Items)
+		}
+	}
+	walk("", cv.collections)
+	return paths, folders
+}
+
+// addToFolder stores a new item inside a folder, a nil folder adds a new collection
+func (cv *CollectionsView) addToFolder(folder *storage.CollectionItem, item *storage.CollectionItem) {
+	if folder == nil {
+		item.Position = len(cv.collections)
+		cv.cs.Put(item)
+	} else {
+		folder.Items = append(folder.Items, item)
+		cv.cs.Put(cv.rootOf(folder.ID))
+	}
+	cv.Reload()
+}
+
+// PromptSave asks where to save the request and under which name
+func (cv *CollectionsView) PromptSave(request storage.RequestInput) {
+	dialog, err := gtk.DialogNewWithButtons("Save to collection", cv.win, gtk.DIALOG_MODAL,
+		[]interface{}{"Cancel", gtk.RESPONSE_CANCEL},
+		[]interface{}{"Save", gtk.RESPONSE_ACCEPT},
+	)
+	if err != nil {
+		log.Fatal("Unable to create dialog:", err)
+	}
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+
+	grid, _ := gtk.GridNew()
+	setMargins(grid, 10, 10, 10, 10)
+	grid.SetRowSpacing(5)
+	grid.SetColumnSpacing(10)
+
+	nameEntry := attachEntry(grid, "Name", "", 0)
+	nameEntry.SetText(request.Method + " " + request.Path)
+	nameEntry.SetActivatesDefault(true)
+
+	paths, folders := cv.folderPaths()
+	folderLbl, _ := gtk.LabelNew("Folder")
+	folderLbl.SetHAlign(gtk.ALIGN_START)
+	folderCombo, _ := gtk.ComboBoxTextNew()
+	for _, path := range paths {
+		folderCombo.AppendText(path)
+	}
+	if len(paths) == 0 {
+		folderCombo.AppendText(storage.DefaultCollectionName)
+	}
+	folderCombo.SetActive(0)
+	if current := cv.selected(); current != nil {
+		for idx, folder := range folders {
+			if folder.Find(current.ID) != nil {
+				folderCombo.SetActive(idx)
+			}
+		}
+	}
+	grid.Attach(folderLbl, 0, 1, 1, 1)
+	grid.Attach(folderCombo, 1, 1, 1, 1)
+
+	content, _ := dialog.GetContentArea()
+	content.Add(grid)
+	dialog.ShowAll()
+
+	response := dialog.Run()
+	name := strings.TrimSpace(entryText(nameEntry))
+	folderIdx := folderCombo.GetActive()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_ACCEPT {
+		return
+	}
+	if name == "" {
+		name = request.Method + " " + request.Path
+	}
+
+	item := storage.NewCollectionItem(name, &request)
+	if len(folders) == 0 {
+		collection := storage.NewCollectionItem(storage.DefaultCollectionName, nil)
+		collection.Items = []*storage.CollectionItem{item}
+		cv.addToFolder(nil, collection)
+		return
+	}
+	cv.addToFolder(folders[folderIdx], item)
+}
+
+// promptName asks for the name of a collection or folder
+func (cv *CollectionsView) promptName(title, initial string) (string, bool) {
+	dialog, err := gtk.DialogNewWithButtons(title, cv.win, gtk.DIALOG_MODAL,
+		[]interface{}{"Cancel", gtk.RESPONSE_CANCEL},
+		[]interface{}{"OK", gtk.RESPONSE_ACCEPT},
+	)
+	if err != nil {
+		log.Fatal("Unable to create dialog:", err)
+	}
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+
+	grid, _ := gtk.GridNew()
+	setMargins(grid, 10, 10, 10, 10)
+	grid.SetColumnSpacing(10)
+	nameEntry := attachEntry(grid, "Name", "", 0)
+	nameEntry.SetText(initial)
+	nameEntry.SetActivatesDefault(true)
+
+	content, _ := dialog.GetContentArea()
+	content.Add(grid)
+	dialog.ShowAll()
+
+	response := dialog.Run()
+	name := strings.TrimSpace(entryText(nameEntry))
+	dialog.Destroy()
+
+	return name, response == gtk.RESPONSE_ACCEPT && name != ""
+}
+
+func getCollectionsView(
+	cs *storage.CollectionStorage,
+	win *gtk.ApplicationWindow,
+	confirmDiag *ConfirmationDialog,
+	bus evbus.Bus,
+) (*gtk.Grid, *CollectionsView) {
+	collectionsGrid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create collectionsGrid:", err)
+	}
+	collectionsGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	treeView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	treeView.SetHeadersVisible(false)
+	treeView.SetReorderable(true)
+	treeView.SetVExpand(true)
+	treeView.SetHExpand(true)
+	treeView.SetTooltipText("Drag the requests and folders to rearrange them")
+
+	treeStore, err := gtk.TreeStoreNew(glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create tree store:", err)
+	}
+	treeView.SetModel(treeStore)
+
+	cellRenderer, err := gtk.CellRendererTextNew()
+	if err != nil {
+		log.Fatal("Unable to create text cell renderer:", err)
+	}
+	column, err := gtk.TreeViewColumnNewWithAttribute("Collections", cellRenderer, "markup", CollectionColumnLabel)
+	if err != nil {
+		log.Fatal("Unable to create cell column:", err)
+	}
+	treeView.AppendColumn(column)
+
+	cv := &CollectionsView{
+		cs:    cs,
+		win:   win,
+		store: treeStore,
+		view:  treeView,
+	}
+	cv.Reload()
+
+	// the tree store moves the dragged rows itself, the result is stored once the drag is over
+	treeView.Connect("drag-end", cv.reordered)
+
+	selection, err := treeView.GetSelection()
+	if err != nil {
+		log.Fatal("Unable to get tree view selection:", err)
+	}
+	selection.Connect("changed", func() {
+		if cv.loading {
+			return
+		}
+		if item := cv.selected(); item != nil && !item.IsFolder() {
+			bus.Publish("request:loaded", storage.RequestResponse{Request: *item.Request})
+		}
+	})
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(treeView)
+	scrolledWindow.SetVExpand(true)
+
+	buttonBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
+	setMargins(buttonBox, 5, 5, 5, 5)
+
+	newCollectionBtn, _ := gtk.ButtonNewFromIconName("list-add-symbolic", gtk.ICON_SIZE_BUTTON)
+	newCollectionBtn.SetTooltipText("Add a new collection")
+	newFolderBtn, _ := gtk.ButtonNewFromIconName("folder-new-symbolic", gtk.ICON_SIZE_BUTTON)
+	newFolderBtn.SetTooltipText("Add a folder to the selected collection or folder")
+	renameBtn, _ := gtk.ButtonNewFromIconName("document-edit-symbolic", gtk.ICON_SIZE_BUTTON)
+	renameBtn.SetTooltipText("Rename the selected item")
+	deleteBtn, _ := gtk.ButtonNewFromIconName("edit-delete-symbolic", gtk.ICON_SIZE_BUTTON)
+	deleteBtn.SetTooltipText("Delete the selected item")
+
+	newCollectionBtn.Connect("clicked", func() {
+		if name, ok := cv.promptName("New collection", ""); ok {
+			cv.addToFolder(nil, storage.NewCollectionItem(name, nil))
+		}
+	})
+	newFolderBtn.Connect("clicked", func() {
+		parent := cv.selected()
+		if parent == nil || !parent.IsFolder() {
+			return
+		}
+		if name, ok := cv.promptName("New folder", ""); ok {
+			cv.addToFolder(parent, storage.NewCollectionItem(name, nil))
+		}
+	})
+	renameBtn.Connect("clicked", func() {
+		item := cv.selected()
+		if item == nil {
+			return
+		}
+		if name, ok := cv.promptName("Rename", item.Name); ok {
+			item.Name = name
+			cv.cs.Put(cv.rootOf(item.ID))
+			cv.Reload()
+		}
+	})
+	deleteBtn.Connect("clicked", func() {
+		item := cv.selected()
+		if item == nil {
+			return
+		}
+		question := fmt.Sprintf("This will delete \"%s\".\nAre you sure that you want to proceed?", item.Name)
+		if item.IsFolder() && len(item.Items) > 0 {
+			question = fmt.Sprintf("This will delete \"%s\" and everything in it.\nAre you sure that you want to proceed?", item.Name)
+		}
+		confirmDiag.Confirm(question, func(yes bool) {
+			if !yes {
+				return
+			}
+			root := cv.rootOf(item.ID)
+			if root == item {
+				cv.cs.Remove(item.ID)
+			} else {
+				root.Remove(item.ID)
+				cv.cs.Put(root)
+			}
+			cv.Reload()
+		})
+	})
+
+	buttonBox.PackStart(newCollectionBtn, false, false, 0)
+	buttonBox.PackStart(newFolderBtn, false, false, 0)
+	buttonBox.PackEnd(deleteBtn, false, false, 0)
+	buttonBox.PackEnd(renameBtn, false, false, 0)
+
+	collectionsGrid.Add(scrolledWindow)
+	collectionsGrid.Add(buttonBox)
+
+	return collectionsGrid, cv
+}
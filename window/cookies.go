@@ -0,0 +1,154 @@
+package window
+
+import (
+	"strconv"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// IDs to access the cookie manager columns by
+const (
+	CookieColumnDomain = iota
+	CookieColumnPath
+	CookieColumnName
+	CookieColumnValue
+	CookieColumnExpires
+)
+
+const cookieDateFormat = "2006-01-02 15:04:05"
+
+type CookieManager struct {
+	widget *gtk.Window
+	Show   func()
+}
+
+func getCookieManager(cs *storage.CookieStorage, confirmDiag *ConfirmationDialog) *CookieManager {
+	cookieWin, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	cookieWin.SetTitle("Cookies")
+	cookieWin.SetPosition(gtk.WIN_POS_MOUSE)
+	cookieWin.SetDefaultSize(700, 400)
+	cookieWin.Connect("delete-event", func() bool {
+		cookieWin.Hide()
+		return true
+	})
+
+	grid, _ := gtk.GridNew()
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	treeView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	treeView.SetHExpand(true)
+	treeView.SetVExpand(true)
+
+	cookieStore, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+	treeView.SetModel(cookieStore)
+
+	// cookies holds the displayed cookies in row order
+	var cookies []storage.Cookie
+
+	refresh := func() {
+		cookieStore.Clear()
+		cookies = cs.GetAll()
+		for _, cookie := range cookies {
+			expires := "Session"
+			if !cookie.Expires.IsZero() {
+				expires = cookie.Expires.Local().Format(cookieDateFormat)
+			}
+			domain := cookie.Domain
+			if !cookie.HostOnly {
+				domain = "." + domain
+			}
+			err := cookieStore.Set(cookieStore.Append(),
+				[]int{CookieColumnDomain, CookieColumnPath, CookieColumnName, CookieColumnValue, CookieColumnExpires},
+				[]interface{}{domain, cookie.Path, cookie.Name, cookie.Value, expires})
+			if err != nil {
+				log.Fatal("Unable to add row:", err)
+			}
+		}
+	}
+
+	for id, title := range []string{"Domain", "Path", "Name", "Value", "Expires"} {
+		cellRenderer, err := gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal("Unable to create text cell renderer:", err)
+		}
+		if id == CookieColumnValue {
+			cellRenderer.SetProperty("editable", true)
+			cellRenderer.Connect("edited", func(crt *gtk.CellRendererText, row string, value string) {
+				idx, err := strconv.Atoi(row)
+				if err != nil || idx >= len(cookies) {
+					log.Printf("Invalid cookie row edited: %s", row)
+					return
+				}
+				cookie := cookies[idx]
+				cookie.Value = value
+				cs.Put(cookie)
+				refresh()
+			})
+		}
+		column, err := gtk.TreeViewColumnNewWithAttribute(title, cellRenderer, "text", id)
+		if err != nil {
+			log.Fatal("Unable to create cell column:", err)
+		}
+		column.SetResizable(true)
+		treeView.AppendColumn(column)
+	}
+
+	scrolledWindow, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolledWindow.Add(treeView)
+
+	buttonBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	setMargins(buttonBox, 5, 5, 5, 5)
+	deleteBtn, _ := gtk.ButtonNewWithLabel("Delete selected cookie")
+	clearBtn, _ := gtk.ButtonNewWithLabel("Delete all cookies")
+	closeBtn, _ := gtk.ButtonNewWithLabel("Close")
+
+	deleteBtn.Connect("clicked", func() {
+		selection, err := treeView.GetSelection()
+		if err != nil {
+			log.Fatal("Unable to get tree view selection:", err)
+		}
+		selection.GetSelectedRows(&cookieStore.TreeModel).Foreach(func(item interface{}) {
+			indices := item.(*gtk.TreePath).GetIndices()
+			if len(indices) > 0 && indices[0] < len(cookies) {
+				cs.Remove(cookies[indices[0]])
+			}
+		})
+		refresh()
+	})
+	clearBtn.Connect("clicked", func() {
+		confirmDiag.Confirm("This will delete every stored cookie.\nAre you sure that you want to proceed?", func(yes bool) {
+			if yes {
+				cs.RemoveAll()
+				refresh()
+			}
+		})
+	})
+	closeBtn.Connect("clicked", func() {
+		cookieWin.Hide()
+	})
+
+	buttonBox.PackEnd(closeBtn, false, false, 3)
+	buttonBox.PackEnd(clearBtn, false, false, 3)
+	buttonBox.PackEnd(deleteBtn, false, false, 3)
+
+	grid.Add(scrolledWindow)
+	grid.Add(buttonBox)
+	cookieWin.Add(grid)
+
+	showFunc := func() {
+		refresh()
+		cookieWin.ShowAll()
+		cookieWin.Present()
+	}
+
+	return &CookieManager{cookieWin, showFunc}
+}
@@ -0,0 +1,98 @@
+package window
+
+import (
+	"fmt"
+	"strings"
+
+	evbus "github.com/asaskevich/EventBus"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/communication"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// isCurlCommand reports whether the text looks like a curl command line
+func isCurlCommand(text string) bool {
+	text = strings.TrimSpace(text)
+	return text == "curl" || strings.HasPrefix(text, "curl ") || strings.HasPrefix(text, "curl\t") || strings.HasPrefix(text, "curl\\")
+}
+
+// loadCurl parses the curl command and opens it in the request editor
+func loadCurl(command string, bus evbus.Bus, errorDiag *ErrorDialog) {
+	request, err := storage.ParseCurl(strings.TrimSpace(command))
+	if err != nil {
+		errorDiag.ShowError(fmt.Sprintf("Unable to read the curl command.\n%s", err))
+		return
+	}
+	bus.Publish("request:loaded", storage.RequestResponse{
+		Request:  request,
+		Response: storage.RequestResult{Outcome: communication.OutcomeNotSent},
+	})
+}
+
+// attachCurlPaste loads curl commands pasted into the URL entry instead of inserting them
+func attachCurlPaste(pathInput *gtk.Entry, bus evbus.Bus, errorDiag *ErrorDialog) {
+	pathInput.Connect("paste-clipboard", func(entry *gtk.Entry) {
+		clipboard, err := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+		if err != nil {
+			log.Printf("Unable to access the clipboard: %s", err)
+			return
+		}
+		text, err := clipboard.WaitForText()
+		if err != nil || !isCurlCommand(text) {
+			return
+		}
+		entry.StopEmission("paste-clipboard")
+		loadCurl(text, bus, errorDiag)
+	})
+}
+
+// promptCurl asks for a curl command, multi-line commands can be pasted as they are
+func promptCurl(win *gtk.ApplicationWindow, bus evbus.Bus, errorDiag *ErrorDialog) func() {
+	return func() {
+		dialog, err := gtk.DialogNewWithButtons("Paste curl command", win, gtk.DIALOG_MODAL,
+			[]interface{}{"Cancel", gtk.RESPONSE_CANCEL},
+			[]interface{}{"Load", gtk.RESPONSE_ACCEPT},
+		)
+		if err != nil {
+			log.Fatal("Unable to create dialog:", err)
+		}
+		dialog.SetDefaultSize(600, 300)
+
+		textView, err := gtk.TextViewNew()
+		if err != nil {
+			log.Fatal("Unable to create TextView:", err)
+		}
+		textView.SetMonospace(true)
+		textView.SetWrapMode(gtk.WRAP_CHAR)
+		buff, _ := textView.GetBuffer()
+		if clipboard, err := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD); err == nil {
+			if text, err := clipboard.WaitForText(); err == nil && isCurlCommand(text) {
+				buff.SetText(text)
+			}
+		}
+
+		scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+		if err != nil {
+			log.Fatal("Unable to create ScrolledWindow:", err)
+		}
+		scrolledWindow.SetVExpand(true)
+		scrolledWindow.SetHExpand(true)
+		scrolledWindow.Add(textView)
+		setMargins(scrolledWindow, 10, 10, 10, 10)
+
+		content, _ := dialog.GetContentArea()
+		content.Add(scrolledWindow)
+		dialog.ShowAll()
+
+		response := dialog.Run()
+		start, end := buff.GetBounds()
+		command, _ := buff.GetText(start, end, false)
+		dialog.Destroy()
+		if response != gtk.RESPONSE_ACCEPT || strings.TrimSpace(command) == "" {
+			return
+		}
+		loadCurl(command, bus, errorDiag)
+	}
+}
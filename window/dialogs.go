@@ -122,6 +122,20 @@ func getSettingsDialog(win *gtk.ApplicationWindow, settings *storage.Settings, b
 	theme, _ := gtk.ComboBoxTextNew()
 	setMargins(theme, 0, 0, 40, 0)
 
+	tlsFrame, _ := gtk.FrameNew("TLS")
+	tlsGrid, tlsForm := getTLSForm("Default", false)
+	setMargins(tlsGrid, 10, 10, 10, 10)
+	tlsFrame.Add(tlsGrid)
+
+	proxyFrame, _ := gtk.FrameNew("Proxy")
+	proxyGrid, proxyForm := getProxyForm()
+	setMargins(proxyGrid, 10, 10, 10, 10)
+	proxyFrame.Add(proxyGrid)
+
+	connectionBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 20)
+	connectionBox.Add(tlsFrame)
+	connectionBox.Add(proxyFrame)
+
 	bbox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 20)
 
 	bs, _ := gtk.ButtonNewWithLabel("Save")
@@ -148,6 +162,9 @@ func getSettingsDialog(win *gtk.ApplicationWindow, settings *storage.Settings, b
 				theme.SetActive(k)
 			}
 		}
+
+		tlsForm.SetOptions(settings.TLSOptions())
+		proxyForm.SetOptions(settings.ProxyOptions())
 	}
 
 	applyCurrentValues()
@@ -156,15 +173,21 @@ func getSettingsDialog(win *gtk.ApplicationWindow, settings *storage.Settings, b
 	b.Add(updates)
 	b.Add(ltheme)
 	b.Add(theme)
+	b.Add(connectionBox)
 	b.Add(bbox)
 
 	settingsDiag.Add(b)
 
 	bs.Connect("clicked", func() {
-		newSettings := storage.Settings{
-			storage.SettingTheme:        theme.GetActiveText(),
-			storage.SettingCheckUpdates: updates.GetActive(),
+		// keep the settings that are not edited in this dialog
+		newSettings := storage.Settings{}
+		for k, v := range *settings {
+			newSettings[k] = v
 		}
+		newSettings[storage.SettingTheme] = theme.GetActiveText()
+		newSettings[storage.SettingCheckUpdates] = updates.GetActive()
+		newSettings.SetTLSOptions(tlsForm.Options())
+		newSettings.SetProxyOptions(proxyForm.Options())
 		*settings = newSettings
 		bus.Publish("preferences:updated", newSettings)
 		settingsDiag.Hide()
@@ -0,0 +1,409 @@
+package window
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	evbus "github.com/asaskevich/EventBus"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// unresolvedTag marks unresolved {{name}} references in text views
+const unresolvedTag = "unresolved"
+
+// EnvironmentSwitcher picks the environment whose variables are substituted into requests
+type EnvironmentSwitcher struct {
+	combo    *gtk.ComboBoxText
+	es       *storage.EnvironmentStorage
+	settings *storage.Settings
+	st       *storage.SettingsStorage
+	// loading is set while the combo is refilled
+	loading bool
+}
+
+// Active returns the name of the active environment, empty when none is active
+func (sw *EnvironmentSwitcher) Active() string {
+	name, _ := (*sw.settings)[storage.SettingEnvironment].(string)
+	return name
+}
+
+// Variables returns the variables of the active environment along with the
+// ones extracted from responses
+func (sw *EnvironmentSwitcher) Variables() map[string]string {
+	return storage.MergeVariables(sw.Environment(), sw.Extracted())
+}
+
+// Environment returns the variables of the active environment
+func (sw *EnvironmentSwitcher) Environment() map[string]string {
+	return sw.es.Variables(sw.Active())
+}
+
+// Extracted returns the variables extracted from responses
+func (sw *EnvironmentSwitcher) Extracted() map[string]string {
+	return sw.settings.Variables()
+}
+
+// SetExtracted replaces the variables extracted from responses
+func (sw *EnvironmentSwitcher) SetExtracted(vars map[string]string) {
+	sw.settings.SetVariables(vars)
+	sw.st.UpdateSetting(storage.SettingVariables, vars)
+}
+
+// Reload refills the switcher after the environments were edited
+func (sw *EnvironmentSwitcher) Reload() {
+	sw.loading = true
+	defer func() { sw.loading = false }()
+
+	active := sw.Active()
+	sw.combo.RemoveAll()
+	sw.combo.Append("", "No environment")
+	found := false
+	for _, env := range sw.es.GetAll() {
+		sw.combo.Append(env.Name, env.Name)
+		found = found || env.Name == active
+	}
+	if !found {
+		active = ""
+		sw.setActive(active)
+	}
+	sw.combo.SetActiveID(active)
+}
+
+func (sw *EnvironmentSwitcher) setActive(name string) {
+	(*sw.settings)[storage.SettingEnvironment] = name
+	sw.st.UpdateSetting(storage.SettingEnvironment, name)
+}
+
+func getEnvironmentSwitcher(es *storage.EnvironmentStorage, settings *storage.Settings, st *storage.SettingsStorage, bus evbus.Bus) *EnvironmentSwitcher {
+	combo, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		log.Fatal("Unable to create ComboBoxText:", err)
+	}
+	combo.SetTooltipText("The environment whose variables are substituted into {{name}} references")
+
+	sw := &EnvironmentSwitcher{combo: combo, es: es, settings: settings, st: st}
+	sw.Reload()
+
+	combo.Connect("changed", func() {
+		if sw.loading {
+			return
+		}
+		sw.setActive(combo.GetActiveID())
+		bus.Publish("environments:updated")
+	})
+
+	return sw
+}
+
+// markUnresolvedEntry flags an entry holding {{name}} references that have no value
+func markUnresolvedEntry(entry *gtk.Entry, vars map[string]string) {
+	text, _ := entry.GetText()
+	refs := storage.UnresolvedRefs(text, vars)
+
+	style, err := entry.GetStyleContext()
+	if err != nil {
+		log.Fatal("Unable to get style context:", err)
+	}
+	if len(refs) == 0 {
+		style.RemoveClass("error")
+		entry.SetTooltipText("")
+		return
+	}
+	names := make([]string, len(refs))
+	for i, ref := range refs {
+		names[i] = text[ref[0]:ref[1]]
+	}
+	style.AddClass("error")
+	entry.SetTooltipText("Unresolved variables: " + strings.Join(names, ", "))
+}
+
+// markUnresolvedText highlights the {{name}} references that have no value in a text buffer
+func markUnresolvedText(buff *gtk.TextBuffer, vars map[string]string) {
+	table, err := buff.GetTagTable()
+	if err != nil {
+		log.Fatal("Unable to get tag table:", err)
+	}
+	if _, err := table.Lookup(unresolvedTag); err != nil {
+		buff.CreateTag(unresolvedTag, map[string]interface{}{
+			"foreground": "#cc0000",
+			"weight":     700,
+		})
+	}
+
+	buff.RemoveTagByName(unresolvedTag, buff.GetStartIter(), buff.GetEndIter())
+	text, err := buff.GetText(buff.GetStartIter(), buff.GetEndIter(), true)
+	if err != nil {
+		log.Fatal("Unable to get text:", err)
+	}
+	for _, ref := range storage.UnresolvedRefs(text, vars) {
+		// text iters are positioned by characters, not bytes
+		start := buff.GetIterAtOffset(utf8.RuneCountInString(text[:ref[0]]))
+		end := buff.GetIterAtOffset(utf8.RuneCountInString(text[:ref[1]]))
+		buff.ApplyTagByName(unresolvedTag, start, end)
+	}
+}
+
+// EnvironmentManager edits the environments and their variables
+type EnvironmentManager struct {
+	widget *gtk.Window
+	Show   func()
+}
+
+func getEnvironmentManager(es *storage.EnvironmentStorage, sw *EnvironmentSwitcher, confirmDiag *ConfirmationDialog, errorDiag *ErrorDialog, bus evbus.Bus) *EnvironmentManager {
+	envWin, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	envWin.SetTitle("Environments")
+	envWin.SetPosition(gtk.WIN_POS_MOUSE)
+	envWin.SetDefaultSize(700, 400)
+	envWin.Connect("delete-event", func() bool {
+		envWin.Hide()
+		return true
+	})
+
+	pane, _ := gtk.PanedNew(gtk.ORIENTATION_HORIZONTAL)
+
+	// Environment list
+	envGrid, _ := gtk.GridNew()
+	envGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	envView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	envView.SetVExpand(true)
+	envStore, err := gtk.ListStoreNew(glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+	envView.SetModel(envStore)
+
+	// Variables of the selected environment
+	varsView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	varsView.SetHExpand(true)
+	varsView.SetVExpand(true)
+	varsStore, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+	varsView.SetModel(varsStore)
+
+	// environments holds the listed environments in row order, selected is the edited one
+	var environments []storage.Environment
+	selected := -1
+
+	showVariables := func() {
+		varsStore.Clear()
+		if selected < 0 || selected >= len(environments) {
+			return
+		}
+		vars := environments[selected].Variables
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			AddRowToStore(varsStore, name, vars[name])
+		}
+	}
+
+	refresh := func(selectName string) {
+		envStore.Clear()
+		environments = es.GetAll()
+		selected = -1
+		for idx, env := range environments {
+			iter := envStore.Append()
+			envStore.SetValue(iter, 0, env.Name)
+			if env.Name == selectName {
+				selected = idx
+				selection, _ := envView.GetSelection()
+				selection.SelectIter(iter)
+			}
+		}
+		showVariables()
+		sw.Reload()
+		bus.Publish("environments:updated")
+	}
+
+	saveVariables := func() {
+		if selected < 0 {
+			return
+		}
+		env := environments[selected]
+		env.Variables = map[string]string{}
+		for name, values := range getListStoreContents(varsStore) {
+			env.Variables[name] = values[len(values)-1]
+		}
+		environments[selected] = env
+		es.Put(env)
+		bus.Publish("environments:updated")
+	}
+
+	nameRenderer, _ := gtk.CellRendererTextNew()
+	nameRenderer.SetProperty("editable", true)
+	nameRenderer.Connect("edited", func(crt *gtk.CellRendererText, row string, value string) {
+		idx, err := strconv.Atoi(row)
+		value = strings.TrimSpace(value)
+		if err != nil || idx >= len(environments) || value == "" || value == environments[idx].Name {
+			return
+		}
+		if _, exists := es.Get(value); exists {
+			errorDiag.ShowError("An environment with this name already exists")
+			return
+		}
+		env := environments[idx]
+		es.Remove(env.Name)
+		if sw.Active() == env.Name {
+			sw.setActive(value)
+		}
+		env.Name = value
+		es.Put(env)
+		refresh(value)
+	})
+	nameColumn, _ := gtk.TreeViewColumnNewWithAttribute("Environment", nameRenderer, "text", 0)
+	envView.AppendColumn(nameColumn)
+
+	envSelection, _ := envView.GetSelection()
+	envSelection.Connect("changed", func() {
+		selected = -1
+		envSelection.GetSelectedRows(&envStore.TreeModel).Foreach(func(item interface{}) {
+			if indices := item.(*gtk.TreePath).GetIndices(); len(indices) > 0 {
+				selected = indices[0]
+			}
+		})
+		showVariables()
+	})
+
+	// The variable columns are editable, changes are stored right away
+	for _, id := range []int{ColumnKey, ColumnValue} {
+		title := "Variable"
+		if id == ColumnValue {
+			title = "Value"
+		}
+		cellRenderer, err := gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal("Unable to create text cell renderer:", err)
+		}
+		cellRenderer.SetProperty("editable", true)
+		columnID := id
+		cellRenderer.Connect("edited", func(crt *gtk.CellRendererText, row string, value string) {
+			rowIter, err := varsStore.GetIterFromString(row)
+			if err != nil {
+				log.Fatal("Unable to get row iter:", err)
+			}
+			varsStore.SetValue(rowIter, columnID, value)
+			saveVariables()
+		})
+		column, err := gtk.TreeViewColumnNewWithAttribute(title, cellRenderer, "text", id)
+		if err != nil {
+			log.Fatal("Unable to create cell column:", err)
+		}
+		column.SetResizable(true)
+		varsView.AppendColumn(column)
+	}
+
+	varsScroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	varsScroll.Add(varsView)
+	varsScroll.SetVExpand(true)
+
+	envScroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	envScroll.Add(envView)
+	envScroll.SetVExpand(true)
+
+	envButtons, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	setMargins(envButtons, 5, 5, 5, 5)
+	addEnvBtn, _ := gtk.ButtonNewWithLabel("Add")
+	deleteEnvBtn, _ := gtk.ButtonNewWithLabel("Delete")
+	addEnvBtn.Connect("clicked", func() {
+		name := "New environment"
+		for i := 2; ; i++ {
+			if _, exists := es.Get(name); !exists {
+				break
+			}
+			name = "New environment " + strconv.Itoa(i)
+		}
+		es.Put(storage.Environment{Name: name, Variables: map[string]string{}})
+		refresh(name)
+	})
+	deleteEnvBtn.Connect("clicked", func() {
+		if selected < 0 {
+			return
+		}
+		name := environments[selected].Name
+		confirmDiag.Confirm("This will delete the environment \""+name+"\" and its variables.\nAre you sure that you want to proceed?", func(yes bool) {
+			if yes {
+				es.Remove(name)
+				refresh("")
+			}
+		})
+	})
+	envButtons.PackStart(addEnvBtn, false, false, 0)
+	envButtons.PackStart(deleteEnvBtn, false, false, 0)
+
+	envGrid.Add(envScroll)
+	envGrid.Add(envButtons)
+
+	varsGrid, _ := gtk.GridNew()
+	varsGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	varsButtons, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	setMargins(varsButtons, 5, 5, 5, 5)
+	addVarBtn, _ := gtk.ButtonNewWithLabel("Add a new variable")
+	deleteVarBtn, _ := gtk.ButtonNewWithLabel("Delete selected variable")
+	closeBtn, _ := gtk.ButtonNewWithLabel("Close")
+	addVarBtn.Connect("clicked", func() {
+		if selected < 0 {
+			errorDiag.ShowError("Select or add an environment first")
+			return
+		}
+		AddRowToStore(varsStore, "name", "value")
+		saveVariables()
+	})
+	deleteVarBtn.Connect("clicked", func() {
+		selection, err := varsView.GetSelection()
+		if err != nil {
+			log.Fatal("Unable to get tree view selection:", err)
+		}
+		selection.GetSelectedRows(&varsStore.TreeModel).Foreach(func(item interface{}) {
+			iter, err := varsStore.GetIter(item.(*gtk.TreePath))
+			if err != nil {
+				log.Fatal("Unable to get tree view iter:", err)
+			}
+			varsStore.Remove(iter)
+		})
+		saveVariables()
+	})
+	closeBtn.Connect("clicked", func() {
+		envWin.Hide()
+	})
+	varsButtons.PackEnd(closeBtn, false, false, 3)
+	varsButtons.PackEnd(deleteVarBtn, false, false, 3)
+	varsButtons.PackEnd(addVarBtn, false, false, 3)
+
+	varsGrid.Add(varsScroll)
+	varsGrid.Add(varsButtons)
+
+	envGrid.SetSizeRequest(200, -1)
+	pane.Pack1(envGrid, false, false)
+	pane.Pack2(varsGrid, true, false)
+	envWin.Add(pane)
+
+	showFunc := func() {
+		name := ""
+		if selected >= 0 && selected < len(environments) {
+			name = environments[selected].Name
+		}
+		refresh(name)
+		envWin.ShowAll()
+		envWin.Present()
+	}
+
+	return &EnvironmentManager{envWin, showFunc}
+}
@@ -0,0 +1,357 @@
+package window
+
+import (
+	"sort"
+	"strconv"
+
+	evbus "github.com/asaskevich/EventBus"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// IDs to access the extraction columns by
+const (
+	ExtractionColumnEnabled = iota
+	ExtractionColumnVariable
+	ExtractionColumnSource
+	ExtractionColumnExpression
+)
+
+// IDs to access the variable manager columns by
+const (
+	VariableColumnName = iota
+	VariableColumnValue
+)
+
+// RequestExtractions holds the widgets of the request "Extract" tab
+type RequestExtractions struct {
+	store *gtk.ListStore
+}
+
+// Extractions returns the extractions listed in the tab, including the disabled ones
+func (re *RequestExtractions) Extractions() []storage.Extraction {
+	var extractions []storage.Extraction
+	iter, ok := re.store.GetIterFirst()
+	for ok {
+		enabled, _ := re.store.GetValue(iter, ExtractionColumnEnabled)
+		enabledVal, _ := enabled.GoValue()
+		var columns [3]string
+		for idx, id := range []int{ExtractionColumnVariable, ExtractionColumnSource, ExtractionColumnExpression} {
+			value, _ := re.store.GetValue(iter, id)
+			columns[idx], _ = value.GetString()
+		}
+		extractions = append(extractions, storage.Extraction{
+			Variable:   columns[0],
+			Source:     columns[1],
+			Expression: columns[2],
+			Disabled:   enabledVal != true,
+		})
+		ok = re.store.IterNext(iter)
+	}
+	return extractions
+}
+
+func (re *RequestExtractions) add(e storage.Extraction) {
+	err := re.store.Set(re.store.Append(),
+		[]int{ExtractionColumnEnabled, ExtractionColumnVariable, ExtractionColumnSource, ExtractionColumnExpression},
+		[]interface{}{!e.Disabled, e.Variable, e.Source, e.Expression})
+	if err != nil {
+		log.Fatal("Unable to add row:", err)
+	}
+}
+
+// Load displays the extractions of a stored request
+func (re *RequestExtractions) Load(rq storage.RequestInput) {
+	re.store.Clear()
+	for _, e := range rq.Extractions {
+		re.add(e)
+	}
+}
+
+// Apply stores the extractions of the tab into the request
+func (re *RequestExtractions) Apply(rq *storage.RequestInput) {
+	rq.Extractions = re.Extractions()
+}
+
+// Reset clears the extractions tab
+func (re *RequestExtractions) Reset() {
+	re.store.Clear()
+}
+
+func getRequestExtractions() (*gtk.Grid, *RequestExtractions) {
+	extractionsGrid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create extractionsGrid:", err)
+	}
+	extractionsGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	treeView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	treeView.SetHExpand(true)
+	treeView.SetVExpand(true)
+	treeView.SetTooltipText("Values of the response are stored in variables that later requests reference as {{name}}.\nThe expression is a JSONPath, a header or cookie name, or a regular expression whose first group is extracted from the body.\nEnvironment variables of the same name take precedence.")
+
+	extractionsStore, err := gtk.ListStoreNew(glib.TYPE_BOOLEAN, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+	treeView.SetModel(extractionsStore)
+
+	re := &RequestExtractions{store: extractionsStore}
+
+	toggleRenderer, err := gtk.CellRendererToggleNew()
+	if err != nil {
+		log.Fatal("Unable to create toggle cell renderer:", err)
+	}
+	toggleRenderer.Connect("toggled", func(crt *gtk.CellRendererToggle, row string) {
+		rowIter, err := extractionsStore.GetIterFromString(row)
+		if err != nil {
+			log.Fatal("Unable to get row iter:", err)
+		}
+		enabled, _ := extractionsStore.GetValue(rowIter, ExtractionColumnEnabled)
+		enabledVal, _ := enabled.GoValue()
+		extractionsStore.SetValue(rowIter, ExtractionColumnEnabled, enabledVal != true)
+	})
+	toggleColumn, err := gtk.TreeViewColumnNewWithAttribute("", toggleRenderer, "active", ExtractionColumnEnabled)
+	if err != nil {
+		log.Fatal("Unable to create cell column:", err)
+	}
+	treeView.AppendColumn(toggleColumn)
+
+	columns := []struct {
+		id      int
+		title   string
+		choices []string
+	}{
+		{ExtractionColumnVariable, "Variable", nil},
+		{ExtractionColumnSource, "Source", storage.ExtractionSources},
+		{ExtractionColumnExpression, "Expression", nil},
+	}
+	for _, c := range columns {
+		columnID := c.id
+		edited := func(row string, value string) {
+			rowIter, err := extractionsStore.GetIterFromString(row)
+			if err != nil {
+				log.Fatal("Unable to get row iter:", err)
+			}
+			extractionsStore.SetValue(rowIter, columnID, value)
+		}
+		var renderer gtk.ICellRenderer
+		if c.choices != nil {
+			comboRenderer, err := gtk.CellRendererComboNew()
+			if err != nil {
+				log.Fatal("Unable to create combo cell renderer:", err)
+			}
+			comboRenderer.SetProperty("model", choicesStore(c.choices).Object)
+			comboRenderer.SetProperty("text-column", 0)
+			comboRenderer.SetProperty("has-entry", false)
+			comboRenderer.SetProperty("editable", true)
+			comboRenderer.Connect("edited", func(crc *gtk.CellRendererCombo, row string, value string) {
+				edited(row, value)
+			})
+			renderer = comboRenderer
+		} else {
+			textRenderer, err := gtk.CellRendererTextNew()
+			if err != nil {
+				log.Fatal("Unable to create text cell renderer:", err)
+			}
+			textRenderer.SetProperty("editable", true)
+			textRenderer.Connect("edited", func(crt *gtk.CellRendererText, row string, value string) {
+				edited(row, value)
+			})
+			renderer = textRenderer
+		}
+		column, err := gtk.TreeViewColumnNewWithAttribute(c.title, renderer, "text", c.id)
+		if err != nil {
+			log.Fatal("Unable to create cell column:", err)
+		}
+		column.SetResizable(true)
+		treeView.AppendColumn(column)
+	}
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(treeView)
+	scrolledWindow.SetVExpand(true)
+
+	buttonBox, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	if err != nil {
+		log.Fatal("Unable to create extractions button box:", err)
+	}
+	setMargins(buttonBox, 5, 5, 5, 0)
+
+	deleteExtractionBtn, _ := gtk.ButtonNewWithLabel("Delete selected extraction")
+	addExtractionBtn, _ := gtk.ButtonNewWithLabel("Add a new extraction")
+
+	addExtractionBtn.Connect("clicked", func() {
+		re.add(storage.Extraction{Variable: "token", Source: storage.ExtractJSONPath, Expression: "$.token"})
+	})
+
+	deleteExtractionBtn.Connect("clicked", func() {
+		selection, err := treeView.GetSelection()
+		if err != nil {
+			log.Fatal("Unable to get tree view selection:", err)
+		}
+		selection.GetSelectedRows(&extractionsStore.TreeModel).Foreach(func(item interface{}) {
+			iter, err := extractionsStore.GetIter(item.(*gtk.TreePath))
+			if err != nil {
+				log.Fatal("Unable to get tree view iter:", err)
+			}
+			extractionsStore.Remove(iter)
+		})
+	})
+
+	buttonBox.SetVAlign(gtk.ALIGN_END)
+	buttonBox.PackEnd(deleteExtractionBtn, false, false, 3)
+	buttonBox.PackEnd(addExtractionBtn, false, false, 3)
+
+	sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
+
+	extractionsGrid.Add(scrolledWindow)
+	extractionsGrid.Add(sep)
+	extractionsGrid.Add(buttonBox)
+
+	return extractionsGrid, re
+}
+
+// VariableManager edits the variables extracted from responses
+type VariableManager struct {
+	widget *gtk.Window
+	Show   func()
+}
+
+func getVariableManager(sw *EnvironmentSwitcher, confirmDiag *ConfirmationDialog, bus evbus.Bus) *VariableManager {
+	varWin, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	varWin.SetTitle("Extracted variables")
+	varWin.SetPosition(gtk.WIN_POS_MOUSE)
+	varWin.SetDefaultSize(600, 350)
+	varWin.Connect("delete-event", func() bool {
+		varWin.Hide()
+		return true
+	})
+
+	grid, _ := gtk.GridNew()
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	treeView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	treeView.SetHExpand(true)
+	treeView.SetVExpand(true)
+
+	varStore, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+	treeView.SetModel(varStore)
+
+	// names holds the displayed variable names in row order
+	var names []string
+
+	refresh := func() {
+		varStore.Clear()
+		vars := sw.Extracted()
+		names = names[:0]
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			err := varStore.Set(varStore.Append(),
+				[]int{VariableColumnName, VariableColumnValue},
+				[]interface{}{name, vars[name]})
+			if err != nil {
+				log.Fatal("Unable to add row:", err)
+			}
+		}
+	}
+
+	update := func(vars map[string]string) {
+		sw.SetExtracted(vars)
+		refresh()
+		bus.Publish("environments:updated")
+	}
+
+	for id, title := range []string{"Name", "Value"} {
+		cellRenderer, err := gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal("Unable to create text cell renderer:", err)
+		}
+		if id == VariableColumnValue {
+			cellRenderer.SetProperty("editable", true)
+			cellRenderer.Connect("edited", func(crt *gtk.CellRendererText, row string, value string) {
+				idx, err := strconv.Atoi(row)
+				if err != nil || idx >= len(names) {
+					log.Printf("Invalid variable row edited: %s", row)
+					return
+				}
+				vars := sw.Extracted()
+				vars[names[idx]] = value
+				update(vars)
+			})
+		}
+		column, err := gtk.TreeViewColumnNewWithAttribute(title, cellRenderer, "text", id)
+		if err != nil {
+			log.Fatal("Unable to create cell column:", err)
+		}
+		column.SetResizable(true)
+		treeView.AppendColumn(column)
+	}
+
+	scrolledWindow, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolledWindow.Add(treeView)
+
+	buttonBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	setMargins(buttonBox, 5, 5, 5, 5)
+	deleteBtn, _ := gtk.ButtonNewWithLabel("Delete selected variable")
+	clearBtn, _ := gtk.ButtonNewWithLabel("Delete all variables")
+	closeBtn, _ := gtk.ButtonNewWithLabel("Close")
+
+	deleteBtn.Connect("clicked", func() {
+		selection, err := treeView.GetSelection()
+		if err != nil {
+			log.Fatal("Unable to get tree view selection:", err)
+		}
+		vars := sw.Extracted()
+		selection.GetSelectedRows(&varStore.TreeModel).Foreach(func(item interface{}) {
+			indices := item.(*gtk.TreePath).GetIndices()
+			if len(indices) > 0 && indices[0] < len(names) {
+				delete(vars, names[indices[0]])
+			}
+		})
+		update(vars)
+	})
+	clearBtn.Connect("clicked", func() {
+		confirmDiag.Confirm("This will delete every variable extracted from responses.\nAre you sure that you want to proceed?", func(yes bool) {
+			if yes {
+				update(map[string]string{})
+			}
+		})
+	})
+	closeBtn.Connect("clicked", func() {
+		varWin.Hide()
+	})
+
+	buttonBox.PackEnd(closeBtn, false, false, 3)
+	buttonBox.PackEnd(clearBtn, false, false, 3)
+	buttonBox.PackEnd(deleteBtn, false, false, 3)
+
+	grid.Add(scrolledWindow)
+	grid.Add(buttonBox)
+	varWin.Add(grid)
+
+	showFunc := func() {
+		refresh()
+		varWin.ShowAll()
+		varWin.Present()
+	}
+
+	return &VariableManager{varWin, showFunc}
+}
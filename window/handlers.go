@@ -5,7 +5,7 @@ import (
 	"time"
 
 	"github.com/gotk3/gotk3/gtk"
-	"github.com/lnenad/probster/helpers"
+	"github.com/lnenad/probster/communication"
 	"github.com/lnenad/probster/storage"
 )
 
@@ -45,32 +45,43 @@ func resolveContentType(headers map[string][]string) string {
 	return contentType
 }
 
+func statusText(result storage.RequestResult) string {
+	if !result.Completed() {
+		return fmt.Sprintf("Status Code: %s", result.Outcome)
+	}
+	if len(result.Redirects) > 0 {
+		return fmt.Sprintf("Status Code: %d (after %d redirects)", result.StatusCode, len(result.Redirects))
+	}
+	return fmt.Sprintf("Status Code: %d", result.StatusCode)
+}
+
 func requestCompleted(
 	h *storage.HistoryStorage,
-	settings *storage.Settings,
 	highlightCheckbutton *gtk.CheckButton,
 	historyListbox *gtk.ListBox,
-	responseText *gtk.TextView,
+	responseView *ResponseView,
 	responseStore *gtk.ListStore,
 	responseStatusLbl *gtk.Label,
 	requestDurationLbl *gtk.Label,
+	timingView *TimingView,
+	redirectsView *RedirectsView,
+	certificateView *CertificateView,
+	testsView *TestsView,
 ) func(reqRes storage.RequestResponse) error {
 	return func(reqRes storage.RequestResponse) error {
-		helpers.DisplaySource(
-			resolveContentType(reqRes.Response.Headers),
-			responseText,
-			string(reqRes.Response.ResponseBody),
-			highlightCheckbutton.GetActive(),
-			settings,
-		)
+		responseView.Display(reqRes.Response, highlightCheckbutton.GetActive())
 		responseStore.Clear()
 		for name, values := range reqRes.Response.Headers {
 			for _, value := range values {
 				AddRowToStore(responseStore, name, value)
 			}
 		}
-		responseStatusLbl.SetText(fmt.Sprintf("Status Code: %d", reqRes.Response.StatusCode))
+		responseStatusLbl.SetText(statusText(reqRes.Response))
 		requestDurationLbl.SetText(fmt.Sprintf("Request Duration: %d ms", reqRes.Response.Dur.Milliseconds()))
+		timingView.SetTiming(reqRes.Response.Timing)
+		redirectsView.SetRedirects(reqRes.Response.Redirects)
+		certificateView.SetTLSInfo(reqRes.Response.TLS)
+		testsView.SetTests(reqRes.Response.Tests, reqRes.Response.Extracted)
 		key := []byte(time.Now().Format(storage.HistoryKeyFormat))
 		AddHistoryRow(
 			h,
@@ -89,28 +100,35 @@ func requestCompleted(
 
 func requestLoaded(
 	h *storage.HistoryStorage,
-	settings *storage.Settings,
 	highlightCheckbutton *gtk.CheckButton,
 	pathInput *gtk.Entry,
 	pathMethod *gtk.ComboBoxText,
 	historyListbox *gtk.ListBox,
-	requestText *gtk.TextView,
-	responseText *gtk.TextView,
+	requestBody *RequestBody,
+	requestParams *RequestParams,
+	responseView *ResponseView,
 	requestStore *gtk.ListStore,
 	responseStore *gtk.ListStore,
 	responseStatusLbl *gtk.Label,
 	requestDurationLbl *gtk.Label,
+	timingView *TimingView,
+	redirectsView *RedirectsView,
+	certificateView *CertificateView,
+	testsView *TestsView,
+	requestOptions *RequestOptions,
+	requestAssertions *RequestAssertions,
+	requestExtractions *RequestExtractions,
+	requestScripts *RequestScripts,
+	requestAuth *RequestAuth,
 ) func(reqRes storage.RequestResponse) error {
 	return func(reqRes storage.RequestResponse) error {
-		helpers.DisplaySource(
-			resolveContentType(reqRes.Response.Headers),
-			responseText,
-			string(reqRes.Response.ResponseBody),
-			highlightCheckbutton.GetActive(),
-			settings,
-		)
-		rqTxtBuff, _ := requestText.GetBuffer()
-		rqTxtBuff.SetText(reqRes.Request.Body)
+		responseView.Display(reqRes.Response, highlightCheckbutton.GetActive())
+		requestOptions.Load(reqRes.Request)
+		requestAssertions.Load(reqRes.Request)
+		requestExtractions.Load(reqRes.Request)
+		requestScripts.Load(reqRes.Request)
+		requestAuth.Load(reqRes.Request)
+		requestBody.Load(reqRes.Request)
 		requestStore.Clear()
 		responseStore.Clear()
 		for name, values := range reqRes.Response.Headers {
@@ -123,39 +141,30 @@ func requestLoaded(
 				AddRowToStore(requestStore, name, value)
 			}
 		}
-		responseStatusLbl.SetText(fmt.Sprintf("Status Code: %d", reqRes.Response.StatusCode))
+		responseStatusLbl.SetText(statusText(reqRes.Response))
 		requestDurationLbl.SetText(fmt.Sprintf("Request Duration: %d ms", reqRes.Response.Dur.Milliseconds()))
+		timingView.SetTiming(reqRes.Response.Timing)
+		redirectsView.SetRedirects(reqRes.Response.Redirects)
+		certificateView.SetTLSInfo(reqRes.Response.TLS)
+		testsView.SetTests(reqRes.Response.Tests, reqRes.Response.Extracted)
 
 		pathInput.SetText(reqRes.Request.Path)
+		requestParams.Load(reqRes.Request)
+		setMethod(pathMethod, reqRes.Request.Method)
 		h.SetActiveRecord(&reqRes)
 
-		for idx, v := range supportedMethods {
-			if v == reqRes.Request.Method {
-				pathMethod.SetActive(idx)
-				return nil
-			}
-		}
-		pathMethod.SetActive(0)
-
 		return nil
 	}
 }
 
 func reloadResponseBody(
 	h *storage.HistoryStorage,
-	settings *storage.Settings,
 	highlightCheckbutton *gtk.CheckButton,
-	responseText *gtk.TextView,
+	responseView *ResponseView,
 ) func() error {
 	return func() error {
 		reqRes := h.GetActiveRecord()
-		helpers.DisplaySource(
-			resolveContentType(reqRes.Response.Headers),
-			responseText,
-			string(reqRes.Response.ResponseBody),
-			highlightCheckbutton.GetActive(),
-			settings,
-		)
+		responseView.Display(reqRes.Response, highlightCheckbutton.GetActive())
 
 		return nil
 	}
@@ -163,32 +172,47 @@ func reloadResponseBody(
 
 func requestNew(
 	h *storage.HistoryStorage,
-	settings *storage.Settings,
 	pathInput *gtk.Entry,
 	pathMethod *gtk.ComboBoxText,
 	historyListbox *gtk.ListBox,
-	responseText *gtk.TextView,
+	requestBody *RequestBody,
+	requestParams *RequestParams,
+	responseView *ResponseView,
 	requestStore *gtk.ListStore,
 	responseStore *gtk.ListStore,
 	responseStatusLbl *gtk.Label,
 	requestDurationLbl *gtk.Label,
+	timingView *TimingView,
+	redirectsView *RedirectsView,
+	certificateView *CertificateView,
+	testsView *TestsView,
+	requestOptions *RequestOptions,
+	requestAssertions *RequestAssertions,
+	requestExtractions *RequestExtractions,
+	requestScripts *RequestScripts,
+	requestAuth *RequestAuth,
 ) func() error {
 	return func() error {
-		helpers.DisplaySource(
-			"",
-			responseText,
-			"",
-			false,
-			settings,
-		)
+		responseView.Clear()
 		requestStore.Clear()
 		responseStore.Clear()
 		historyListbox.UnselectAll()
 		responseStatusLbl.SetText("Status Code: ---")
 		requestDurationLbl.SetText("Request Duration: --- ms")
+		timingView.SetTiming(communication.Timing{})
+		redirectsView.SetRedirects(nil)
+		certificateView.SetTLSInfo(nil)
+		testsView.SetTests(nil, nil)
+		requestOptions.Reset()
+		requestAssertions.Reset()
+		requestExtractions.Reset()
+		requestScripts.Reset()
+		requestAuth.Reset()
 
 		pathInput.SetText("https://")
+		requestParams.Reset()
 		pathMethod.SetActive(0)
+		requestBody.Reset(pathMethod.GetActiveText())
 		h.SetActiveRecord(nil)
 
 		return nil
@@ -0,0 +1,255 @@
+package window
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// IDs to access the HAR export columns by
+const (
+	ExportColumnSelected = iota
+	ExportColumnMethod
+	ExportColumnStatus
+	ExportColumnPath
+	ExportColumnKey
+)
+
+// HARTransfer imports HAR archives into the history and exports history entries as HAR
+type HARTransfer struct {
+	h              *storage.HistoryStorage
+	historyListbox *gtk.ListBox
+	win            *gtk.ApplicationWindow
+	version        string
+	errorDiag      *ErrorDialog
+	nDiag          *NotificationDialog
+}
+
+func getHARTransfer(
+	h *storage.HistoryStorage,
+	historyListbox *gtk.ListBox,
+	win *gtk.ApplicationWindow,
+	version string,
+	errorDiag *ErrorDialog,
+	nDiag *NotificationDialog,
+) *HARTransfer {
+	return &HARTransfer{h, historyListbox, win, version, errorDiag, nDiag}
+}
+
+// harFilter limits a file chooser to HAR archives
+func harFilter() *gtk.FileFilter {
+	filter, err := gtk.FileFilterNew()
+	if err != nil {
+		log.Fatal("Unable to create FileFilter:", err)
+	}
+	filter.SetName("HTTP Archive (*.har)")
+	filter.AddPattern("*.har")
+	return filter
+}
+
+// Import asks for a HAR archive and adds its entries to the history
+func (ht *HARTransfer) Import() {
+	dialog, err := gtk.FileChooserDialogNewWith2Buttons(
+		"Import HAR archive",
+		ht.win,
+		gtk.FILE_CHOOSER_ACTION_OPEN,
+		"Cancel", gtk.RESPONSE_CANCEL,
+		"Import", gtk.RESPONSE_ACCEPT,
+	)
+	if err != nil {
+		log.Fatal("Unable to create FileChooserDialog:", err)
+	}
+	dialog.AddFilter(harFilter())
+	response := dialog.Run()
+	filename := dialog.GetFilename()
+	dialog.Destroy()
+	if response != gtk.RESPONSE_ACCEPT {
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		ht.errorDiag.ShowError(fmt.Sprintf("Unable to read the HAR archive.\n%s", err))
+		return
+	}
+	entries, err := storage.ImportHAR(data)
+	if err != nil {
+		ht.errorDiag.ShowError(fmt.Sprintf("Unable to import the HAR archive.\n%s", err))
+		return
+	}
+
+	// rows are prepended, adding the oldest first keeps the newest on top
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	for _, entry := range ht.h.Import(entries) {
+		AddHistoryRow(ht.h, ht.historyListbox, entry.Key, entry.RR)
+	}
+	ht.nDiag.ShowNotification(fmt.Sprintf("Imported %d requests into the history", len(entries)))
+}
+
+// Export lets the user pick history entries and writes them to a HAR archive
+func (ht *HARTransfer) Export() {
+	history := ht.h.GetAllRequests()
+	if len(history) == 0 {
+		ht.errorDiag.ShowError("There are no requests in the history to export")
+		return
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Key > history[j].Key
+	})
+
+	dialog, err := gtk.DialogNewWithButtons("Export HAR archive", ht.win, gtk.DIALOG_MODAL,
+		[]interface{}{"Cancel", gtk.RESPONSE_CANCEL},
+		[]interface{}{"Export", gtk.RESPONSE_ACCEPT},
+	)
+	if err != nil {
+		log.Fatal("Unable to create dialog:", err)
+	}
+	dialog.SetDefaultSize(600, 400)
+
+	store, err := gtk.ListStoreNew(glib.TYPE_BOOLEAN, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+
+	// the entry selected in the sidebar is preselected, all of them when there is none
+	selectedKey := ""
+	if row := ht.historyListbox.GetSelectedRow(); row != nil {
+		selectedKey, _ = row.GetName()
+	}
+	for _, entry := range history {
+		status := statusText(entry.RR.Response)
+		err := store.Set(store.Append(),
+			[]int{ExportColumnSelected, ExportColumnMethod, ExportColumnStatus, ExportColumnPath, ExportColumnKey},
+			[]interface{}{selectedKey == "" || selectedKey == entry.Key, entry.RR.Request.Method, status, entry.RR.Request.Path, entry.Key})
+		if err != nil {
+			log.Fatal("Unable to add row:", err)
+		}
+	}
+
+	treeView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	treeView.SetModel(store)
+
+	toggleRenderer, err := gtk.CellRendererToggleNew()
+	if err != nil {
+		log.Fatal("Unable to create toggle cell renderer:", err)
+	}
+	toggleRenderer.Connect("toggled", func(crt *gtk.CellRendererToggle, row string) {
+		rowIter, err := store.GetIterFromString(row)
+		if err != nil {
+			log.Fatal("Unable to get row iter:", err)
+		}
+		selected, _ := store.GetValue(rowIter, ExportColumnSelected)
+		selectedVal, _ := selected.GoValue()
+		store.SetValue(rowIter, ExportColumnSelected, selectedVal != true)
+	})
+	toggleColumn, err := gtk.TreeViewColumnNewWithAttribute("", toggleRenderer, "active", ExportColumnSelected)
+	if err != nil {
+		log.Fatal("Unable to create cell column:", err)
+	}
+	treeView.AppendColumn(toggleColumn)
+
+	for id, title := range []string{ExportColumnMethod: "Method", ExportColumnStatus: "Status", ExportColumnPath: "Path"} {
+		if title == "" {
+			continue
+		}
+		cellRenderer, err := gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal("Unable to create text cell renderer:", err)
+		}
+		column, err := gtk.TreeViewColumnNewWithAttribute(title, cellRenderer, "text", id)
+		if err != nil {
+			log.Fatal("Unable to create cell column:", err)
+		}
+		column.SetSortColumnID(id)
+		treeView.AppendColumn(column)
+	}
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.SetVExpand(true)
+	scrolledWindow.SetHExpand(true)
+	scrolledWindow.Add(treeView)
+
+	setAll := func(selected bool) {
+		for iter, ok := store.GetIterFirst(); ok; ok = store.IterNext(iter) {
+			store.SetValue(iter, ExportColumnSelected, selected)
+		}
+	}
+	selectAllBtn, _ := gtk.ButtonNewWithLabel("Select all")
+	selectAllBtn.Connect("clicked", func() { setAll(true) })
+	selectNoneBtn, _ := gtk.ButtonNewWithLabel("Select none")
+	selectNoneBtn.Connect("clicked", func() { setAll(false) })
+
+	buttonBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	setMargins(buttonBox, 5, 0, 5, 0)
+	buttonBox.Add(selectAllBtn)
+	buttonBox.Add(selectNoneBtn)
+
+	content, _ := dialog.GetContentArea()
+	content.Add(scrolledWindow)
+	content.Add(buttonBox)
+	dialog.ShowAll()
+
+	response := dialog.Run()
+	var keys []string
+	for iter, ok := store.GetIterFirst(); ok; ok = store.IterNext(iter) {
+		selected, _ := store.GetValue(iter, ExportColumnSelected)
+		if selectedVal, _ := selected.GoValue(); selectedVal == true {
+			key, _ := store.GetValue(iter, ExportColumnKey)
+			keyVal, _ := key.GetString()
+			keys = append(keys, keyVal)
+		}
+	}
+	dialog.Destroy()
+	if response != gtk.RESPONSE_ACCEPT {
+		return
+	}
+	if len(keys) == 0 {
+		ht.errorDiag.ShowError("Select the requests to export")
+		return
+	}
+
+	// entries are exported oldest first, the order they were sent in
+	sort.Strings(keys)
+	var entries []storage.HistoryEntry
+	for _, key := range keys {
+		entries = append(entries, ht.h.GetEntry(key))
+	}
+	data, err := storage.ExportHAR(entries, ht.version)
+	if err != nil {
+		ht.errorDiag.ShowError(fmt.Sprintf("Unable to export the requests.\n%s", err))
+		return
+	}
+
+	saveDialog, err := gtk.FileChooserDialogNewWith2Buttons(
+		"Save HAR archive",
+		ht.win,
+		gtk.FILE_CHOOSER_ACTION_SAVE,
+		"Cancel", gtk.RESPONSE_CANCEL,
+		"Save", gtk.RESPONSE_ACCEPT,
+	)
+	if err != nil {
+		log.Fatal("Unable to create FileChooserDialog:", err)
+	}
+	saveDialog.AddFilter(harFilter())
+	saveDialog.SetCurrentName("probster.har")
+	saveDialog.SetDoOverwriteConfirmation(true)
+	if saveDialog.Run() == gtk.RESPONSE_ACCEPT {
+		if err := ioutil.WriteFile(saveDialog.GetFilename(), data, 0644); err != nil {
+			ht.errorDiag.ShowError(fmt.Sprintf("Unable to save the HAR archive.\n%s", err))
+		}
+	}
+	saveDialog.Destroy()
+}
@@ -0,0 +1,210 @@
+package window
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	evbus "github.com/asaskevich/EventBus"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// CollectionImporter imports the collections and environments exported by other tools
+type CollectionImporter struct {
+	cols            *storage.CollectionStorage
+	es              *storage.EnvironmentStorage
+	collectionsView *CollectionsView
+	envSwitcher     *EnvironmentSwitcher
+	win             *gtk.ApplicationWindow
+	errorDiag       *ErrorDialog
+	bus             evbus.Bus
+}
+
+func getCollectionImporter(
+	cols *storage.CollectionStorage,
+	es *storage.EnvironmentStorage,
+	collectionsView *CollectionsView,
+	envSwitcher *EnvironmentSwitcher,
+	win *gtk.ApplicationWindow,
+	errorDiag *ErrorDialog,
+	bus evbus.Bus,
+) *CollectionImporter {
+	return &CollectionImporter{cols, es, collectionsView, envSwitcher, win, errorDiag, bus}
+}
+
+// Import asks for an exported file, stores what it holds and shows a summary
+func (ci *CollectionImporter) Import() {
+	dialog, err := gtk.FileChooserDialogNewWith2Buttons(
+		"Import Postman or Insomnia export",
+		ci.win,
+		gtk.FILE_CHOOSER_ACTION_OPEN,
+		"Cancel", gtk.RESPONSE_CANCEL,
+		"Import", gtk.RESPONSE_ACCEPT,
+	)
+	if err != nil {
+		log.Fatal("Unable to create FileChooserDialog:", err)
+	}
+	filter, err := gtk.FileFilterNew()
+	if err != nil {
+		log.Fatal("Unable to create FileFilter:", err)
+	}
+	filter.SetName("JSON exports (*.json)")
+	filter.AddPattern("*.json")
+	dialog.AddFilter(filter)
+	response := dialog.Run()
+	filename := dialog.GetFilename()
+	dialog.Destroy()
+	if response != gtk.RESPONSE_ACCEPT {
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		ci.errorDiag.ShowError(fmt.Sprintf("Unable to read the file.\n%s", err))
+		return
+	}
+	result, err := storage.ImportCollections(data)
+	if err != nil {
+		ci.errorDiag.ShowError(fmt.Sprintf("Unable to import the file.\n%s", err))
+		return
+	}
+
+	if len(result.Collections) > 0 {
+		ci.cols.SaveAll(append(ci.cols.GetAll(), result.Collections...))
+		ci.collectionsView.Reload()
+	}
+	var envNames []string
+	if len(result.Environments) > 0 {
+		for _, env := range result.Environments {
+			env.Name = ci.environmentName(env.Name)
+			ci.es.Put(env)
+			envNames = append(envNames, env.Name)
+		}
+		ci.envSwitcher.Reload()
+		ci.bus.Publish("environments:updated")
+	}
+
+	ci.showSummary(result, envNames)
+}
+
+// environmentName makes the name of an imported environment unique
+func (ci *CollectionImporter) environmentName(name string) string {
+	unique := name
+	for idx := 2; ; idx++ {
+		if _, exists := ci.es.Get(unique); !exists {
+			return unique
+		}
+		unique = fmt.Sprintf("%s (%d)", name, idx)
+	}
+}
+
+// showSummary lists what was imported and the features that were skipped
+func (ci *CollectionImporter) showSummary(result storage.ImportResult, envNames []string) {
+	dialog, err := gtk.DialogNewWithButtons("Import summary", ci.win, gtk.DIALOG_MODAL,
+		[]interface{}{"Close", gtk.RESPONSE_CLOSE},
+	)
+	if err != nil {
+		log.Fatal("Unable to create dialog:", err)
+	}
+	dialog.SetDefaultSize(500, -1)
+
+	grid, _ := gtk.GridNew()
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+	grid.SetRowSpacing(5)
+	setMargins(grid, 10, 10, 10, 10)
+
+	summary := fmt.Sprintf("Imported %d requests in %d collections from the %s", result.Requests(), len(result.Collections), result.Format)
+	if len(envNames) > 0 {
+		summary += fmt.Sprintf("\nEnvironments: %s", strings.Join(envNames, ", "))
+	}
+	summaryLbl, _ := gtk.LabelNew(summary)
+	summaryLbl.SetHAlign(gtk.ALIGN_START)
+	summaryLbl.SetLineWrap(true)
+	grid.Add(summaryLbl)
+
+	if len(result.Unsupported) > 0 {
+		unsupportedLbl, _ := gtk.LabelNew("These features are not supported and were skipped:")
+		unsupportedLbl.SetHAlign(gtk.ALIGN_START)
+		grid.Add(unsupportedLbl)
+
+		textView, err := gtk.TextViewNew()
+		if err != nil {
+			log.Fatal("Unable to create TextView:", err)
+		}
+		textView.SetEditable(false)
+		textView.SetWrapMode(gtk.WRAP_WORD)
+		buff, _ := textView.GetBuffer()
+		buff.SetText(strings.Join(result.Unsupported, "\n"))
+
+		scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+		if err != nil {
+			log.Fatal("Unable to create ScrolledWindow:", err)
+		}
+		scrolledWindow.SetSizeRequest(-1, 200)
+		scrolledWindow.SetVExpand(true)
+		scrolledWindow.SetHExpand(true)
+		scrolledWindow.Add(textView)
+		grid.Add(scrolledWindow)
+	}
+
+	content, _ := dialog.GetContentArea()
+	content.Add(grid)
+	dialog.ShowAll()
+	dialog.Run()
+	dialog.Destroy()
+}
+
+// importOpenAPI asks for an OpenAPI document and adds a history entry for each of its operations
+func importOpenAPI(
+	h *storage.HistoryStorage,
+	historyListbox *gtk.ListBox,
+	win *gtk.ApplicationWindow,
+	errorDiag *ErrorDialog,
+) func() {
+	return func() {
+		dialog, err := gtk.FileChooserDialogNewWith2Buttons(
+			"Import OpenAPI specification",
+			win,
+			gtk.FILE_CHOOSER_ACTION_OPEN,
+			"Cancel", gtk.RESPONSE_CANCEL,
+			"Import", gtk.RESPONSE_ACCEPT,
+		)
+		if err != nil {
+			log.Fatal("Unable to create FileChooserDialog:", err)
+		}
+		filter, err := gtk.FileFilterNew()
+		if err != nil {
+			log.Fatal("Unable to create FileFilter:", err)
+		}
+		filter.SetName("OpenAPI and Swagger (*.json, *.yaml, *.yml)")
+		filter.AddPattern("*.json")
+		filter.AddPattern("*.yaml")
+		filter.AddPattern("*.yml")
+		dialog.AddFilter(filter)
+		response := dialog.Run()
+		filename := dialog.GetFilename()
+		dialog.Destroy()
+		if response != gtk.RESPONSE_ACCEPT {
+			return
+		}
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			errorDiag.ShowError(fmt.Sprintf("Unable to read the specification.\n%s", err))
+			return
+		}
+		spec, err := storage.ImportOpenAPI(data)
+		if err != nil {
+			errorDiag.ShowError(fmt.Sprintf("Unable to import the specification.\n%s", err))
+			return
+		}
+
+		// rows are prepended, the last operation goes first so the list keeps the document order
+		for _, entry := range h.AddUnsent(spec.Title, spec.Requests) {
+			AddHistoryRow(h, historyListbox, entry.Key, entry.RR)
+		}
+		historyListbox.InvalidateHeaders()
+	}
+}
@@ -2,9 +2,7 @@ package window
 
 import (
 	"fmt"
-	"net/http"
 	"regexp"
-	"strings"
 
 	log "github.com/sirupsen/logrus"
 
@@ -16,7 +14,10 @@ import (
 	"github.com/lnenad/probster/update"
 )
 
-var headerRegex = regexp.MustCompile(`^[\w-]+$`)
+var headerRegex = regexp.MustCompile(`^([\w-]|\{\{\s*[\w.\-]+\s*\}\})+$`)
+
+// methodRegex matches the token characters allowed in a request method
+var methodRegex = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
 
 // IDs to access the tree view columns by
 const (
@@ -31,6 +32,8 @@ var supportedMethods = []string{
 	"PATCH",
 	"DELETE",
 	"HEAD",
+	"OPTIONS",
+	"TRACE",
 }
 
 // BuildWindow is used to build main app window
@@ -40,6 +43,9 @@ func BuildWindow(
 	application *gtk.Application,
 	h *storage.HistoryStorage,
 	st *storage.SettingsStorage,
+	cs *storage.CookieStorage,
+	es *storage.EnvironmentStorage,
+	cols *storage.CollectionStorage,
 	bus evbus.Bus,
 ) *gtk.ApplicationWindow {
 	win, err := gtk.ApplicationWindowNew(application)
@@ -54,6 +60,10 @@ func BuildWindow(
 	nDiag := getNotificationDialog(win)
 	aDiag := getAboutDialog(currentVersion)
 	sDiag := getSettingsDialog(win, settings, bus)
+	cookieManager := getCookieManager(cs, confirmDiag)
+	envSwitcher := getEnvironmentSwitcher(es, settings, st, bus)
+	envManager := getEnvironmentManager(es, envSwitcher, confirmDiag, errorDiag, bus)
+	varManager := getVariableManager(envSwitcher, confirmDiag, bus)
 
 	if should, ok := (*settings)[storage.SettingCheckUpdates].(bool); ok && should {
 		if shouldUpdate, newVersion := update.CheckVersion(currentVersion); shouldUpdate {
@@ -64,7 +74,7 @@ func BuildWindow(
 	}
 
 	// Register header bar with menu
-	registerMenu(win, bus, confirmDiag, aDiag)
+	registerMenu(win, bus, confirmDiag, aDiag, envSwitcher)
 
 	//
 	// START DRAWING
@@ -77,7 +87,8 @@ func BuildWindow(
 	}
 	mainGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
 
-	requestBodyWindow, requestText := getScrollableTextView("Request")
+	requestBodyGrid, requestBody := getRequestBody(envSwitcher)
+	requestParamsGrid, requestParams := getRequestParams()
 
 	requestFrame, err := gtk.FrameNew("Request")
 	if err != nil {
@@ -85,16 +96,12 @@ func BuildWindow(
 	}
 	setMargins(requestFrame, 10, 10, 10, 10)
 
-	responseBodyWindow, responseText := getScrollableTextView("Response")
-
 	responseFrame, err := gtk.FrameNew("Response")
 	if err != nil {
 		log.Fatal("Unable to create Frame:", err)
 	}
 	setMargins(responseFrame, 10, 10, 10, 10)
 
-	responseText.SetEditable(false)
-
 	pane, err := gtk.PanedNew(gtk.ORIENTATION_VERTICAL)
 	if err != nil {
 		log.Fatal("Unable to create paned:", err)
@@ -104,6 +111,10 @@ func BuildWindow(
 	if err != nil {
 		log.Fatal("Unable to create notebook:", err)
 	}
+	requestNotebookParamsLbl, err := gtk.LabelNew("Params")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
 	requestNotebookBodyLbl, err := gtk.LabelNew("Body")
 	if err != nil {
 		log.Fatal("Unable to create button:", err)
@@ -112,6 +123,36 @@ func BuildWindow(
 	if err != nil {
 		log.Fatal("Unable to create button:", err)
 	}
+	requestNotebookAuthLbl, err := gtk.LabelNew("Auth")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	requestNotebookOptionsLbl, err := gtk.LabelNew("Options")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	requestNotebookTLSLbl, err := gtk.LabelNew("TLS")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	requestNotebookAssertionsLbl, err := gtk.LabelNew("Assertions")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	requestNotebookExtractLbl, err := gtk.LabelNew("Extract")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	requestAssertionsGrid, requestAssertions := getRequestAssertions()
+	requestExtractionsGrid, requestExtractions := getRequestExtractions()
+	requestNotebookScriptsLbl, err := gtk.LabelNew("Scripts")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	requestScriptsPane, requestScripts := getRequestScripts()
+	tokens := getTokenCache(errorDiag)
+	requestAuthGrid, requestAuth := getRequestAuth(tokens, settings, cs, envSwitcher, errorDiag)
+	requestOptionsGrid, requestTLSGrid, requestOptions := getRequestOptions()
 	requestHeaders, err := gtk.GridNew()
 	if err != nil {
 		log.Fatal("Unable to create requestHeaders grid:", err)
@@ -166,8 +207,15 @@ func BuildWindow(
 	requestHeaders.Add(sep)
 	requestHeaders.Add(requestHeadersButtonBox)
 
-	requestNotebook.AppendPage(requestBodyWindow, requestNotebookBodyLbl)
+	requestNotebook.AppendPage(requestParamsGrid, requestNotebookParamsLbl)
+	requestNotebook.AppendPage(requestBodyGrid, requestNotebookBodyLbl)
 	requestNotebook.AppendPage(requestHeaders, requestNotebookHeadersLbl)
+	requestNotebook.AppendPage(requestAuthGrid, requestNotebookAuthLbl)
+	requestNotebook.AppendPage(requestOptionsGrid, requestNotebookOptionsLbl)
+	requestNotebook.AppendPage(requestTLSGrid, requestNotebookTLSLbl)
+	requestNotebook.AppendPage(requestAssertionsGrid, requestNotebookAssertionsLbl)
+	requestNotebook.AppendPage(requestExtractionsGrid, requestNotebookExtractLbl)
+	requestNotebook.AppendPage(requestScriptsPane, requestNotebookScriptsLbl)
 	requestFrame.Add(requestNotebook)
 	requestNotebook.SetVExpand(true)
 	requestFrame.SetVExpand(true)
@@ -186,80 +234,151 @@ func BuildWindow(
 	if err != nil {
 		log.Fatal("Unable to create button:", err)
 	}
+	responseNotebookTimingLbl, err := gtk.LabelNew("Timing")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	responseNotebookRedirectsLbl, err := gtk.LabelNew("Redirects")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	responseNotebookCertificateLbl, err := gtk.LabelNew("Certificate")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	responseNotebookTestsLbl, err := gtk.LabelNew("Tests")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	responseNotebookConsoleLbl, err := gtk.LabelNew("Console")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
 	responseHeaders, err := gtk.GridNew()
 	if err != nil {
 		log.Fatal("Unable to create responseHeaders grid:", err)
 	}
 
+	responseBodyGrid, responseView := getResponseView(settings, errorDiag)
 	responseTreeScroll, _, responseStore := setupTreeView(errorDiag, false)
 	responseHeaders.Add(responseTreeScroll)
 	responseTreeScroll.SetVExpand(true)
 
-	responseNotebook.AppendPage(responseBodyWindow, responseNotebookBodyLbl)
+	responseNotebook.AppendPage(responseBodyGrid, responseNotebookBodyLbl)
 	responseNotebook.AppendPage(responseHeaders, responseNotebookHeadersLbl)
 
+	timingWindow, timingView := getTimingView()
+	responseNotebook.AppendPage(timingWindow, responseNotebookTimingLbl)
+
+	redirectsWindow, redirectsView := getRedirectsView()
+	responseNotebook.AppendPage(redirectsWindow, responseNotebookRedirectsLbl)
+
+	certificateGrid, certificateView := getCertificateView()
+	responseNotebook.AppendPage(certificateGrid, responseNotebookCertificateLbl)
+
+	testsWindow, testsView := getTestsView(responseNotebookTestsLbl)
+	responseNotebook.AppendPage(testsWindow, responseNotebookTestsLbl)
+
+	consoleWindow, consoleView := getConsoleView()
+	responseNotebook.AppendPage(consoleWindow, responseNotebookConsoleLbl)
+
 	responseFrame.Add(responseNotebook)
 	pane.Add2(responseFrame)
 
-	actionBar, highlightCheckbutton, responseStatusLbl, requestDurationLbl := GetActionbar()
+	actionBar, highlightCheckbutton, charsetCombo, responseStatusLbl, requestDurationLbl := GetActionbar()
+	charsetCombo.Connect("changed", func() {
+		responseView.SetCharset(charsetCombo.GetActiveID())
+	})
 
-	sideBar, historyListbox := GetSidebar(h, bus)
+	collectionsGrid, collectionsView := getCollectionsView(cols, win, confirmDiag, bus)
+	sideBar, historyListbox := GetSidebar(h, collectionsGrid, bus)
 
 	reloadResponseBodyFn := reloadResponseBody(
 		h,
-		settings,
 		highlightCheckbutton,
-		responseText,
+		responseView,
 	)
 
 	highlightCheckbutton.Connect("clicked", reloadResponseBodyFn)
 
 	pathHeader, pathInput, pathMethod := getPathGrid(
 		h,
+		cs,
+		tokens,
+		settings,
 		bus,
 		errorDiag,
-		requestText,
+		requestBody,
+		requestParams,
+		requestAssertions,
+		requestExtractions,
+		requestScripts,
+		requestAuth,
+		consoleView,
 		requestStore,
-		requestBodyWindow,
+		requestOptions,
+		envSwitcher,
 	)
 
 	bus.Subscribe("request:completed", requestCompleted(
 		h,
-		settings,
 		highlightCheckbutton,
 		historyListbox,
-		responseText,
+		responseView,
 		responseStore,
 		responseStatusLbl,
 		requestDurationLbl,
+		timingView,
+		redirectsView,
+		certificateView,
+		testsView,
 	))
 
 	bus.Subscribe("request:loaded", requestLoaded(
 		h,
-		settings,
 		highlightCheckbutton,
 		pathInput,
 		pathMethod,
 		historyListbox,
-		requestText,
-		responseText,
+		requestBody,
+		requestParams,
+		responseView,
 		requestStore,
 		responseStore,
 		responseStatusLbl,
 		requestDurationLbl,
+		timingView,
+		redirectsView,
+		certificateView,
+		testsView,
+		requestOptions,
+		requestAssertions,
+		requestExtractions,
+		requestScripts,
+		requestAuth,
 	))
 
 	bus.Subscribe("request:new", requestNew(
 		h,
-		settings,
 		pathInput,
 		pathMethod,
 		historyListbox,
-		responseText,
+		requestBody,
+		requestParams,
+		responseView,
 		requestStore,
 		responseStore,
 		responseStatusLbl,
 		requestDurationLbl,
+		timingView,
+		redirectsView,
+		certificateView,
+		testsView,
+		requestOptions,
+		requestAssertions,
+		requestExtractions,
+		requestScripts,
+		requestAuth,
 	))
 
 	bus.Subscribe("history:clear", clearHistory(
@@ -276,6 +395,40 @@ func BuildWindow(
 		sDiag.Show()
 	})
 
+	harTransfer := getHARTransfer(h, historyListbox, win, currentVersion.String(), errorDiag, nDiag)
+	bus.Subscribe("har:import", harTransfer.Import)
+	bus.Subscribe("har:export", harTransfer.Export)
+
+	collectionImporter := getCollectionImporter(cols, es, collectionsView, envSwitcher, win, errorDiag, bus)
+	bus.Subscribe("collections:import", collectionImporter.Import)
+	bus.Subscribe("openapi:import", importOpenAPI(h, historyListbox, win, errorDiag))
+	bus.Subscribe("curl:paste", promptCurl(win, bus, errorDiag))
+	bus.Subscribe("history:copy", func(format, key string) {
+		copySnippet(format, h.GetEntry(key).RR.Request, envSwitcher.Variables(), errorDiag)
+	})
+
+	bus.Subscribe("cookies:show", func() {
+		cookieManager.Show()
+	})
+
+	bus.Subscribe("collections:save", func(request storage.RequestInput) {
+		collectionsView.PromptSave(request)
+	})
+
+	bus.Subscribe("environments:show", func() {
+		envManager.Show()
+	})
+
+	bus.Subscribe("variables:show", func() {
+		varManager.Show()
+	})
+
+	bus.Subscribe("environments:updated", func() {
+		vars := envSwitcher.Variables()
+		markUnresolvedEntry(pathInput, vars)
+		requestBody.MarkUnresolved(vars)
+	})
+
 	mainGrid.Add(pathHeader)
 	mainGrid.Add(pane)
 
@@ -292,12 +445,7 @@ func BuildWindow(
 
 	win.ShowAll()
 
-	currMethod := pathMethod.GetActiveText()
-	if currMethod == "GET" || currMethod == "HEAD" {
-		requestBodyWindow.SetVisible(false)
-	} else {
-		requestBodyWindow.SetVisible(true)
-	}
+	requestBody.MethodChanged(pathMethod.GetActiveText())
 
 	return win
 }
@@ -310,18 +458,6 @@ func setMargins(iw gtk.IWidget, top, right, bot, left int) {
 	w.SetMarginStart(left)
 }
 
-func resolveResponseHeaders(headers http.Header) map[string][]string {
-	responseHeaders := make(map[string][]string)
-	for n, vals := range headers {
-		name := strings.ToLower(n)
-		responseHeaders[name] = []string{}
-		for _, v := range vals {
-			responseHeaders[name] = append(responseHeaders[name], v)
-		}
-	}
-	return responseHeaders
-}
-
 func getListStoreContents(store *gtk.ListStore) map[string][]string {
 	result := make(map[string][]string)
 	iter, err := store.GetIterFirst()
@@ -448,6 +584,49 @@ func setupTreeView(errorDiag *ErrorDialog, editable bool) (*gtk.ScrolledWindow,
 	return scrolledWindow, treeView, listStore
 }
 
+// Creates a read only tree view and the tree store that holds its nested key/value rows
+func setupTreeStoreView(keyTitle, valueTitle string) (*gtk.ScrolledWindow, *gtk.TreeView, *gtk.TreeStore) {
+	treeView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+
+	treeView.SetHExpand(true)
+	treeView.SetVExpand(true)
+
+	treeStore, err := gtk.TreeStoreNew(glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create tree store:", err)
+	}
+	treeView.SetModel(treeStore)
+
+	treeView.AppendColumn(createColumn(nil, keyTitle, ColumnKey, false, nil))
+	treeView.AppendColumn(createColumn(nil, valueTitle, ColumnValue, false, nil))
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(treeView)
+
+	return scrolledWindow, treeView, treeStore
+}
+
+// AddRowToTreeStore appends a key/value row under parent, a nil parent adds a top level row
+func AddRowToTreeStore(treeStore *gtk.TreeStore, parent *gtk.TreeIter, key, value string) *gtk.TreeIter {
+	iter := treeStore.Append(parent)
+
+	err := treeStore.SetValue(iter, ColumnKey, key)
+	if err == nil {
+		err = treeStore.SetValue(iter, ColumnValue, value)
+	}
+	if err != nil {
+		log.Fatal("Unable to add row:", err)
+	}
+
+	return iter
+}
+
 // AddRowToStore append a row to the list store for the tree view
 func AddRowToStore(listStore *gtk.ListStore, key, value string) {
 	// Get an iterator for a new row at the end of the list store
@@ -8,9 +8,10 @@ import (
 	evbus "github.com/asaskevich/EventBus"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/storage"
 )
 
-func registerMenu(win *gtk.ApplicationWindow, bus evbus.Bus, confirmDiag *ConfirmationDialog, aboutDiag *AboutDialog) {
+func registerMenu(win *gtk.ApplicationWindow, bus evbus.Bus, confirmDiag *ConfirmationDialog, aboutDiag *AboutDialog, envSwitcher *EnvironmentSwitcher) {
 	// Create a header bar
 	header, err := gtk.HeaderBarNew()
 	if err != nil {
@@ -35,7 +36,24 @@ func registerMenu(win *gtk.ApplicationWindow, bus evbus.Bus, confirmDiag *Confir
 	// Actions with the prefix 'win' reference actions on the current window (specific to ApplicationWindow)
 	// Other prefixes can be added to widgets via InsertActionGroup
 	menu.Append("New Request", "win.new-request")
+	menu.Append("Save to collection", "win.save-request")
+	menu.Append("Paste curl command", "win.paste-curl")
+	copyMenu := glib.MenuNew()
+	if copyMenu == nil {
+		log.Fatal("Could not create menu (nil)")
+	}
+	for _, f := range storage.SnippetFormats {
+		copyMenu.Append(f.Name, "win.copy-as-"+f.ID)
+	}
+	menu.AppendSubmenu("Copy as", &copyMenu.MenuModel)
 	menu.Append("Clear history", "win.clear-history")
+	menu.Append("Import HAR", "win.import-har")
+	menu.Append("Export HAR", "win.export-har")
+	menu.Append("Import collection", "win.import-collection")
+	menu.Append("Import OpenAPI", "win.import-openapi")
+	menu.Append("Cookies", "win.cookies")
+	menu.Append("Environments", "win.environments")
+	menu.Append("Extracted variables", "win.variables")
 	menu.Append("Preferences", "win.preferences")
 	menu.Append("About", "win.about")
 	menu.Append("Quit", "app.quit")
@@ -51,6 +69,34 @@ func registerMenu(win *gtk.ApplicationWindow, bus evbus.Bus, confirmDiag *Confir
 	})
 	win.AddAction(aClearHistory)
 
+	// Create the action "win.import-har"
+	aImportHAR := glib.SimpleActionNew("import-har", nil)
+	aImportHAR.Connect("activate", func() {
+		bus.Publish("har:import")
+	})
+	win.AddAction(aImportHAR)
+
+	// Create the action "win.export-har"
+	aExportHAR := glib.SimpleActionNew("export-har", nil)
+	aExportHAR.Connect("activate", func() {
+		bus.Publish("har:export")
+	})
+	win.AddAction(aExportHAR)
+
+	// Create the action "win.import-collection"
+	aImportCollection := glib.SimpleActionNew("import-collection", nil)
+	aImportCollection.Connect("activate", func() {
+		bus.Publish("collections:import")
+	})
+	win.AddAction(aImportCollection)
+
+	// Create the action "win.import-openapi"
+	aImportOpenAPI := glib.SimpleActionNew("import-openapi", nil)
+	aImportOpenAPI.Connect("activate", func() {
+		bus.Publish("openapi:import")
+	})
+	win.AddAction(aImportOpenAPI)
+
 	// Create the action "win.close"
 	aPreferences := glib.SimpleActionNew("preferences", nil)
 	aPreferences.Connect("activate", func() {
@@ -58,6 +104,27 @@ func registerMenu(win *gtk.ApplicationWindow, bus evbus.Bus, confirmDiag *Confir
 	})
 	win.AddAction(aPreferences)
 
+	// Create the action "win.cookies"
+	aCookies := glib.SimpleActionNew("cookies", nil)
+	aCookies.Connect("activate", func() {
+		bus.Publish("cookies:show")
+	})
+	win.AddAction(aCookies)
+
+	// Create the action "win.environments"
+	aEnvironments := glib.SimpleActionNew("environments", nil)
+	aEnvironments.Connect("activate", func() {
+		bus.Publish("environments:show")
+	})
+	win.AddAction(aEnvironments)
+
+	// Create the action "win.variables"
+	aVariables := glib.SimpleActionNew("variables", nil)
+	aVariables.Connect("activate", func() {
+		bus.Publish("variables:show")
+	})
+	win.AddAction(aVariables)
+
 	// Create the action "win.close"
 	aAbout := glib.SimpleActionNew("about", nil)
 	aAbout.Connect("activate", func() {
@@ -72,10 +139,35 @@ func registerMenu(win *gtk.ApplicationWindow, bus evbus.Bus, confirmDiag *Confir
 	})
 	win.AddAction(aNewRequest)
 
+	// Create the action "win.paste-curl"
+	aPasteCurl := glib.SimpleActionNew("paste-curl", nil)
+	aPasteCurl.Connect("activate", func() {
+		bus.Publish("curl:paste")
+	})
+	win.AddAction(aPasteCurl)
+
+	// Create the action "win.save-request"
+	aSaveRequest := glib.SimpleActionNew("save-request", nil)
+	aSaveRequest.Connect("activate", func() {
+		bus.Publish("request:save")
+	})
+	win.AddAction(aSaveRequest)
+
+	// Create the "win.copy-as-<format>" actions, they copy the request in the editor
+	for _, f := range storage.SnippetFormats {
+		format := f.ID
+		aCopyAs := glib.SimpleActionNew("copy-as-"+format, nil)
+		aCopyAs.Connect("activate", func() {
+			bus.Publish("request:copy", format)
+		})
+		win.AddAction(aCopyAs)
+	}
+
 	mbtn.SetMenuModel(&menu.MenuModel)
 
 	// add the menu button to the header
 	header.PackStart(mbtn)
+	header.PackEnd(envSwitcher.combo)
 	win.SetTitlebar(header)
 
 }
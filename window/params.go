@@ -0,0 +1,238 @@
+package window
+
+import (
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/communication"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// IDs to access the query param columns by
+const (
+	ParamColumnEnabled = iota
+	ParamColumnKey
+	ParamColumnValue
+)
+
+// RequestParams holds the widgets of the request "Params" tab, it is kept in sync with the URL entry
+type RequestParams struct {
+	store     *gtk.ListStore
+	pathInput *gtk.Entry
+	// syncing is set while one side is being updated from the other
+	syncing bool
+}
+
+// Params returns the params listed in the tab, including the disabled ones
+func (rp *RequestParams) Params() []communication.QueryParam {
+	var params []communication.QueryParam
+	iter, ok := rp.store.GetIterFirst()
+	for ok {
+		enabled, _ := rp.store.GetValue(iter, ParamColumnEnabled)
+		key, _ := rp.store.GetValue(iter, ParamColumnKey)
+		value, _ := rp.store.GetValue(iter, ParamColumnValue)
+		enabledVal, _ := enabled.GoValue()
+		keyStr, _ := key.GetString()
+		valueStr, _ := value.GetString()
+		params = append(params, communication.QueryParam{
+			Key:      keyStr,
+			Value:    valueStr,
+			Disabled: enabledVal != true,
+		})
+		ok = rp.store.IterNext(iter)
+	}
+	return params
+}
+
+// SetParams replaces the params listed in the tab
+func (rp *RequestParams) SetParams(params []communication.QueryParam) {
+	rp.store.Clear()
+	for _, p := range params {
+		err := rp.store.Set(rp.store.Append(),
+			[]int{ParamColumnEnabled, ParamColumnKey, ParamColumnValue},
+			[]interface{}{!p.Disabled, p.Key, p.Value})
+		if err != nil {
+			log.Fatal("Unable to add row:", err)
+		}
+	}
+}
+
+// Load displays the params of a stored request, the enabled ones are taken from its URL
+func (rp *RequestParams) Load(rq storage.RequestInput) {
+	params := communication.ParseQuery(rq.Path)
+	for _, p := range rq.Params {
+		if p.Disabled {
+			params = append(params, p)
+		}
+	}
+	rp.SetParams(params)
+}
+
+// Apply stores the params currently set in the params tab into the request
+func (rp *RequestParams) Apply(rq *storage.RequestInput) {
+	rq.Params = rp.Params()
+}
+
+// Reset clears the params tab
+func (rp *RequestParams) Reset() {
+	rp.store.Clear()
+}
+
+// pathChanged reparses the params after the URL has been edited, disabled params are kept
+func (rp *RequestParams) pathChanged() {
+	if rp.syncing {
+		return
+	}
+	rp.syncing = true
+	defer func() { rp.syncing = false }()
+
+	path, _ := rp.pathInput.GetText()
+	params := communication.ParseQuery(path)
+	for _, p := range rp.Params() {
+		if p.Disabled {
+			params = append(params, p)
+		}
+	}
+	rp.SetParams(params)
+}
+
+// paramsChanged rewrites the query of the URL after the params have been edited
+func (rp *RequestParams) paramsChanged() {
+	if rp.syncing || rp.pathInput == nil {
+		return
+	}
+	rp.syncing = true
+	defer func() { rp.syncing = false }()
+
+	path, _ := rp.pathInput.GetText()
+	rp.pathInput.SetText(communication.WithQuery(path, rp.Params()))
+}
+
+// attach connects the params tab to the URL entry
+func (rp *RequestParams) attach(pathInput *gtk.Entry) {
+	rp.pathInput = pathInput
+	pathInput.Connect("changed", rp.pathChanged)
+}
+
+func getRequestParams() (*gtk.Grid, *RequestParams) {
+	paramsGrid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create paramsGrid:", err)
+	}
+	paramsGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	treeView, err := gtk.TreeViewNew()
+	if err != nil {
+		log.Fatal("Unable to create tree view:", err)
+	}
+	treeView.SetHExpand(true)
+	treeView.SetVExpand(true)
+
+	paramsStore, err := gtk.ListStoreNew(glib.TYPE_BOOLEAN, glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal("Unable to create list store:", err)
+	}
+	treeView.SetModel(paramsStore)
+
+	rp := &RequestParams{store: paramsStore}
+
+	toggleRenderer, err := gtk.CellRendererToggleNew()
+	if err != nil {
+		log.Fatal("Unable to create toggle cell renderer:", err)
+	}
+	toggleRenderer.Connect("toggled", func(crt *gtk.CellRendererToggle, row string) {
+		rowIter, err := paramsStore.GetIterFromString(row)
+		if err != nil {
+			log.Fatal("Unable to get row iter:", err)
+		}
+		enabled, _ := paramsStore.GetValue(rowIter, ParamColumnEnabled)
+		enabledVal, _ := enabled.GoValue()
+		paramsStore.SetValue(rowIter, ParamColumnEnabled, enabledVal != true)
+		rp.paramsChanged()
+	})
+	toggleColumn, err := gtk.TreeViewColumnNewWithAttribute("", toggleRenderer, "active", ParamColumnEnabled)
+	if err != nil {
+		log.Fatal("Unable to create cell column:", err)
+	}
+	treeView.AppendColumn(toggleColumn)
+
+	for _, id := range []int{ParamColumnKey, ParamColumnValue} {
+		title := "Param Name"
+		if id == ParamColumnValue {
+			title = "Param Value"
+		}
+		cellRenderer, err := gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal("Unable to create text cell renderer:", err)
+		}
+		cellRenderer.SetProperty("editable", true)
+		columnID := id
+		cellRenderer.Connect("edited", func(crt *gtk.CellRendererText, row string, value string) {
+			rowIter, err := paramsStore.GetIterFromString(row)
+			if err != nil {
+				log.Fatal("Unable to get row iter:", err)
+			}
+			paramsStore.SetValue(rowIter, columnID, value)
+			rp.paramsChanged()
+		})
+		column, err := gtk.TreeViewColumnNewWithAttribute(title, cellRenderer, "text", id)
+		if err != nil {
+			log.Fatal("Unable to create cell column:", err)
+		}
+		column.SetResizable(true)
+		treeView.AppendColumn(column)
+	}
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(treeView)
+	scrolledWindow.SetVExpand(true)
+
+	buttonBox, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	if err != nil {
+		log.Fatal("Unable to create params button box:", err)
+	}
+	setMargins(buttonBox, 5, 5, 5, 0)
+
+	deleteParamBtn, _ := gtk.ButtonNewWithLabel("Delete selected param")
+	addParamBtn, _ := gtk.ButtonNewWithLabel("Add a new param")
+
+	addParamBtn.Connect("clicked", func() {
+		err := paramsStore.Set(paramsStore.Append(),
+			[]int{ParamColumnEnabled, ParamColumnKey, ParamColumnValue},
+			[]interface{}{true, "name", "value"})
+		if err != nil {
+			log.Fatal("Unable to add row:", err)
+		}
+		rp.paramsChanged()
+	})
+
+	deleteParamBtn.Connect("clicked", func() {
+		selection, err := treeView.GetSelection()
+		if err != nil {
+			log.Fatal("Unable to get tree view selection:", err)
+		}
+		selection.GetSelectedRows(&paramsStore.TreeModel).Foreach(func(item interface{}) {
+			iter, err := paramsStore.GetIter(item.(*gtk.TreePath))
+			if err != nil {
+				log.Fatal("Unable to get tree view iter:", err)
+			}
+			paramsStore.Remove(iter)
+		})
+		rp.paramsChanged()
+	})
+
+	buttonBox.SetVAlign(gtk.ALIGN_END)
+	buttonBox.PackEnd(deleteParamBtn, false, false, 3)
+	buttonBox.PackEnd(addParamBtn, false, false, 3)
+
+	sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
+
+	paramsGrid.Add(scrolledWindow)
+	paramsGrid.Add(sep)
+	paramsGrid.Add(buttonBox)
+
+	return paramsGrid, rp
+}
@@ -1,25 +1,39 @@
 package window
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	evbus "github.com/asaskevich/EventBus"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 	"github.com/lnenad/probster/communication"
+	"github.com/lnenad/probster/scripting"
 	"github.com/lnenad/probster/storage"
 	log "github.com/sirupsen/logrus"
 )
 
 func getPathGrid(
 	h *storage.HistoryStorage,
+	cs *storage.CookieStorage,
+	tokens *communication.TokenCache,
+	settings *storage.Settings,
 	bus evbus.Bus,
 	errorDiag *ErrorDialog,
-	requestText *gtk.TextView,
+	requestBody *RequestBody,
+	requestParams *RequestParams,
+	requestAssertions *RequestAssertions,
+	requestExtractions *RequestExtractions,
+	requestScripts *RequestScripts,
+	requestAuth *RequestAuth,
+	console *ConsoleView,
 	requestStore *gtk.ListStore,
-	requestBodyWindow *gtk.ScrolledWindow,
+	requestOptions *RequestOptions,
+	environments *EnvironmentSwitcher,
 ) (*gtk.Grid, *gtk.Entry, *gtk.ComboBoxText) {
 	pathGrid, err := gtk.GridNew()
 	if err != nil {
@@ -27,7 +41,7 @@ func getPathGrid(
 	}
 	setMargins(pathGrid, 10, 10, 10, 10)
 
-	pathMethod, err := gtk.ComboBoxTextNew()
+	pathMethod, err := gtk.ComboBoxTextNewWithEntry()
 	if err != nil {
 		log.Fatal("Unable to create pathMethod:", err)
 	}
@@ -35,7 +49,10 @@ func getPathGrid(
 		pathMethod.AppendText(method)
 	}
 	pathMethod.SetActive(0)
-	pathMethod.SetTooltipText("Select the request method")
+	pathMethod.SetTooltipText("Select the request method or type a custom one")
+	if methodEntry, err := pathMethod.GetEntry(); err == nil {
+		methodEntry.SetWidthChars(10)
+	}
 
 	pathInput, err := gtk.EntryNew()
 	if err != nil {
@@ -43,82 +60,285 @@ func getPathGrid(
 	}
 	pathInput.SetPlaceholderText("https://google.com")
 	pathInput.SetHExpand(true)
+	requestParams.attach(pathInput)
+	attachCurlPaste(pathInput, bus, errorDiag)
+	pathInput.Connect("changed", func() {
+		markUnresolvedEntry(pathInput, environments.Variables())
+	})
 
 	sendRequestBtn, err := gtk.ButtonNewWithLabel("SEND")
 	if err != nil {
 		log.Fatal("Unable to create Button:", err)
 	}
 
+	saveRequestBtn, err := gtk.ButtonNewFromIconName("document-save-symbolic", gtk.ICON_SIZE_BUTTON)
+	if err != nil {
+		log.Fatal("Unable to create Button:", err)
+	}
+	saveRequestBtn.SetTooltipText("Save to collection")
+
 	pathMethod.Connect("changed", func() {
-		method := pathMethod.GetActiveText()
-		if method == "GET" || method == "HEAD" {
-			requestBodyWindow.SetVisible(false)
-		} else {
-			requestBodyWindow.SetVisible(true)
-		}
+		requestBody.MethodChanged(getMethod(pathMethod))
 	})
 
-	performRequest := func() {
+	// cancelRequest is set while a request is in flight
+	var cancelRequest context.CancelFunc
+
+	requestFinished := func() {
+		cancelRequest = nil
+		sendRequestBtn.SetLabel("SEND")
+		sendRequestBtn.SetTooltipText("")
+		// the request may have obtained or refreshed a token
+		requestAuth.updateTokenStatus()
+	}
+
+	// buildRequest collects the request from the request tabs, ok is false when it is invalid
+	buildRequest := func() (storage.RequestInput, bool) {
 		path, _ := pathInput.GetText()
-		method := pathMethod.GetActiveText()
+		method := getMethod(pathMethod)
+		if !methodRegex.MatchString(method) {
+			errorDiag.ShowError(fmt.Sprintf("Invalid request method provided: %q", method))
+			return storage.RequestInput{}, false
+		}
+		if method == http.MethodConnect {
+			errorDiag.ShowError("The CONNECT method is not supported")
+			return storage.RequestInput{}, false
+		}
 
-		res, err := url.Parse(path)
-		if err != nil {
-			errorDiag.ShowError(fmt.Sprintf("Invalid URL provided. %s", err))
+		request := storage.RequestInput{
+			Path:    path,
+			Method:  method,
+			Headers: getListStoreContents(requestStore),
+		}
+		requestBody.Apply(&request)
+		requestParams.Apply(&request)
+		requestOptions.Apply(&request)
+		requestAssertions.Apply(&request)
+		requestExtractions.Apply(&request)
+		requestScripts.Apply(&request)
+		requestAuth.Apply(&request)
+		if request.Proxy.Mode == communication.ProxyCustom && request.Proxy.URL == "" {
+			errorDiag.ShowError("Please provide the custom proxy URL in the request options")
+			return storage.RequestInput{}, false
+		}
+		return request, true
+	}
+
+	performRequest := func() {
+		if cancelRequest != nil {
 			return
 		}
-		if res.Scheme != "http" && res.Scheme != "https" {
-			errorDiag.ShowError(fmt.Sprintf("Invalid URL Scheme provided.\nPlease start the url with http:// or https://"))
+
+		request, ok := buildRequest()
+		if !ok {
 			return
 		}
-		sendRequestBtn.SetSensitive(false)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelRequest = cancel
+		sendRequestBtn.SetLabel("CANCEL")
+		sendRequestBtn.SetTooltipText("Cancel the request in progress")
+		console.Clear()
+
+		// the scripts run in the goroutine with copies of the variables, their
+		// changes are applied on the main thread
+		environment := environments.Environment()
+		extractedVars := make(map[string]string)
+		for name, value := range environments.Extracted() {
+			extractedVars[name] = value
+		}
+		sendSettings := make(storage.Settings, len(*settings))
+		for name, value := range *settings {
+			sendSettings[name] = value
+		}
 
 		go func() {
-			requestBody, err := getText(requestText)
-			if err != nil {
-				log.Fatal("Unable to retrieve text from requestTextView:", err)
+			defer cancel()
+
+			fail := func(message string) {
+				glib.IdleAdd(func() {
+					requestFinished()
+					errorDiag.ShowError(message)
+				})
+			}
+			cancelled := func(err error, start time.Time) {
+				glib.IdleAdd(func(reqRes storage.RequestResponse) {
+					bus.Publish("request:completed", reqRes)
+					requestFinished()
+				}, storage.RequestResponse{
+					Request: request,
+					Response: storage.RequestResult{
+						Dur:     time.Now().Sub(start),
+						Outcome: communication.Outcome(err),
+					},
+				})
 			}
-			requestHeaders := getListStoreContents(requestStore)
+
+			// the pre-request script changes the request that is sent, history keeps the one from the editor
+			sent := request
 			start := time.Now()
-			response, responseBody, err := communication.Send(path, method, requestHeaders, requestBody)
+			pre, preErr := scripting.PreRequest(&sent, storage.MergeVariables(environment, extractedVars))
+			glib.IdleAdd(func() {
+				console.Append("pre-request", pre.Console)
+				if stored := environments.Extracted(); pre.ApplyVariables(stored) {
+					environments.SetExtracted(stored)
+					bus.Publish("environments:updated")
+				}
+				if preErr != nil {
+					console.Error(preErr)
+				}
+			})
+			if preErr != nil {
+				fail(fmt.Sprintf("Error in the pre-request script.\n%s", preErr))
+				return
+			}
+			if ctx.Err() != nil {
+				cancelled(communication.ErrCancelled, start)
+				return
+			}
+
+			// history keeps the {{name}} references, the resolved request is sent
+			pre.ApplyVariables(extractedVars)
+			vars := storage.MergeVariables(environment, extractedVars)
+			resolved, unresolved := sent.Resolve(vars)
+			if len(unresolved) > 0 {
+				fail(fmt.Sprintf("Unresolved variables: {{%s}}\nDefine them in the active environment or extract them from a response", strings.Join(unresolved, "}}, {{")))
+				return
+			}
+
+			res, err := url.Parse(resolved.Path)
 			if err != nil {
-				glib.IdleAdd(func() {
-					errorDiag.ShowError(fmt.Sprintf("Error while performing request.\n%s", err))
-					return
-				})
+				fail(fmt.Sprintf("Invalid URL provided. %s", err))
+				return
+			}
+			if res.Scheme != "http" && res.Scheme != "https" {
+				fail(fmt.Sprintf("Invalid URL Scheme provided.\nPlease start the url with http:// or https://"))
 				return
 			}
-			log.Printf("Response: %#v\n", response)
+
+			options := resolved.SendOptions(sendSettings, cs, tokens)
+			headers, body, err := resolved.Outgoing()
+			if err != nil {
+				fail(fmt.Sprintf("Unable to build the request body.\n%s", err))
+				return
+			}
+
+			start = time.Now()
+			result, err := communication.Send(
+				ctx,
+				resolved.Path,
+				resolved.Method,
+				headers,
+				body,
+				options,
+			)
+			if err == communication.ErrCancelled || err == communication.ErrTimedOut {
+				cancelled(err, start)
+				return
+			}
+			if err != nil {
+				fail(fmt.Sprintf("Error while performing request.\n%s", err))
+				return
+			}
+			log.Printf("Response: %#v\n", result.Response)
+
+			response := storage.RequestResult{
+				StatusCode:   result.Response.StatusCode,
+				Headers:      storage.ResponseHeaders(result.Response.Header),
+				ResponseBody: result.Body,
+				EncodedSize:  result.EncodedSize,
+				Dur:          result.Timing.Total,
+				Timing:       result.Timing,
+				Redirects:    result.Redirects,
+				TLS:          result.TLS,
+				Outcome:      communication.OutcomeCompleted,
+			}
+			response.Tests = storage.RunAssertions(resolved.Assertions, response)
+			response.Extracted = storage.RunExtractions(resolved.Extractions, response)
+			extracted := storage.ExtractedVariables(response.Extracted)
+			post, postErr := scripting.PostResponse(resolved, &response, storage.MergeVariables(vars, extracted))
 
 			glib.IdleAdd(func(reqRes storage.RequestResponse) {
+				stored := environments.Extracted()
+				for name, value := range extracted {
+					stored[name] = value
+				}
+				if post.ApplyVariables(stored) || len(extracted) > 0 {
+					environments.SetExtracted(stored)
+					bus.Publish("environments:updated")
+				}
+				console.Append("post-response", post.Console)
 				bus.Publish("request:completed", reqRes)
-				sendRequestBtn.SetSensitive(true)
+				requestFinished()
+				if postErr != nil {
+					console.Error(postErr)
+					errorDiag.ShowError(fmt.Sprintf("Error in the post-response script.\n%s", postErr))
+				}
 			}, storage.RequestResponse{
-				Request: storage.RequestInput{
-					Body:    requestBody,
-					Path:    path,
-					Method:  method,
-					Headers: requestHeaders,
-				},
-				Response: storage.RequestResult{
-					StatusCode:   response.StatusCode,
-					Headers:      resolveResponseHeaders(response.Header),
-					ResponseBody: responseBody,
-					Dur:          time.Now().Sub(start),
-				},
+				Request:  request,
+				Response: response,
 			})
 		}()
 	}
 
 	pathInput.Connect("activate", performRequest)
 
-	sendRequestBtn.Connect("clicked", performRequest)
+	saveRequest := func() {
+		if request, ok := buildRequest(); ok {
+			bus.Publish("collections:save", request)
+		}
+	}
+	saveRequestBtn.Connect("clicked", saveRequest)
+	bus.Subscribe("request:save", saveRequest)
+
+	bus.Subscribe("request:copy", func(format string) {
+		if request, ok := buildRequest(); ok {
+			copySnippet(format, request, environments.Variables(), errorDiag)
+		}
+	})
+
+	sendRequestBtn.Connect("clicked", func() {
+		if cancelRequest != nil {
+			cancelRequest()
+			return
+		}
+		performRequest()
+	})
 
 	// Assemble the window
 	pathGrid.Add(pathMethod)
 	pathGrid.Add(pathInput)
 	pathGrid.Add(sendRequestBtn)
+	pathGrid.Add(saveRequestBtn)
 
 	pathGrid.SetHExpand(true)
 	return pathGrid, pathInput, pathMethod
 }
+
+// getMethod returns the chosen or typed request method. Methods are case
+// sensitive, only the supported ones are uppercased.
+func getMethod(pathMethod *gtk.ComboBoxText) string {
+	method := strings.TrimSpace(pathMethod.GetActiveText())
+	for _, v := range supportedMethods {
+		if strings.EqualFold(v, method) {
+			return v
+		}
+	}
+	return method
+}
+
+// setMethod selects the method in the combo, custom methods are typed into its entry
+func setMethod(pathMethod *gtk.ComboBoxText, method string) {
+	for idx, v := range supportedMethods {
+		if v == method {
+			pathMethod.SetActive(idx)
+			return
+		}
+	}
+	pathMethod.SetActive(-1)
+	methodEntry, err := pathMethod.GetEntry()
+	if err != nil {
+		log.Fatal("Unable to get method entry:", err)
+	}
+	methodEntry.SetText(method)
+}
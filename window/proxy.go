@@ -0,0 +1,76 @@
+package window
+
+import (
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/communication"
+	log "github.com/sirupsen/logrus"
+)
+
+const proxyBypassSeparator = ","
+
+// ProxyForm edits the global communication.ProxyOptions in the preferences dialog
+type ProxyForm struct {
+	http     *gtk.Entry
+	https    *gtk.Entry
+	socks5   *gtk.Entry
+	username *gtk.Entry
+	password *gtk.Entry
+	bypass   *gtk.Entry
+}
+
+// Options returns the proxy options currently set in the form
+func (pf *ProxyForm) Options() communication.ProxyOptions {
+	var bypass []string
+	text, _ := pf.bypass.GetText()
+	for _, entry := range strings.Split(text, proxyBypassSeparator) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			bypass = append(bypass, entry)
+		}
+	}
+
+	return communication.ProxyOptions{
+		HTTP:     entryText(pf.http),
+		HTTPS:    entryText(pf.https),
+		SOCKS5:   entryText(pf.socks5),
+		Username: entryText(pf.username),
+		Password: entryText(pf.password),
+		Bypass:   bypass,
+	}
+}
+
+// SetOptions displays the provided proxy options in the form
+func (pf *ProxyForm) SetOptions(p communication.ProxyOptions) {
+	pf.http.SetText(p.HTTP)
+	pf.https.SetText(p.HTTPS)
+	pf.socks5.SetText(p.SOCKS5)
+	pf.username.SetText(p.Username)
+	pf.password.SetText(p.Password)
+	pf.bypass.SetText(strings.Join(p.Bypass, proxyBypassSeparator+" "))
+}
+
+func getProxyForm() (*gtk.Grid, *ProxyForm) {
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create proxy grid:", err)
+	}
+	grid.SetRowSpacing(5)
+	grid.SetColumnSpacing(10)
+
+	hint, _ := gtk.LabelNew("Leave the proxies empty to use the system environment configuration")
+	hint.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(hint, 0, 0, 2, 1)
+
+	pf := &ProxyForm{
+		http:     attachEntry(grid, "HTTP proxy", "proxy.local:8080", 1),
+		https:    attachEntry(grid, "HTTPS proxy", "proxy.local:8080", 2),
+		socks5:   attachEntry(grid, "SOCKS5 proxy", "socks.local:1080", 3),
+		username: attachEntry(grid, "Username", "", 4),
+		password: attachEntry(grid, "Password", "", 5),
+		bypass:   attachEntry(grid, "Bypass proxy for", "localhost, .internal.com, 10.0.0.0/8", 6),
+	}
+	pf.password.SetVisibility(false)
+
+	return grid, pf
+}
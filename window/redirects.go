@@ -0,0 +1,40 @@
+package window
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/communication"
+)
+
+// RedirectsView lists the redirect chain of a response, each hop expands to its headers
+type RedirectsView struct {
+	treeView *gtk.TreeView
+	store    *gtk.TreeStore
+}
+
+// SetRedirects replaces the displayed redirect chain
+func (rv *RedirectsView) SetRedirects(hops []communication.Hop) {
+	rv.store.Clear()
+	for idx, hop := range hops {
+		iter := AddRowToTreeStore(rv.store, nil, fmt.Sprintf("#%d  %d  %s %s", idx+1, hop.StatusCode, hop.Method, hop.URL), formatDuration(hop.Dur))
+
+		names := make([]string, 0, len(hop.Headers))
+		for name := range hop.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range hop.Headers[name] {
+				AddRowToTreeStore(rv.store, iter, name, value)
+			}
+		}
+	}
+}
+
+func getRedirectsView() (*gtk.ScrolledWindow, *RedirectsView) {
+	scrolledWindow, treeView, treeStore := setupTreeStoreView("Redirect", "Duration / Header Value")
+
+	return scrolledWindow, &RedirectsView{treeView, treeStore}
+}
@@ -0,0 +1,277 @@
+package window
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+	"github.com/lnenad/probster/communication"
+	"github.com/lnenad/probster/helpers"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Response body views
+const (
+	ViewAuto  = "auto"
+	ViewText  = "text"
+	ViewHex   = "hex"
+	ViewImage = "image"
+)
+
+// maxHexDump limits how much of a body is shown in the hex view
+const maxHexDump = 64 * 1024
+
+// maxHighlight is the largest body that still gets syntax highlighted
+const maxHighlight = 1024 * 1024
+
+// ResponseView shows the response body as text, a hex dump or an image preview
+type ResponseView struct {
+	settings  *storage.Settings
+	stack     *gtk.Stack
+	mode      *gtk.ComboBoxText
+	info      *gtk.Label
+	text      *gtk.TextView
+	hex       *gtk.TextView
+	image     *gtk.Image
+	saveBtn   *gtk.Button
+	result    storage.RequestResult
+	body      []byte
+	highlight bool
+	// charset overrides the charset sent by the server when set
+	charset string
+}
+
+// Display shows a response body, the view is picked from its content unless one was chosen
+func (rv *ResponseView) Display(result storage.RequestResult, highlight bool) {
+	rv.result = result
+	rv.body = result.ResponseBody
+	rv.highlight = highlight
+	rv.refresh()
+}
+
+// Clear empties the response body views
+func (rv *ResponseView) Clear() {
+	rv.Display(storage.RequestResult{}, false)
+}
+
+// SetCharset decodes the text view with the given charset, an empty one uses the charset of the response
+func (rv *ResponseView) SetCharset(charset string) {
+	rv.charset = charset
+	rv.refresh()
+}
+
+func (rv *ResponseView) refresh() {
+	contentType := resolveContentType(rv.result.Headers)
+	binary := communication.IsBinary(contentType, rv.body)
+	rv.saveBtn.SetSensitive(len(rv.body) > 0)
+
+	view := rv.mode.GetActiveID()
+	if view == ViewAuto || view == "" {
+		view = ViewText
+		if communication.IsImage(contentType) {
+			view = ViewImage
+		} else if binary {
+			view = ViewHex
+		}
+	}
+
+	info := sizeText(rv.result)
+	if binary && info != "" {
+		info = "Binary content, " + info
+	}
+
+	switch view {
+	case ViewImage:
+		if err := rv.showImage(); err != nil {
+			info = fmt.Sprintf("Unable to preview the image: %s", err)
+			view = ViewHex
+			rv.showHex()
+		}
+	case ViewHex:
+		rv.showHex()
+	default:
+		charset := rv.charset
+		if charset == "" {
+			charset = communication.Charset(contentType)
+		}
+		text, err := communication.DecodeText(rv.body, charset)
+		if err != nil {
+			info = fmt.Sprintf("%s, unable to decode as %s: %s", info, charset, err)
+		}
+		highlight := rv.highlight && !binary && len(rv.body) <= maxHighlight
+		helpers.DisplaySource(contentType, rv.text, text, highlight, rv.settings)
+	}
+	if view == ViewHex && len(rv.body) > maxHexDump {
+		info = fmt.Sprintf("%s, showing the first %s", info, formatSize(maxHexDump))
+	}
+
+	rv.info.SetText(info)
+	rv.stack.SetVisibleChildName(view)
+}
+
+// sizeText describes the size of a response body, with the size it was transferred in when it was compressed
+func sizeText(result storage.RequestResult) string {
+	size := int64(len(result.ResponseBody))
+	if size == 0 {
+		return ""
+	}
+	if result.EncodedSize == 0 || result.EncodedSize == size {
+		return formatSize(size)
+	}
+	encoding := "encoded"
+	if val, ok := result.Headers["content-encoding"]; ok {
+		encoding = val[0]
+	}
+	return fmt.Sprintf("%s (%s %s)", formatSize(size), formatSize(result.EncodedSize), encoding)
+}
+
+// formatSize formats a byte count using binary units
+func formatSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+}
+
+func (rv *ResponseView) showHex() {
+	body := rv.body
+	if len(body) > maxHexDump {
+		body = body[:maxHexDump]
+	}
+	buff, err := rv.hex.GetBuffer()
+	if err != nil {
+		log.Fatal("Unable to retrieve TextBuffer:", err)
+	}
+	buff.SetText(hex.Dump(body))
+}
+
+func (rv *ResponseView) showImage() error {
+	loader, err := gdk.PixbufLoaderNew()
+	if err != nil {
+		return err
+	}
+	pixbuf, err := loader.WriteAndReturnPixbuf(rv.body)
+	if err != nil {
+		return err
+	}
+	rv.image.SetFromPixbuf(pixbuf)
+	return nil
+}
+
+// save writes the raw response body to a file chosen by the user
+func (rv *ResponseView) save(errorDiag *ErrorDialog) {
+	dialog, err := gtk.FileChooserDialogNewWith2Buttons(
+		"Save response body",
+		nil,
+		gtk.FILE_CHOOSER_ACTION_SAVE,
+		"Cancel", gtk.RESPONSE_CANCEL,
+		"Save", gtk.RESPONSE_ACCEPT,
+	)
+	if err != nil {
+		log.Fatal("Unable to create FileChooserDialog:", err)
+	}
+	dialog.SetDoOverwriteConfirmation(true)
+	if dialog.Run() == gtk.RESPONSE_ACCEPT {
+		if err := ioutil.WriteFile(dialog.GetFilename(), rv.body, 0644); err != nil {
+			errorDiag.ShowError(fmt.Sprintf("Unable to save the response body.\n%s", err))
+		}
+	}
+	dialog.Destroy()
+}
+
+func getResponseView(settings *storage.Settings, errorDiag *ErrorDialog) (*gtk.Grid, *ResponseView) {
+	responseGrid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create responseGrid:", err)
+	}
+	responseGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	toolBox, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	if err != nil {
+		log.Fatal("Unable to create toolBox:", err)
+	}
+	setMargins(toolBox, 5, 5, 5, 5)
+
+	mode, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		log.Fatal("Unable to create ComboBoxText:", err)
+	}
+	mode.Append(ViewAuto, "Auto")
+	mode.Append(ViewText, "Text")
+	mode.Append(ViewHex, "Hex")
+	mode.Append(ViewImage, "Image")
+	mode.SetActiveID(ViewAuto)
+
+	info, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal("Unable to create label:", err)
+	}
+	info.SetEllipsize(pango.ELLIPSIZE_END)
+
+	saveBtn, err := gtk.ButtonNewWithLabel("Save response body to file")
+	if err != nil {
+		log.Fatal("Unable to create Button:", err)
+	}
+	saveBtn.SetSensitive(false)
+
+	toolBox.PackStart(mode, false, false, 0)
+	toolBox.PackStart(info, false, false, 5)
+	toolBox.PackEnd(saveBtn, false, false, 0)
+
+	stack, err := gtk.StackNew()
+	if err != nil {
+		log.Fatal("Unable to create Stack:", err)
+	}
+	stack.SetVExpand(true)
+	stack.SetHExpand(true)
+
+	textWindow, responseText := getScrollableTextView("Response")
+	responseText.SetEditable(false)
+
+	hexWindow, hexText := getScrollableTextView("Hex")
+	hexText.SetEditable(false)
+	hexText.SetMonospace(true)
+	hexText.SetWrapMode(gtk.WRAP_NONE)
+
+	image, err := gtk.ImageNew()
+	if err != nil {
+		log.Fatal("Unable to create Image:", err)
+	}
+	imageWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	imageWindow.Add(image)
+
+	stack.AddNamed(textWindow, ViewText)
+	stack.AddNamed(hexWindow, ViewHex)
+	stack.AddNamed(imageWindow, ViewImage)
+
+	responseGrid.Add(toolBox)
+	responseGrid.Add(stack)
+
+	rv := &ResponseView{
+		settings: settings,
+		stack:    stack,
+		mode:     mode,
+		info:     info,
+		text:     responseText,
+		hex:      hexText,
+		image:    image,
+		saveBtn:  saveBtn,
+	}
+
+	mode.Connect("changed", rv.refresh)
+	saveBtn.Connect("clicked", func() {
+		rv.save(errorDiag)
+	})
+
+	return responseGrid, rv
+}
@@ -0,0 +1,158 @@
+package window
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/scripting"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestScripts holds the widgets of the request "Scripts" tab
+type RequestScripts struct {
+	pre  *gtk.TextBuffer
+	post *gtk.TextBuffer
+}
+
+// Load displays the scripts of a stored request
+func (rs *RequestScripts) Load(rq storage.RequestInput) {
+	rs.pre.SetText(rq.PreRequestScript)
+	rs.post.SetText(rq.PostResponseScript)
+}
+
+// Apply stores the scripts of the tab into the request
+func (rs *RequestScripts) Apply(rq *storage.RequestInput) {
+	rq.PreRequestScript = bufferText(rs.pre)
+	rq.PostResponseScript = bufferText(rs.post)
+}
+
+// Reset clears the scripts tab
+func (rs *RequestScripts) Reset() {
+	rs.pre.SetText("")
+	rs.post.SetText("")
+}
+
+func bufferText(buff *gtk.TextBuffer) string {
+	text, err := buff.GetText(buff.GetStartIter(), buff.GetEndIter(), true)
+	if err != nil {
+		log.Fatal("Unable to get text:", err)
+	}
+	return text
+}
+
+// getScriptEditor returns a titled monospace editor for one of the scripts
+func getScriptEditor(title, tooltip string) (*gtk.Box, *gtk.TextBuffer) {
+	box, err := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 3)
+	if err != nil {
+		log.Fatal("Unable to create script box:", err)
+	}
+	label, err := gtk.LabelNew(title)
+	if err != nil {
+		log.Fatal("Unable to create label:", err)
+	}
+	label.SetHAlign(gtk.ALIGN_START)
+	setMargins(label, 5, 5, 0, 5)
+
+	textView, err := gtk.TextViewNew()
+	if err != nil {
+		log.Fatal("Unable to create TextView:", err)
+	}
+	textView.SetMonospace(true)
+	textView.SetTooltipText(tooltip)
+	buff, err := textView.GetBuffer()
+	if err != nil {
+		log.Fatal("Unable to get buffer:", err)
+	}
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(textView)
+	scrolledWindow.SetVExpand(true)
+	scrolledWindow.SetHExpand(true)
+
+	box.PackStart(label, false, false, 0)
+	box.PackStart(scrolledWindow, true, true, 0)
+	return box, buff
+}
+
+func getRequestScripts() (*gtk.Paned, *RequestScripts) {
+	pane, err := gtk.PanedNew(gtk.ORIENTATION_VERTICAL)
+	if err != nil {
+		log.Fatal("Unable to create paned:", err)
+	}
+
+	preBox, pre := getScriptEditor("Pre-request script",
+		"Runs before the request is sent, request.method, request.url, request.headers and request.body can be changed.\n"+
+			"variables.get, variables.set and variables.replace work with the {{name}} variables, crypto.hash and crypto.hmac sign requests.")
+	postBox, post := getScriptEditor("Post-response script",
+		"Runs once the response is received, it is available as response.status, response.headers, response.body and response.json().\n"+
+			"test(name, fn) adds a check to the Tests tab that fails when fn throws, variables.set stores values for later requests.")
+
+	pane.Pack1(preBox, true, false)
+	pane.Pack2(postBox, true, false)
+	return pane, &RequestScripts{pre, post}
+}
+
+// ConsoleView shows what the scripts wrote to the console
+type ConsoleView struct {
+	textView *gtk.TextView
+	buff     *gtk.TextBuffer
+}
+
+// Clear empties the console
+func (cv *ConsoleView) Clear() {
+	cv.buff.SetText("")
+}
+
+// Append adds the console output of a script
+func (cv *ConsoleView) Append(hook string, lines []scripting.Line) {
+	for _, line := range lines {
+		cv.write(line.Level, fmt.Sprintf("[%s] %s", hook, line.Message))
+	}
+}
+
+// Error adds a script error to the console
+func (cv *ConsoleView) Error(err error) {
+	cv.write(scripting.LevelError, err.Error())
+}
+
+func (cv *ConsoleView) write(level, message string) {
+	end := cv.buff.GetEndIter()
+	cv.buff.InsertWithTagByName(end, time.Now().Format("15:04:05 "), "time")
+	switch level {
+	case scripting.LevelWarn, scripting.LevelError:
+		cv.buff.InsertWithTagByName(cv.buff.GetEndIter(), message+"\n", level)
+	default:
+		cv.buff.Insert(cv.buff.GetEndIter(), message+"\n")
+	}
+	cv.textView.ScrollToIter(cv.buff.GetEndIter(), 0, false, 0, 0)
+}
+
+func getConsoleView() (*gtk.ScrolledWindow, *ConsoleView) {
+	textView, err := gtk.TextViewNew()
+	if err != nil {
+		log.Fatal("Unable to create TextView:", err)
+	}
+	textView.SetMonospace(true)
+	textView.SetEditable(false)
+	textView.SetWrapMode(gtk.WRAP_WORD_CHAR)
+	buff, err := textView.GetBuffer()
+	if err != nil {
+		log.Fatal("Unable to get buffer:", err)
+	}
+	buff.CreateTag("time", map[string]interface{}{"foreground": "#888888"})
+	buff.CreateTag(scripting.LevelWarn, map[string]interface{}{"foreground": "#c47f00"})
+	buff.CreateTag(scripting.LevelError, map[string]interface{}{"foreground": "#cc0000"})
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(textView)
+
+	return scrolledWindow, &ConsoleView{textView, buff}
+}
@@ -2,15 +2,17 @@ package window
 
 import (
 	"fmt"
+	"html"
 
 	log "github.com/sirupsen/logrus"
 
 	evbus "github.com/asaskevich/EventBus"
+	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/gtk"
 	"github.com/lnenad/probster/storage"
 )
 
-func GetSidebar(h *storage.HistoryStorage, bus evbus.Bus) (*gtk.Grid, *gtk.ListBox) {
+func GetSidebar(h *storage.HistoryStorage, collections *gtk.Grid, bus evbus.Bus) (*gtk.Paned, *gtk.ListBox) {
 	sideGrid, _ := gtk.GridNew()
 	sideGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
 	sideGrid.SetVExpand(true)
@@ -33,6 +35,20 @@ func GetSidebar(h *storage.HistoryStorage, bus evbus.Bus) (*gtk.Grid, *gtk.ListB
 		AddHistoryRow(h, listView, entry.Key, entry.RR)
 	}
 
+	// imported entries are listed under the name of their group
+	listView.SetHeaderFunc(func(row, before *gtk.ListBoxRow, userData ...interface{}) {
+		group := rowGroup(row)
+		if group == "" || (before != nil && rowGroup(before) == group) {
+			row.SetHeader(nil)
+			return
+		}
+		header, _ := gtk.LabelNew("")
+		header.SetMarkup(fmt.Sprintf("<b>%s</b>", html.EscapeString(group)))
+		header.SetHAlign(gtk.ALIGN_START)
+		setMargins(header, 5, 10, 5, 10)
+		row.SetHeader(header)
+	})
+
 	listView.Connect("row_selected", func(lb *gtk.ListBox, row *gtk.ListBoxRow) {
 		if lb.GetSelectedRows().Length() > 0 {
 			id, err := row.GetName()
@@ -46,6 +62,43 @@ func GetSidebar(h *storage.HistoryStorage, bus evbus.Bus) (*gtk.Grid, *gtk.ListB
 		}
 	})
 
+	// a right click on a row offers to copy its request as code
+	var menuKey string
+	snippetMenu := getSnippetMenu(func(format string) {
+		bus.Publish("history:copy", format, menuKey)
+	})
+	listView.Connect("button-press-event", func(lb *gtk.ListBox, ev *gdk.Event) bool {
+		event := gdk.EventButtonNewFromEvent(ev)
+		if event.Button() != gdk.BUTTON_SECONDARY {
+			return false
+		}
+		row := lb.GetRowAtY(int(event.Y()))
+		if row == nil {
+			return false
+		}
+		key, err := row.GetName()
+		if err != nil {
+			log.Printf("Error getting row id: %s", err)
+			return false
+		}
+		menuKey = key
+		snippetMenu.PopupAtPointer(ev)
+		return true
+	})
+
+	collectionsLbl, _ := gtk.LabelNew("")
+	collectionsLbl.SetMarkup("<span size='large'>Collections</span>")
+	collectionsLbl.SetHAlign(gtk.ALIGN_START)
+	setMargins(collectionsLbl, 10, 10, 10, 10)
+
+	collectionsSep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
+
+	collectionsGrid, _ := gtk.GridNew()
+	collectionsGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+	collectionsGrid.Add(collectionsLbl)
+	collectionsGrid.Add(collectionsSep)
+	collectionsGrid.Add(collections)
+
 	historyLbl, _ := gtk.LabelNew("")
 	historyLbl.SetMarkup("<span size='large'>Request History</span>")
 	historyLbl.SetHAlign(gtk.ALIGN_START)
@@ -57,7 +110,22 @@ func GetSidebar(h *storage.HistoryStorage, bus evbus.Bus) (*gtk.Grid, *gtk.ListB
 	sideGrid.Add(historySep)
 	sideGrid.Add(scrolledWindow)
 
-	return sideGrid, listView
+	sidePane, _ := gtk.PanedNew(gtk.ORIENTATION_VERTICAL)
+	sidePane.Pack1(collectionsGrid, true, false)
+	sidePane.Pack2(sideGrid, true, false)
+
+	return sidePane, listView
+}
+
+// historyGroups holds the group of the history rows by their key
+var historyGroups = map[string]string{}
+
+func rowGroup(row *gtk.ListBoxRow) string {
+	key, err := row.GetName()
+	if err != nil {
+		return ""
+	}
+	return historyGroups[key]
 }
 
 func AddHistoryRow(
@@ -74,7 +142,19 @@ func AddHistoryRow(
 	lblMethod, _ := gtk.LabelNew("")
 	//lblMethod.SetHExpand(true)
 	lblMethod.SetWidthChars(11)
-	if reqRes.Response.StatusCode <= 299 {
+	passed, tests := reqRes.Response.TestsPassed()
+	if !reqRes.Response.Completed() {
+		lblMethod.SetMarkup(fmt.Sprintf(`<span size='large' foreground='grey'>%s</span>`, reqRes.Request.Method))
+		lblMethod.SetTooltipText(fmt.Sprintf("Request %s", reqRes.Response.Outcome))
+	} else if tests > 0 {
+		// requests with assertions are coloured by their outcome rather than the status code
+		colour := "green"
+		if passed < tests {
+			colour = "red"
+		}
+		lblMethod.SetMarkup(fmt.Sprintf(`<span size='large' foreground='%s'>%s</span>`, colour, reqRes.Request.Method))
+		lblMethod.SetTooltipText(fmt.Sprintf("%d of %d tests passed, status code %d", passed, tests, reqRes.Response.StatusCode))
+	} else if reqRes.Response.StatusCode <= 299 {
 		lblMethod.SetMarkup(fmt.Sprintf(`<span size='large' foreground='green'>%s</span>`, reqRes.Request.Method))
 	} else if reqRes.Response.StatusCode > 299 && reqRes.Response.StatusCode < 399 {
 		lblMethod.SetMarkup(fmt.Sprintf(`<span size='large' foreground='orange'>%s</span>`, reqRes.Request.Method))
@@ -100,10 +180,14 @@ func AddHistoryRow(
 	listRow.Add(box)
 	listRow.SetHExpand(true)
 	listRow.SetName(key)
-	listRow.SetTooltipText("Load this request")
+	if reqRes.Group != "" {
+		historyGroups[key] = reqRes.Group
+	}
+	listRow.SetTooltipText("Load this request, right click to copy it as code")
 
 	btn.Connect("clicked", func() {
 		historyListbox.Remove(listRow)
+		delete(historyGroups, key)
 		go h.RemoveEntry(key)
 	})
 
@@ -0,0 +1,48 @@
+package window
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// copySnippet copies the request to the clipboard as code in the provided format,
+// the variables of the active environment are substituted
+func copySnippet(format string, request storage.RequestInput, vars map[string]string, errorDiag *ErrorDialog) {
+	resolved, _ := request.Resolve(vars)
+	snippet, err := storage.Snippet(format, resolved)
+	if err != nil {
+		errorDiag.ShowError(fmt.Sprintf("Unable to generate the snippet.\n%s", err))
+		return
+	}
+	clipboard, err := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+	if err != nil {
+		log.Printf("Unable to access the clipboard: %s", err)
+		return
+	}
+	clipboard.SetText(snippet)
+}
+
+// getSnippetMenu returns a popup menu with an item for every snippet format
+func getSnippetMenu(onCopy func(format string)) *gtk.Menu {
+	menu, err := gtk.MenuNew()
+	if err != nil {
+		log.Fatal("Unable to create Menu:", err)
+	}
+	for _, f := range storage.SnippetFormats {
+		format := f.ID
+		item, err := gtk.MenuItemNewWithLabel(fmt.Sprintf("Copy as %s", f.Name))
+		if err != nil {
+			log.Fatal("Unable to create MenuItem:", err)
+		}
+		item.Connect("activate", func() {
+			onCopy(format)
+		})
+		menu.Append(item)
+	}
+	menu.ShowAll()
+	return menu
+}
@@ -0,0 +1,113 @@
+package window
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gotk3/gotk3/cairo"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/communication"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	waterfallRowHeight   = 28
+	waterfallLabelWidth  = 150
+	waterfallValueWidth  = 90
+	waterfallMarginWidth = 10
+)
+
+// phaseColours are used to paint the waterfall bars, in the order returned by Timing.Phases
+var phaseColours = [][3]float64{
+	{0.16, 0.50, 0.73},
+	{0.95, 0.61, 0.07},
+	{0.56, 0.27, 0.68},
+	{0.15, 0.68, 0.38},
+	{0.91, 0.30, 0.24},
+}
+
+// TimingView renders the request timing breakdown as a waterfall
+type TimingView struct {
+	area   *gtk.DrawingArea
+	timing communication.Timing
+}
+
+// SetTiming replaces the displayed timing
+func (tv *TimingView) SetTiming(timing communication.Timing) {
+	tv.timing = timing
+	tv.area.QueueDraw()
+}
+
+func getTimingView() (*gtk.ScrolledWindow, *TimingView) {
+	area, err := gtk.DrawingAreaNew()
+	if err != nil {
+		log.Fatal("Unable to create DrawingArea:", err)
+	}
+	area.SetHExpand(true)
+	area.SetVExpand(true)
+
+	tv := &TimingView{area: area}
+	phaseCount := len(tv.timing.Phases()) + 1
+	area.SetSizeRequest(waterfallLabelWidth+waterfallValueWidth+100, phaseCount*waterfallRowHeight+2*waterfallMarginWidth)
+
+	area.Connect("draw", func(da *gtk.DrawingArea, cr *cairo.Context) {
+		tv.draw(da, cr)
+	})
+
+	scrolledWindow, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal("Unable to create ScrolledWindow:", err)
+	}
+	scrolledWindow.Add(area)
+
+	return scrolledWindow, tv
+}
+
+func (tv *TimingView) draw(da *gtk.DrawingArea, cr *cairo.Context) {
+	width := float64(da.GetAllocatedWidth())
+	barsWidth := width - waterfallLabelWidth - waterfallValueWidth - 2*waterfallMarginWidth
+	if barsWidth < 10 {
+		barsWidth = 10
+	}
+	scale := 0.0
+	if tv.timing.Total > 0 {
+		scale = barsWidth / float64(tv.timing.Total)
+	}
+
+	cr.SelectFontFace("Sans", cairo.FONT_SLANT_NORMAL, cairo.FONT_WEIGHT_NORMAL)
+	cr.SetFontSize(12)
+
+	y := float64(waterfallMarginWidth)
+	for idx, phase := range tv.timing.Phases() {
+		colour := phaseColours[idx%len(phaseColours)]
+
+		cr.SetSourceRGB(0.4, 0.4, 0.4)
+		cr.MoveTo(waterfallMarginWidth, y+waterfallRowHeight/2+4)
+		cr.ShowText(phase.Name)
+
+		x := waterfallMarginWidth + waterfallLabelWidth + float64(phase.Start)*scale
+		w := float64(phase.Duration) * scale
+		if phase.Duration > 0 && w < 1 {
+			w = 1
+		}
+		cr.SetSourceRGB(colour[0], colour[1], colour[2])
+		cr.Rectangle(x, y+6, w, waterfallRowHeight-12)
+		cr.Fill()
+
+		cr.SetSourceRGB(0.4, 0.4, 0.4)
+		cr.MoveTo(width-waterfallValueWidth, y+waterfallRowHeight/2+4)
+		cr.ShowText(formatDuration(phase.Duration))
+
+		y += waterfallRowHeight
+	}
+
+	cr.SetSourceRGB(0.4, 0.4, 0.4)
+	cr.MoveTo(waterfallMarginWidth, y+waterfallRowHeight/2+4)
+	cr.ShowText("Total")
+	cr.MoveTo(width-waterfallValueWidth, y+waterfallRowHeight/2+4)
+	cr.ShowText(formatDuration(tv.timing.Total))
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.2f ms", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,160 @@
+package window
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/lnenad/probster/communication"
+	log "github.com/sirupsen/logrus"
+)
+
+const caFilesSeparator = ";"
+
+// TLSForm edits a communication.TLSOptions, it is shared by the preferences
+// dialog and the request "TLS" tab
+type TLSForm struct {
+	// insecureSkipVerify is shown in the preferences, verification in the request tab
+	insecureSkipVerify *gtk.CheckButton
+	verification       *gtk.ComboBoxText
+	caFiles            *gtk.Entry
+	clientCertFile     *gtk.Entry
+	clientKeyFile      *gtk.Entry
+	minVersion         *gtk.ComboBoxText
+	maxVersion         *gtk.ComboBoxText
+	serverName         *gtk.Entry
+}
+
+// Options returns the TLS options currently set in the form
+func (tf *TLSForm) Options() communication.TLSOptions {
+	var caFiles []string
+	text, _ := tf.caFiles.GetText()
+	for _, file := range strings.Split(text, caFilesSeparator) {
+		if file = strings.TrimSpace(file); file != "" {
+			caFiles = append(caFiles, file)
+		}
+	}
+
+	options := communication.TLSOptions{
+		CAFiles:        caFiles,
+		ClientCertFile: entryText(tf.clientCertFile),
+		ClientKeyFile:  entryText(tf.clientKeyFile),
+		MinVersion:     tf.minVersion.GetActiveID(),
+		MaxVersion:     tf.maxVersion.GetActiveID(),
+		ServerName:     entryText(tf.serverName),
+	}
+	if tf.verification != nil {
+		options.Verification = tf.verification.GetActiveID()
+	} else {
+		options.InsecureSkipVerify = tf.insecureSkipVerify.GetActive()
+	}
+	return options
+}
+
+// SetOptions displays the provided TLS options in the form
+func (tf *TLSForm) SetOptions(t communication.TLSOptions) {
+	if tf.verification != nil {
+		mode := t.Verification
+		if mode == "" {
+			// requests stored before the verification mode existed only set the flag
+			mode = communication.TLSVerifyInherit
+			if t.InsecureSkipVerify {
+				mode = communication.TLSSkipVerify
+			}
+		}
+		tf.verification.SetActiveID(mode)
+	} else {
+		tf.insecureSkipVerify.SetActive(t.InsecureSkipVerify)
+	}
+	tf.caFiles.SetText(strings.Join(t.CAFiles, caFilesSeparator+" "))
+	tf.clientCertFile.SetText(t.ClientCertFile)
+	tf.clientKeyFile.SetText(t.ClientKeyFile)
+	tf.minVersion.SetActiveID(t.MinVersion)
+	tf.maxVersion.SetActiveID(t.MaxVersion)
+	tf.serverName.SetText(t.ServerName)
+}
+
+// getTLSForm builds the form, defaultLabel names the value used when a version is not chosen.
+// The request form can also inherit or override the certificate verification.
+func getTLSForm(defaultLabel string, perRequest bool) (*gtk.Grid, *TLSForm) {
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal("Unable to create TLS grid:", err)
+	}
+	grid.SetRowSpacing(5)
+	grid.SetColumnSpacing(10)
+
+	tf := &TLSForm{}
+
+	if perRequest {
+		lbl, _ := gtk.LabelNew("Certificate verification")
+		lbl.SetHAlign(gtk.ALIGN_START)
+		tf.verification, err = gtk.ComboBoxTextNew()
+		if err != nil {
+			log.Fatal("Unable to create ComboBoxText:", err)
+		}
+		tf.verification.Append(communication.TLSVerifyInherit, defaultLabel)
+		tf.verification.Append(communication.TLSVerify, "Verify the certificate")
+		tf.verification.Append(communication.TLSSkipVerify, "Skip verification (insecure)")
+		tf.verification.SetActiveID(communication.TLSVerifyInherit)
+		grid.Attach(lbl, 0, 0, 1, 1)
+		grid.Attach(tf.verification, 1, 0, 1, 1)
+	} else {
+		tf.insecureSkipVerify, err = gtk.CheckButtonNewWithLabel("Skip certificate verification (insecure)")
+		if err != nil {
+			log.Fatal("Unable to create CheckButton:", err)
+		}
+		grid.Attach(tf.insecureSkipVerify, 0, 0, 2, 1)
+	}
+
+	tf.caFiles = attachEntry(grid, "Extra CA files (PEM)", fmt.Sprintf("Paths separated by %s", caFilesSeparator), 1)
+	tf.clientCertFile = attachEntry(grid, "Client certificate (PEM)", "Path to the certificate", 2)
+	tf.clientKeyFile = attachEntry(grid, "Client key (PEM)", "Path to the private key", 3)
+	tf.minVersion = attachVersionCombo(grid, "Minimum TLS version", defaultLabel, 4)
+	tf.maxVersion = attachVersionCombo(grid, "Maximum TLS version", defaultLabel, 5)
+	tf.serverName = attachEntry(grid, "Server name (SNI)", "Host name sent to the server", 6)
+
+	return grid, tf
+}
+
+func attachEntry(grid *gtk.Grid, label, placeholder string, row int) *gtk.Entry {
+	lbl, _ := gtk.LabelNew(label)
+	lbl.SetHAlign(gtk.ALIGN_START)
+
+	entry, err := gtk.EntryNew()
+	if err != nil {
+		log.Fatal("Unable to create Entry:", err)
+	}
+	entry.SetPlaceholderText(placeholder)
+	entry.SetHExpand(true)
+
+	grid.Attach(lbl, 0, row, 1, 1)
+	grid.Attach(entry, 1, row, 1, 1)
+
+	return entry
+}
+
+func attachVersionCombo(grid *gtk.Grid, label, defaultLabel string, row int) *gtk.ComboBoxText {
+	lbl, _ := gtk.LabelNew(label)
+	lbl.SetHAlign(gtk.ALIGN_START)
+
+	combo, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		log.Fatal("Unable to create ComboBoxText:", err)
+	}
+	combo.Append("", defaultLabel)
+	for _, v := range communication.TLSVersions {
+		combo.Append(v, fmt.Sprintf("TLS %s", v))
+	}
+	combo.SetActiveID("")
+
+	grid.Attach(lbl, 0, row, 1, 1)
+	grid.Attach(combo, 1, row, 1, 1)
+
+	return combo
+}
+
+func entryText(entry *gtk.Entry) string {
+	text, _ := entry.GetText()
+	return strings.TrimSpace(text)
+}